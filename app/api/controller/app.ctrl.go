@@ -2,36 +2,55 @@ package controller
 
 import (
 	"fmt"
+	"kms/wallet/app/kmsclient"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type appCtrl struct {
+	kmsClient *kmsclient.ResilientClient
 }
 
-func NewAppCtrl() *appCtrl {
-	c := &appCtrl{}
+func NewAppCtrl(kmsClient *kmsclient.ResilientClient) *appCtrl {
+	c := &appCtrl{kmsClient}
 
 	return c
 }
 
 func (c *appCtrl) BootStrap(router fiber.Router) {
 	router.Get("/health", c.HealthCheck)
+}
+
+// 에러 응답 확인용 route. local 환경에서만 등록한다
+func (c *appCtrl) BootStrapDebug(router fiber.Router) {
 	router.Get("/error", c.Error)
 }
 
 // @tags Health
+// @description Returns 503 while the kms circuit breaker is open.
+// @description Kept for compatibility, use /api/health/live and /api/health/ready for probes.
 // @success 200
+// @failure 503
 // @router /api/health [get]
 func (c *appCtrl) HealthCheck(ctx *fiber.Ctx) error {
-	return ctx.JSON(fiber.Map{
-		"success": true,
+	if c.kmsClient == nil {
+		return ctx.JSON(fiber.Map{
+			"success": true,
+		})
+	}
+
+	kmsHealth := c.kmsClient.Health()
+	status := fiber.StatusOK
+	if kmsHealth.State == kmsclient.StateOpen {
+		status = fiber.StatusServiceUnavailable
+	}
+	return ctx.Status(status).JSON(fiber.Map{
+		"success": status == fiber.StatusOK,
+		"kms":     kmsHealth,
 	})
 }
 
-// @tags Health
-// @success 200
-// @router /api/error [get]
+// 처리되지 않은 에러 응답 확인용 (swagger 문서에는 노출하지 않는다)
 func (c *appCtrl) Error(ctx *fiber.Ctx) error {
 	// return fiber.ErrInternalServerError
 	return fmt.Errorf("sdfdsf")
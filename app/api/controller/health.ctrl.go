@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type healthCtrl struct {
+	healthSrv *srv.HealthSrv
+}
+
+func NewHealthCtrl(healthSrv *srv.HealthSrv) *healthCtrl {
+	return &healthCtrl{healthSrv}
+}
+
+func (c *healthCtrl) BootStrap(router fiber.Router) {
+	router.Get("/health/live", c.Liveness)
+	router.Get("/health/ready", c.Readiness)
+}
+
+// @tags Health
+// @summary Liveness probe
+// @description Only checks that the process is serving requests. External dependencies are not checked.
+// @produce json
+// @success 200 {object} dto.LivenessRes
+// @router /api/health/live [get]
+func (c *healthCtrl) Liveness(ctx *fiber.Ctx) error {
+	return ctx.JSON(dto.LivenessRes{Alive: true})
+}
+
+// @tags Health
+// @summary Readiness probe
+// @description Checks kms reachability, the canary key signature (when configured), rpc endpoints and audit log writability.
+// @description Returns 503 when any check fails.
+// @produce json
+// @success 200 {object} dto.ReadinessRes
+// @failure 503 {object} dto.ReadinessRes
+// @router /api/health/ready [get]
+func (c *healthCtrl) Readiness(ctx *fiber.Ctx) error {
+	readinessRes := c.healthSrv.Readiness(ctx.UserContext())
+	status := fiber.StatusOK
+	if !readinessRes.Ready {
+		status = fiber.StatusServiceUnavailable
+	}
+	return ctx.Status(status).JSON(readinessRes)
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type jobCtrl struct {
+	jobSrv *srv.JobSrv
+}
+
+func NewJobCtrl(jobSrv *srv.JobSrv) *jobCtrl {
+	return &jobCtrl{jobSrv}
+}
+
+func (c *jobCtrl) BootStrap(router fiber.Router) {
+	router.Post("/jobs/sign", c.EnqueueSignJob)
+	router.Get("/jobs/:id", c.GetJob)
+}
+
+// @tags Job
+// @summary Enqueue transactions to be signed asynchronously.
+// @description When callbackURL is set, the finished job is POSTed to it with
+// @description X-Signature = "sha256=" + hex(HMAC-SHA256(secret, X-Signature-Timestamp + "." + body)).
+// @produce json
+// @success 202 {object} dto.JobRes
+// @router  /api/jobs/sign [post]
+// @param   subject body dto.SignJobReq true "subject"
+func (c *jobCtrl) EnqueueSignJob(ctx *fiber.Ctx) error {
+	signJobReq, err := dto.ShouldBind[dto.SignJobReq](ctx.BodyParser)
+	if err != nil {
+		return err
+	}
+
+	jobRes, err := c.jobSrv.EnqueueSignJob(ctx.UserContext(), signJobReq)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(jobRes)
+}
+
+// @tags Job
+// @summary Get status and result of job
+// @produce json
+// @success 200 {object} dto.JobRes
+// @router  /api/jobs/{id} [get]
+// @param   id path string true "job id"
+func (c *jobCtrl) GetJob(ctx *fiber.Ctx) error {
+	jobIdReq, err := dto.ShouldBind[dto.JobIdReq](ctx.ParamsParser)
+	if err != nil {
+		return err
+	}
+
+	jobRes, err := c.jobSrv.GetJob(jobIdReq)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(jobRes)
+}
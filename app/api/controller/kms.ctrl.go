@@ -18,9 +18,18 @@ func NewKmsCtrl(kmsSrv *srv.KmsSrv) *kmsCtrl {
 func (c *kmsCtrl) BootStrap(router fiber.Router) {
 	router.Post("/create/account", c.CreateAccount)
 	router.Post("/import/account", c.ImportAccount)
+	router.Post("/import/account/keystore", c.ImportKeystore)
+	router.Post("/import/account/mnemonic", c.ImportMnemonic)
+	router.Post("/import/account/pem", c.ImportPem)
+	router.Get("/import/params", c.GetImportParams)
+	router.Post("/import/account/encrypted", c.ImportEncryptedAccount)
+	router.Post("/accounts/batch", c.CreateAccountBatch)
 	router.Get("/accounts", c.GetAccountList)
 	router.Get("/accounts/:keyID", c.GetAccount)
 	router.Delete("/accounts/:keyID", c.DeleteAccount)
+	router.Post("/accounts/:keyID/disable", c.DisableAccount)
+	router.Post("/accounts/:keyID/enable", c.EnableAccount)
+	router.Get("/accounts/:keyID/policy", c.CheckKeyPolicy)
 }
 
 // @tags Kms
@@ -35,7 +44,7 @@ func (c *kmsCtrl) GetAccount(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	accountRes, err := c.kmsSrv.GetAccount(keyIdReq)
+	accountRes, err := c.kmsSrv.GetAccount(ctx.UserContext(), keyIdReq)
 	if err != nil {
 		return err
 	}
@@ -55,7 +64,7 @@ func (c *kmsCtrl) GetAccountList(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	accountListRes, err := c.kmsSrv.GetAccountList(accountListReq)
+	accountListRes, err := c.kmsSrv.GetAccountList(ctx.UserContext(), accountListReq)
 	if err != nil {
 		return err
 	}
@@ -65,11 +74,25 @@ func (c *kmsCtrl) GetAccountList(ctx *fiber.Ctx) error {
 
 // @tags Kms
 // @summary Create new account
+// @description multiRegion creates a multi-Region key, replicate also replicates it to the configured replica regions
 // @produce json
 // @success 201 {object} dto.AccountRes
 // @router  /api/create/account [post]
+// @param   options query dto.CreateAccountReq false "create options"
 func (c *kmsCtrl) CreateAccount(ctx *fiber.Ctx) error {
-	accountRes, err := c.kmsSrv.CreateAccount()
+	createAccountReq, err := dto.ShouldBind[dto.CreateAccountReq](ctx.QueryParser)
+	if err != nil {
+		return err
+	}
+
+	var opts []srv.CreateAccountOption
+	if createAccountReq.MultiRegion {
+		opts = append(opts, srv.MultiRegion())
+	}
+	if createAccountReq.Replicate {
+		opts = append(opts, srv.Replicate())
+	}
+	accountRes, err := c.kmsSrv.CreateAccount(ctx.UserContext(), opts...)
 	if err != nil {
 		return err
 	}
@@ -77,6 +100,28 @@ func (c *kmsCtrl) CreateAccount(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusCreated).JSON(accountRes)
 }
 
+// @tags Kms
+// @summary Create multiple accounts
+// @description Returns 201 when every account is created, 207 with per-item errors otherwise
+// @produce json
+// @success 201 {object} dto.AccountBatchRes
+// @success 207 {object} dto.AccountBatchRes
+// @router  /api/accounts/batch [post]
+// @param   subject body dto.AccountBatchReq true "subject"
+func (c *kmsCtrl) CreateAccountBatch(ctx *fiber.Ctx) error {
+	accountBatchReq, err := dto.ShouldBind[dto.AccountBatchReq](ctx.BodyParser)
+	if err != nil {
+		return err
+	}
+
+	accountBatchRes := c.kmsSrv.CreateAccountBatch(ctx.UserContext(), accountBatchReq)
+	if accountBatchRes.Failed > 0 {
+		return ctx.Status(fiber.StatusMultiStatus).JSON(accountBatchRes)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(accountBatchRes)
+}
+
 // @tags Kms
 // @summary Import account to kms
 // @produce json
@@ -89,7 +134,108 @@ func (c *kmsCtrl) ImportAccount(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	accountRes, err := c.kmsSrv.ImportAccount(pkReq)
+	accountRes, err := c.kmsSrv.ImportAccount(ctx.UserContext(), pkReq)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(accountRes)
+}
+
+// @tags Kms
+// @summary Import account from encrypted web3 keystore v3 json
+// @produce json
+// @success 201 {object} dto.ImportAccountRes
+// @router  /api/import/account/keystore [post]
+// @param   subject body dto.KeystoreImportReq true "subject"
+func (c *kmsCtrl) ImportKeystore(ctx *fiber.Ctx) error {
+	keystoreReq, err := dto.ShouldBind[dto.KeystoreImportReq](ctx.BodyParser)
+	if err != nil {
+		return err
+	}
+
+	importRes, err := c.kmsSrv.ImportKeystore(ctx.UserContext(), keystoreReq)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(importRes)
+}
+
+// @tags Kms
+// @summary Import accounts derived from bip-39 mnemonic
+// @description Returns 201 when every account is imported, 207 with per-item errors otherwise
+// @produce json
+// @success 201 {object} dto.ImportAccountListRes
+// @success 207 {object} dto.ImportAccountListRes
+// @router  /api/import/account/mnemonic [post]
+// @param   subject body dto.MnemonicImportReq true "subject"
+func (c *kmsCtrl) ImportMnemonic(ctx *fiber.Ctx) error {
+	mnemonicReq, err := dto.ShouldBind[dto.MnemonicImportReq](ctx.BodyParser)
+	if err != nil {
+		return err
+	}
+
+	importListRes, err := c.kmsSrv.ImportMnemonic(ctx.UserContext(), mnemonicReq)
+	if err != nil {
+		return err
+	}
+
+	for _, importRes := range importListRes.Accounts {
+		if importRes.Error != nil {
+			return ctx.Status(fiber.StatusMultiStatus).JSON(importListRes)
+		}
+	}
+	return ctx.Status(fiber.StatusCreated).JSON(importListRes)
+}
+
+// @tags Kms
+// @summary Import account from sec1 or pkcs8 pem
+// @produce json
+// @success 201 {object} dto.ImportAccountRes
+// @router  /api/import/account/pem [post]
+// @param   subject body dto.PemImportReq true "subject"
+func (c *kmsCtrl) ImportPem(ctx *fiber.Ctx) error {
+	pemReq, err := dto.ShouldBind[dto.PemImportReq](ctx.BodyParser)
+	if err != nil {
+		return err
+	}
+
+	importRes, err := c.kmsSrv.ImportPem(ctx.UserContext(), pemReq)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(importRes)
+}
+
+// @tags Kms
+// @summary Create key shell for import and get wrapping key with import token
+// @produce json
+// @success 200 {object} dto.ImportParamsRes
+// @router  /api/import/params [get]
+func (c *kmsCtrl) GetImportParams(ctx *fiber.Ctx) error {
+	importParamsRes, err := c.kmsSrv.GetImportParams(ctx.UserContext())
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(importParamsRes)
+}
+
+// @tags Kms
+// @summary Import key material wrapped on client side
+// @produce json
+// @success 201 {object} dto.AccountRes
+// @router  /api/import/account/encrypted [post]
+// @param   subject body dto.EncryptedImportReq true "subject"
+func (c *kmsCtrl) ImportEncryptedAccount(ctx *fiber.Ctx) error {
+	encryptedReq, err := dto.ShouldBind[dto.EncryptedImportReq](ctx.BodyParser)
+	if err != nil {
+		return err
+	}
+
+	accountRes, err := c.kmsSrv.ImportEncryptedAccount(ctx.UserContext(), encryptedReq)
 	if err != nil {
 		return err
 	}
@@ -109,10 +255,86 @@ func (c *kmsCtrl) DeleteAccount(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	accountDeletionRes, err := c.kmsSrv.DeleteAccount(keyIdReq)
+	accountDeletionRes, err := c.kmsSrv.DeleteAccount(ctx.UserContext(), keyIdReq)
 	if err != nil {
 		return err
 	}
 
 	return ctx.Status(fiber.StatusOK).JSON(accountDeletionRes)
 }
+
+// @tags Kms
+// @summary Freeze account of target key id
+// @produce json
+// @success 200 {object} dto.AccountRes
+// @router  /api/accounts/{keyID}/disable [post]
+// @param   keyID path string true "kms key-id"
+// @param   subject body dto.FreezeReq true "subject"
+func (c *kmsCtrl) DisableAccount(ctx *fiber.Ctx) error {
+	keyIdReq, err := dto.ShouldBind[dto.KeyIdReq](ctx.ParamsParser)
+	if err != nil {
+		return err
+	}
+	freezeReq, err := dto.ShouldBind[dto.FreezeReq](ctx.BodyParser)
+	if err != nil {
+		return err
+	}
+
+	accountRes, err := c.kmsSrv.DisableAccount(ctx.UserContext(), keyIdReq, freezeReq)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(accountRes)
+}
+
+// @tags Kms
+// @summary Unfreeze account of target key id
+// @produce json
+// @success 200 {object} dto.AccountRes
+// @router  /api/accounts/{keyID}/enable [post]
+// @param   keyID path string true "kms key-id"
+// @param   subject body dto.FreezeReq true "subject"
+func (c *kmsCtrl) EnableAccount(ctx *fiber.Ctx) error {
+	keyIdReq, err := dto.ShouldBind[dto.KeyIdReq](ctx.ParamsParser)
+	if err != nil {
+		return err
+	}
+	freezeReq, err := dto.ShouldBind[dto.FreezeReq](ctx.BodyParser)
+	if err != nil {
+		return err
+	}
+
+	accountRes, err := c.kmsSrv.EnableAccount(ctx.UserContext(), keyIdReq, freezeReq)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(accountRes)
+}
+
+// @tags Kms
+// @summary Check key policy drift of target key id
+// @description Compares the key policy with the template applied at creation (or the given template)
+// @produce json
+// @success 200 {object} dto.KeyPolicyDriftRes
+// @router  /api/accounts/{keyID}/policy [get]
+// @param   keyID path string true "kms key-id"
+// @param   template query dto.KeyPolicyReq false "key policy template"
+func (c *kmsCtrl) CheckKeyPolicy(ctx *fiber.Ctx) error {
+	keyIdReq, err := dto.ShouldBind[dto.KeyIdReq](ctx.ParamsParser)
+	if err != nil {
+		return err
+	}
+	keyPolicyReq, err := dto.ShouldBind[dto.KeyPolicyReq](ctx.QueryParser)
+	if err != nil {
+		return err
+	}
+
+	driftRes, err := c.kmsSrv.CheckKeyPolicy(ctx.UserContext(), keyIdReq, keyPolicyReq.Template)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(driftRes)
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type rotationCtrl struct {
+	rotationSrv *srv.RotationSrv
+}
+
+func NewRotationCtrl(rotationSrv *srv.RotationSrv) *rotationCtrl {
+	return &rotationCtrl{rotationSrv}
+}
+
+func (c *rotationCtrl) BootStrap(router fiber.Router) {
+	router.Post("/accounts/:keyID/rotate", c.Rotate)
+	router.Get("/accounts/:keyID/rotation", c.GetRotation)
+}
+
+// @tags Rotation
+// @summary Rotate key by sweeping funds to a fresh kms key. Calling again resumes an unfinished rotation.
+// @produce json
+// @success 200 {object} dto.RotationRes
+// @router  /api/accounts/{keyID}/rotate [post]
+// @param   keyID path string true "kms key-id"
+// @param   subject body dto.RotateReq true "subject"
+func (c *rotationCtrl) Rotate(ctx *fiber.Ctx) error {
+	keyIdReq, err := dto.ShouldBind[dto.KeyIdReq](ctx.ParamsParser)
+	if err != nil {
+		return err
+	}
+	rotateReq, err := dto.ShouldBind[dto.RotateReq](ctx.BodyParser)
+	if err != nil {
+		return err
+	}
+
+	rotationRes, err := c.rotationSrv.Rotate(ctx.UserContext(), keyIdReq, rotateReq)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(rotationRes)
+}
+
+// @tags Rotation
+// @summary Get rotation progress of target key id
+// @produce json
+// @success 200 {object} dto.RotationRes
+// @router  /api/accounts/{keyID}/rotation [get]
+// @param   keyID path string true "kms key-id"
+func (c *rotationCtrl) GetRotation(ctx *fiber.Ctx) error {
+	keyIdReq, err := dto.ShouldBind[dto.KeyIdReq](ctx.ParamsParser)
+	if err != nil {
+		return err
+	}
+
+	rotationRes, err := c.rotationSrv.GetRotation(keyIdReq)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(rotationRes)
+}
@@ -17,6 +17,7 @@ func NewTxnCtrl(txnSrv *srv.TxnSrv) *txnCtrl {
 
 func (c *txnCtrl) BootStrap(router fiber.Router) {
 	router.Post("/sign/txn", c.SignSerializedTxn)
+	router.Post("/sign/txns", c.SignSerializedTxnBatch)
 }
 
 // @tags Transaction
@@ -31,10 +32,33 @@ func (c *txnCtrl) SignSerializedTxn(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	signedTxnRes, err := c.txnSrv.SignSerializedTxn(txnReq)
+	signedTxnRes, err := c.txnSrv.SignSerializedTxn(ctx.UserContext(), txnReq)
 	if err != nil {
 		return err
 	}
 
 	return ctx.Status(fiber.StatusCreated).JSON(signedTxnRes)
 }
+
+// @tags Transaction
+// @summary Sign multiple serialized transactions.
+// @description Returns 201 when every transaction is signed, 207 with per-item errors otherwise.
+// @description With allOrNothing, no signatures are returned if any item fails.
+// @produce json
+// @success 201 {object} dto.SignedTxnBatchRes
+// @success 207 {object} dto.SignedTxnBatchRes
+// @router  /api/sign/txns [post]
+// @param   subject body dto.TxnBatchReq true "subject"
+func (c *txnCtrl) SignSerializedTxnBatch(ctx *fiber.Ctx) error {
+	txnBatchReq, err := dto.ShouldBind[dto.TxnBatchReq](ctx.BodyParser)
+	if err != nil {
+		return err
+	}
+
+	signedTxnBatchRes := c.txnSrv.SignSerializedTxnBatch(ctx.UserContext(), txnBatchReq)
+	if signedTxnBatchRes.Failed > 0 {
+		return ctx.Status(fiber.StatusMultiStatus).JSON(signedTxnBatchRes)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(signedTxnBatchRes)
+}
@@ -27,16 +27,26 @@ func ShouldBind[T any](parser func(any) error) (*T, error) {
 		return nil, errs.BadRequestErr(err)
 	}
 
-	if errors := validate.Struct(&data); errors != nil {
+	if err := Validate(&data); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+// validate 태그 기준으로 검증하고, 실패하면 필드별 메세지를 담은 BadRequestErr 를 리턴
+// 메세지는 응답과 로그에 그대로 남기 때문에 요청 값(private key 등)은 넣지 않는다
+func Validate(data any) error {
+	if errors := validate.Struct(data); errors != nil {
 		errMsgs := []string{}
 		for _, err := range errors.(validator.ValidationErrors) {
 			switch err.Tag() {
 			case "required":
 				errMsgs = append(errMsgs, fmt.Sprintf("field [%s]: required", err.Field()))
 			case "lte":
-				errMsgs = append(errMsgs, fmt.Sprintf("field [%s]: got '%v' should be less than or equal to %s", err.Field(), err.Value(), err.Param()))
+				errMsgs = append(errMsgs, fmt.Sprintf("field [%s]: should be less than or equal to %s", err.Field(), err.Param()))
 			case "gte":
-				errMsgs = append(errMsgs, fmt.Sprintf("field [%s]: got '%v' should be less than or equal to %s", err.Field(), err.Value(), err.Param()))
+				errMsgs = append(errMsgs, fmt.Sprintf("field [%s]: should be greater than or equal to %s", err.Field(), err.Param()))
 			case "max":
 				errMsgs = append(errMsgs, fmt.Sprintf("field [%s]: max length is %s", err.Field(), err.Param()))
 			case "min":
@@ -44,11 +54,11 @@ func ShouldBind[T any](parser func(any) error) (*T, error) {
 			case "marker":
 				errMsgs = append(errMsgs, fmt.Sprintln("marker is invalid"))
 			default:
-				errMsgs = append(errMsgs, fmt.Sprintf("field [%s]: got '%v' need correct %s", err.Field(), err.Value(), err.Tag()))
+				errMsgs = append(errMsgs, fmt.Sprintf("field [%s]: need correct %s", err.Field(), err.Tag()))
 			}
 		}
-		return nil, (errs.BadRequestErr(fmt.Errorf(strings.Join(errMsgs, "\r\n"))))
+		return errs.BadRequestErr(fmt.Errorf(strings.Join(errMsgs, "\r\n")))
 	}
 
-	return &data, nil
+	return nil
 }
@@ -1,9 +1,45 @@
 package dto
 
+import (
+	"errors"
+	"kms/wallet/common/errs"
+)
+
 type ErrRes struct {
-	Status    int      `json:"status"`
-	Timestamp string   `json:"timestamp"`
-	Method    string   `json:"method"`
-	Path      string   `json:"path"`
-	Message   []string `json:"message"`
+	Status    int            `json:"status" example:"404"`
+	Code      string         `json:"code" example:"KEY_NOT_FOUND"` // 바뀌지 않는 에러 코드. 클라이언트는 메세지가 아닌 code 로 분기한다
+	Retryable bool           `json:"retryable" example:"false"`    // 같은 요청을 다시 보내면 성공할 수 있는지
+	Timestamp string         `json:"timestamp"`
+	Method    string         `json:"method"`
+	Path      string         `json:"path"`
+	Message   []string       `json:"message"`
+	Details   map[string]any `json:"details,omitempty"`
+	RequestID string         `json:"requestID,omitempty" example:"0d6ae3c8-0c1f-4bb4-a1ad-1e4f5b5a0c55"` // 응답 헤더의 X-Request-ID 와 같은 값
+}
+
+// batch 요청에서 개별 항목의 실패 사유
+type ItemErrRes struct {
+	Status    int            `json:"status" example:"404"`
+	Code      string         `json:"code" example:"KEY_NOT_FOUND"`
+	Retryable bool           `json:"retryable" example:"false"`
+	Message   []string       `json:"message"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+func NewItemErrRes(err error) *ItemErrRes {
+	var customErr *errs.CusErr
+	if errors.As(err, &customErr) {
+		msg := []string{customErr.Type}
+		if customErr.Kind.Status/100 == 4 && customErr.Inner != nil {
+			msg = append(msg, customErr.Inner.Error())
+		}
+		return &ItemErrRes{
+			Status:    customErr.Status(),
+			Code:      customErr.Kind.Code,
+			Retryable: customErr.Kind.Retryable,
+			Message:   msg,
+			Details:   customErr.Details,
+		}
+	}
+	return NewItemErrRes(errs.UnhandledServerErr(err))
 }
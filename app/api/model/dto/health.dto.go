@@ -0,0 +1,17 @@
+package dto
+
+type LivenessRes struct {
+	Alive bool `json:"alive" example:"true"`
+}
+
+type HealthCheckRes struct {
+	Name      string `json:"name" example:"kms"`
+	Status    string `json:"status" example:"ok" enums:"ok,fail"`
+	LatencyMs int64  `json:"latencyMs" example:"12"`
+	Error     string `json:"error,omitempty" example:"KMS_UNAVAILABLE"`
+}
+
+type ReadinessRes struct {
+	Ready  bool             `json:"ready" example:"true"`
+	Checks []HealthCheckRes `json:"checks"`
+}
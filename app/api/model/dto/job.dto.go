@@ -0,0 +1,24 @@
+package dto
+
+// req
+type SignJobReq struct {
+	TxnBatchReq
+	CallbackURL string `json:"callbackURL" validate:"omitempty,url,max=2048" example:"https://example.com/hooks/kms"` // 작업이 끝나면 HMAC 서명된 결과를 POST 로 전달한다
+}
+
+type JobIdReq struct {
+	ID string `json:"id" validate:"required,uuid" example:"0d6ae3c8-0c1f-4bb4-a1ad-1e4f5b5a0c55"`
+}
+
+// res
+type JobRes struct {
+	ID          string             `json:"id" example:"0d6ae3c8-0c1f-4bb4-a1ad-1e4f5b5a0c55"`
+	Type        string             `json:"type" example:"sign"`
+	Status      string             `json:"status" example:"succeeded"` // queued, running, succeeded, failed
+	Attempts    int                `json:"attempts" example:"1"`
+	LastError   string             `json:"lastError,omitempty"`
+	Result      *SignedTxnBatchRes `json:"result,omitempty"`
+	CallbackErr string             `json:"callbackErr,omitempty"`
+	CreatedAt   string             `json:"createdAt" example:"2024-01-02_15:04:05"`
+	UpdatedAt   string             `json:"updatedAt" example:"2024-01-02_15:04:05"`
+}
@@ -0,0 +1,26 @@
+package dto
+
+// req
+type RotateReq struct {
+	Actor string `json:"actor" validate:"required,min=1,max=256" example:"security-oncall"`
+}
+
+// res
+type SweepRes struct {
+	Asset  string `json:"asset" example:"native"`
+	Amount string `json:"amount" example:"1000000000000000000"`
+	TxHash string `json:"txHash" example:"0x6c5f5b9b1c0f0ad2b5d0fd61d0ba7b0bc2d1d6a35bd5aab8d0a8a57ab1cf1a3e"`
+	Sent   bool   `json:"sent" example:"true"`
+}
+
+type RotationRes struct {
+	OldKeyID   string     `json:"oldKeyID" example:"f50a9229-e7c7-45ba-b06c-8036b894424e"`
+	OldAddress string     `json:"oldAddress" example:"0x216690cD286d8a9c8D39d9714263bB6AB97046F3"`
+	NewKeyID   string     `json:"newKeyID" example:"76eab66d-7bfd-49ac-827e-f9e04aed098e"`
+	NewAddress string     `json:"newAddress" example:"0x39e243a7f209932df41e1fc0a1ada51b3a04b46d"`
+	Step       string     `json:"step" example:"retired"`
+	Sweeps     []SweepRes `json:"sweeps"`
+	StartedAt  string     `json:"startedAt" example:"2024-01-16_14:54:21"`
+	UpdatedAt  string     `json:"updatedAt" example:"2024-01-16_14:54:25"`
+	LastError  string     `json:"lastError,omitempty"`
+}
@@ -6,7 +6,27 @@ type TxnReq struct {
 	SerializedTxn string `json:"serializedTxn" validate:"required,hexadecimal" example:"0xea5685ba43b740008252089439e243a7f209932df41e1fc0a1ada51b3a04b46d018086059407ad8e8b8080"`
 }
 
+// 각 항목은 서비스에서 개별적으로 검증해서 항목별 에러로 리턴한다
+type TxnBatchReq struct {
+	Txns         []TxnReq `json:"txns" validate:"required,min=1,max=500"`
+	AllOrNothing bool     `json:"allOrNothing" example:"false"` // 하나라도 실패하면 서명을 하나도 리턴하지 않는다
+}
+
 // res
 type SingedTxnRes struct {
 	SignedTxn string `json:"signedTxn" example:"0xf86a5685ba43b740008252089439e243a7f209932df41e1fc0a1ada51b3a04b46d0180860b280f5b1d3aa00d2ea43cfd9b91151348d037a5a80293f543e1700a7019853f28063f6442c826a052a29797169740b1bc48962e197299061c8aa3314951a9c71418d19036604645"`
 }
+
+type SignedTxnBatchItemRes struct {
+	Index     int         `json:"index" example:"0"`
+	KeyID     string      `json:"keyID" example:"f50a9229-e7c7-45ba-b06c-8036b894424e"`
+	SignedTxn string      `json:"signedTxn,omitempty"`
+	Error     *ItemErrRes `json:"error,omitempty"`
+}
+
+type SignedTxnBatchRes struct {
+	Succeeded int                     `json:"succeeded" example:"99"`
+	Failed    int                     `json:"failed" example:"1"`
+	Aborted   bool                    `json:"aborted" example:"false"` // allOrNothing 모드에서 실패한 항목이 있어 서명을 버린 경우
+	Results   []SignedTxnBatchItemRes `json:"results"`
+}
@@ -0,0 +1,171 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kms/wallet/app/api/model/dto"
+	"kms/wallet/common/audit"
+	"kms/wallet/common/errs"
+	"math/big"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	defaultHealthCheckTimeout = 3 * time.Second
+	healthStatusOk            = "ok"
+	healthStatusFail          = "fail"
+)
+
+// readiness 에서 서명하는 고정된 메세지
+var canaryDigest = crypto.Keccak256([]byte("kms/wallet readiness canary"))
+
+type HealthSrvConfig struct {
+	CanaryKeyID string        // 설정하면 이 키로 canaryDigest 에 서명하고 검증한다
+	RPCURLs     []string      // 연결과 chain id 를 확인할 rpc 주소
+	ChainID     *big.Int      // rpc 의 chain id 와 비교한다. nil 이면 비교하지 않는다
+	Timeout     time.Duration // 항목별 제한시간
+}
+
+type HealthSrv struct {
+	kmsSrv *KmsSrv
+	config HealthSrvConfig
+}
+
+func NewHealthSrv(kmsSrv *KmsSrv, config HealthSrvConfig) *HealthSrv {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultHealthCheckTimeout
+	}
+	return &HealthSrv{kmsSrv, config}
+}
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// 의존하는 외부 시스템(kms, rpc, audit 파일)을 동시에 확인한다. 하나라도 실패하면 Ready 는 false
+func (s *HealthSrv) Readiness(ctx context.Context) *dto.ReadinessRes {
+	checks := []healthCheck{{"kms", s.checkKms}}
+	if s.config.CanaryKeyID != "" {
+		checks = append(checks, healthCheck{"kms_canary", s.checkCanary})
+	}
+	for _, rpcURL := range s.config.RPCURLs {
+		rpcURL := rpcURL
+		checks = append(checks, healthCheck{"rpc:" + rpcHost(rpcURL), func(ctx context.Context) error {
+			return s.checkRPC(ctx, rpcURL)
+		}})
+	}
+	checks = append(checks, healthCheck{"audit", func(context.Context) error { return audit.Check() }})
+
+	var (
+		readinessRes = &dto.ReadinessRes{Ready: true, Checks: make([]dto.HealthCheckRes, len(checks))}
+		wg           sync.WaitGroup
+	)
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check healthCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.check(checkCtx)
+			readinessRes.Checks[i] = dto.HealthCheckRes{Name: check.name, Status: healthStatusOk, LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				readinessRes.Checks[i].Status = healthStatusFail
+				readinessRes.Checks[i].Error = healthErr(err)
+			}
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, check := range readinessRes.Checks {
+		if check.Status != healthStatusOk {
+			readinessRes.Ready = false
+		}
+	}
+	return readinessRes
+}
+
+// 자격증명, 권한, 네트워크를 가장 저렴한 호출로 확인한다
+func (s *HealthSrv) checkKms(ctx context.Context) error {
+	_, err := s.kmsSrv.client.ListKeys(ctx, &kms.ListKeysInput{Limit: aws.Int32(1)})
+	if err != nil {
+		return errs.RouteAwsErr(err)
+	}
+	return nil
+}
+
+// 서명 권한과 키 상태까지 확인한다
+func (s *HealthSrv) checkCanary(ctx context.Context) error {
+	R, S, err := s.kmsSrv.Sign(ctx, s.config.CanaryKeyID, canaryDigest)
+	if err != nil {
+		return err
+	}
+	pubKey, err := s.kmsSrv.GetPubkey(ctx, &dto.KeyIdReq{KeyID: s.config.CanaryKeyID})
+	if err != nil {
+		return err
+	}
+
+	// VerifySignature 는 S 가 타원곡선 최댓값의 절반보다 큰 서명을 거부한다.
+	// der 인코딩된 R, S 는 앞에 0x00 이 붙어 33 바이트일 수 있기 때문에 정수로 바꿔서 32 바이트로 맞춘다
+	secp256k1n := crypto.S256().Params().N
+	rBigInt, sBigInt := new(big.Int).SetBytes(R), new(big.Int).SetBytes(S)
+	if sBigInt.Cmp(new(big.Int).Rsh(secp256k1n, 1)) > 0 {
+		sBigInt.Sub(secp256k1n, sBigInt)
+	}
+	signature := append(rBigInt.FillBytes(make([]byte, 32)), sBigInt.FillBytes(make([]byte, 32))...)
+	if !crypto.VerifySignature(pubKey, canaryDigest, signature) {
+		return errors.New("canary signature does not match the public key")
+	}
+	return nil
+}
+
+func (s *HealthSrv) checkRPC(ctx context.Context, rpcURL string) error {
+	client, err := ethclient.DialContext(ctx, rpcURL)
+	if err != nil {
+		return hideURL(err, rpcURL)
+	}
+	defer client.Close()
+
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return hideURL(err, rpcURL)
+	}
+	if s.config.ChainID != nil && chainID.Cmp(s.config.ChainID) != 0 {
+		return fmt.Errorf("rpc chain id %v does not match CHAIN_ID %v", chainID, s.config.ChainID)
+	}
+	return nil
+}
+
+// rpc 주소의 path, query 에는 api key 가 들어있을 수 있기 때문에 host 만 보여준다
+func rpcHost(rpcURL string) string {
+	parsed, err := url.Parse(rpcURL)
+	if err != nil || parsed.Host == "" {
+		return "invalid"
+	}
+	return parsed.Host
+}
+
+// http 에러 메세지에 포함된 rpc 주소를 host 로 바꾼다
+func hideURL(err error, rpcURL string) error {
+	return errors.New(strings.ReplaceAll(err.Error(), rpcURL, rpcHost(rpcURL)))
+}
+
+// kms 에러는 aws 메세지(arn, 계정 id 포함) 대신 에러 코드만 보여준다
+func healthErr(err error) string {
+	var customErr *errs.CusErr
+	if errors.As(err, &customErr) {
+		return customErr.Kind.Code
+	}
+	return err.Error()
+}
@@ -0,0 +1,116 @@
+package srv
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"kms/wallet/app/api/model/dto"
+	"kms/wallet/app/tracing"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/utils/keyutil"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const hardenedKeyStart = 0x80000000
+
+// Web3 keystore v3 json 을 복호화해서 주입
+func (s *KmsSrv) ImportKeystore(ctx context.Context, keystoreDTO *dto.KeystoreImportReq) (_ *dto.ImportAccountRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.ImportKeystore")
+	defer func() { tracing.End(span, err) }()
+
+	ecdsaPK, err := keyutil.DecryptKeystore([]byte(keystoreDTO.Keystore), keystoreDTO.Passphrase)
+	if err != nil {
+		return nil, errs.BadRequestErr(err)
+	}
+
+	return s.importDerivedKey(ctx, ecdsaPK, "", keystoreDTO.ExpectedAddress, keystoreDTO.DryRun)
+}
+
+// SEC1 혹은 PKCS#8 PEM 을 파싱해서 주입
+func (s *KmsSrv) ImportPem(ctx context.Context, pemDTO *dto.PemImportReq) (_ *dto.ImportAccountRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.ImportPem")
+	defer func() { tracing.End(span, err) }()
+
+	ecdsaPK, err := keyutil.ParsePEM([]byte(pemDTO.Pem))
+	if err != nil {
+		return nil, errs.BadRequestErr(err)
+	}
+
+	return s.importDerivedKey(ctx, ecdsaPK, "", pemDTO.ExpectedAddress, pemDTO.DryRun)
+}
+
+// BIP-39 니모닉에서 base 경로 아래 index 범위의 키들을 유도해서 주입
+// 일부 index 의 주입이 실패해도 나머지는 계속 진행하고 항목별 결과를 리턴한다
+func (s *KmsSrv) ImportMnemonic(ctx context.Context, mnemonicDTO *dto.MnemonicImportReq) (_ *dto.ImportAccountListRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.ImportMnemonic")
+	defer func() { tracing.End(span, err) }()
+
+	basePath := accounts.DefaultRootDerivationPath
+	if mnemonicDTO.Path != "" {
+		var err error
+		if basePath, err = accounts.ParseDerivationPath(mnemonicDTO.Path); err != nil {
+			return nil, errs.BadRequestErr(err)
+		}
+	}
+	count := mnemonicDTO.Count
+	if count == 0 {
+		count = 1
+	}
+	// index 가 hardened 범위(2^31 이상)로 넘어가면 요청과 다른 경로의 키가 유도된다
+	if uint64(mnemonicDTO.StartIndex)+uint64(count) > hardenedKeyStart {
+		return nil, errs.BadRequestErr(fmt.Errorf("startIndex %v + count %v exceeds the non-hardened index range", mnemonicDTO.StartIndex, count))
+	}
+
+	// 주입 전에 모든 키를 먼저 유도해서 니모닉 오류를 걸러낸다
+	var (
+		paths = make([]accounts.DerivationPath, count)
+		keys  = make([]*ecdsa.PrivateKey, count)
+	)
+	for i := range keys {
+		paths[i] = append(append(accounts.DerivationPath{}, basePath...), mnemonicDTO.StartIndex+uint32(i))
+		ecdsaPK, err := keyutil.DeriveFromMnemonic(mnemonicDTO.Mnemonic, mnemonicDTO.Passphrase, paths[i])
+		if err != nil {
+			return nil, errs.BadRequestErr(err)
+		}
+		keys[i] = ecdsaPK
+	}
+
+	importListRes := &dto.ImportAccountListRes{Accounts: make([]dto.ImportAccountRes, count)}
+	for i, ecdsaPK := range keys {
+		importRes, err := s.importDerivedKey(ctx, ecdsaPK, paths[i].String(), "", mnemonicDTO.DryRun)
+		if err != nil {
+			importListRes.Accounts[i] = dto.ImportAccountRes{
+				DerivedAddress: crypto.PubkeyToAddress(ecdsaPK.PublicKey).String(),
+				Path:           paths[i].String(),
+				Error:          dto.NewItemErrRes(err),
+			}
+			continue
+		}
+		importListRes.Accounts[i] = *importRes
+	}
+
+	return importListRes, nil
+}
+
+func (s *KmsSrv) importDerivedKey(ctx context.Context, ecdsaPK *ecdsa.PrivateKey, path string, expectedAddr string, dryRun bool) (*dto.ImportAccountRes, error) {
+	importRes := &dto.ImportAccountRes{
+		DerivedAddress: crypto.PubkeyToAddress(ecdsaPK.PublicKey).String(),
+		Path:           path,
+	}
+	if dryRun {
+		if expectedAddr != "" && common.HexToAddress(expectedAddr).String() != importRes.DerivedAddress {
+			return nil, errs.AddressMismatchErr(fmt.Errorf("derived address %v does not match expected address %v", importRes.DerivedAddress, expectedAddr))
+		}
+		return importRes, nil
+	}
+
+	accountRes, err := s.importPrivateKey(ctx, ecdsaPK, expectedAddr)
+	if err != nil {
+		return nil, err
+	}
+	importRes.Account = accountRes
+	return importRes, nil
+}
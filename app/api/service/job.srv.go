@@ -0,0 +1,384 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"kms/wallet/app/api/model/dto"
+	"kms/wallet/app/store"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"kms/wallet/common/utils/timeutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultJobWorkers     = 4
+	defaultJobMaxAttempts = 5
+	defaultJobRetryDelay  = time.Second
+	maxJobRetryDelay      = 5 * time.Minute
+	webhookAttempts       = 3
+)
+
+type JobSrvConfig struct {
+	Workers        int
+	MaxAttempts    int           // kms 일시적 에러로 실패한 항목을 다시 시도하는 최대 횟수
+	RetryBaseDelay time.Duration // 재시도 간격은 시도할 때마다 두배로 늘어난다
+	WebhookSecret  string        // 콜백 payload 의 HMAC-SHA256 서명 키
+	HTTPClient     *http.Client
+}
+
+// 오래 걸리는 서명 요청을 비동기로 처리한다. 작업은 store 에 기록되어 재시작 이후에도 이어서 처리된다
+type JobSrv struct {
+	txnSrv   *TxnSrv
+	store    store.JobStore
+	config   JobSrvConfig
+	queue    chan string
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewJobSrv(txnSrv *TxnSrv, jobStore store.JobStore, config JobSrvConfig) *JobSrv {
+	if config.Workers <= 0 {
+		config.Workers = defaultJobWorkers
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultJobMaxAttempts
+	}
+	if config.RetryBaseDelay <= 0 {
+		config.RetryBaseDelay = defaultJobRetryDelay
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &JobSrv{
+		txnSrv: txnSrv,
+		store:  jobStore,
+		config: config,
+		queue:  make(chan string),
+		stop:   make(chan struct{}),
+	}
+}
+
+// 끝나지 않은 작업을 다시 큐에 넣고 worker 를 실행
+func (s *JobSrv) Start() error {
+	jobs, err := s.store.ListUnfinished()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		// 실행 도중 중단된 작업은 처음부터 다시 대기 상태로 돌린다
+		if job.Status == store.JobStatusRunning {
+			job.Status = store.JobStatusQueued
+			if err := s.store.Save(job); err != nil {
+				return err
+			}
+		}
+		s.schedule(job.ID, job.NextRunAt)
+	}
+	if len(jobs) > 0 {
+		logger.Info().D("jobs", len(jobs)).W("resumed unfinished jobs")
+	}
+
+	for i := 0; i < s.config.Workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	return nil
+}
+
+// 새 작업을 더이상 꺼내지 않고, 처리중인 작업이 끝날때까지 기다린다
+func (s *JobSrv) Stop() {
+	s.Shutdown(context.Background())
+}
+
+// Stop 과 같지만 ctx 가 끝나면 더 기다리지 않는다. 여러번 호출해도 된다.
+// 끝나지 않은 작업은 running 상태로 남아있다가 다음 Start 에서 다시 처리된다
+func (s *JobSrv) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *JobSrv) EnqueueSignJob(ctx context.Context, signJobDTO *dto.SignJobReq) (*dto.JobRes, error) {
+	if signJobDTO.CallbackURL != "" && s.config.WebhookSecret == "" {
+		return nil, errs.BadRequestErr(fmt.Errorf("callbackURL requires a webhook secret to be configured"))
+	}
+
+	request, err := json.Marshal(signJobDTO.TxnBatchReq)
+	if err != nil {
+		return nil, errs.InternalServerErr(err)
+	}
+
+	now := time.Now()
+	job := store.Job{
+		ID:          uuid.NewString(),
+		Type:        store.JobTypeSign,
+		Status:      store.JobStatusQueued,
+		Request:     request,
+		RequestID:   logger.RequestID(ctx),
+		CallbackURL: signJobDTO.CallbackURL,
+		NextRunAt:   now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.store.Save(job); err != nil {
+		return nil, errs.InternalServerErr(err)
+	}
+	s.schedule(job.ID, job.NextRunAt)
+
+	return toJobRes(job), nil
+}
+
+func (s *JobSrv) GetJob(jobIdDTO *dto.JobIdReq) (*dto.JobRes, error) {
+	job, ok, err := s.store.Get(jobIdDTO.ID)
+	if err != nil {
+		return nil, errs.InternalServerErr(err)
+	}
+	if !ok {
+		return nil, errs.JobNotFoundErr(fmt.Errorf("job '%v' not found", jobIdDTO.ID))
+	}
+	return toJobRes(job), nil
+}
+
+func (s *JobSrv) schedule(id string, at time.Time) {
+	time.AfterFunc(max(time.Until(at), 0), func() {
+		select {
+		case s.queue <- id:
+		case <-s.stop:
+		}
+	})
+}
+
+func (s *JobSrv) work() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case id := <-s.queue:
+			if err := s.process(id); err != nil {
+				logger.Error().E(err).D("jobID", id).W("failed to process job")
+			}
+		}
+	}
+}
+
+func (s *JobSrv) process(id string) error {
+	job, ok, err := s.store.Get(id)
+	if err != nil || !ok || job.Status != store.JobStatusQueued {
+		return err
+	}
+
+	job.Status = store.JobStatusRunning
+	job.Attempts++
+	job.UpdatedAt = time.Now()
+	if err := s.store.Save(job); err != nil {
+		return err
+	}
+
+	// 작업은 요청과 무관하게 실행되기 때문에 kms 호출 제한시간만 적용된다. 로그와 kms 호출에는 등록한 요청의 id 를 남긴다
+	ctx := logger.WithRequestID(context.Background(), job.RequestID)
+
+	var txnBatchReq dto.TxnBatchReq
+	if err := json.Unmarshal(job.Request, &txnBatchReq); err != nil {
+		return s.finish(job, store.JobStatusFailed, err.Error())
+	}
+	var prev *dto.SignedTxnBatchRes
+	if job.Result != nil {
+		prev = new(dto.SignedTxnBatchRes)
+		if err := json.Unmarshal(job.Result, prev); err != nil {
+			return s.finish(job, store.JobStatusFailed, err.Error())
+		}
+	}
+
+	batchRes := s.runSignJob(ctx, &txnBatchReq, prev)
+	if job.Result, err = json.Marshal(batchRes); err != nil {
+		return s.finish(job, store.JobStatusFailed, err.Error())
+	}
+
+	if transient := countTransient(batchRes.Results); transient > 0 {
+		lastError := fmt.Sprintf("%v items failed with transient errors", transient)
+		if job.Attempts < s.config.MaxAttempts {
+			job.Status = store.JobStatusQueued
+			job.LastError = lastError
+			job.NextRunAt = time.Now().Add(s.retryDelay(job.Attempts))
+			job.UpdatedAt = time.Now()
+			if err := s.store.Save(job); err != nil {
+				return err
+			}
+			s.schedule(job.ID, job.NextRunAt)
+			return nil
+		}
+		return s.finish(job, store.JobStatusFailed, lastError)
+	}
+
+	if batchRes.Failed > 0 {
+		return s.finish(job, store.JobStatusFailed, fmt.Sprintf("%v items failed", batchRes.Failed))
+	}
+	return s.finish(job, store.JobStatusSucceeded, "")
+}
+
+// 이전 시도에서 일시적 에러로 실패한 항목만 다시 서명한다. allOrNothing 이면 전체를 다시 서명한다
+func (s *JobSrv) runSignJob(ctx context.Context, txnBatchReq *dto.TxnBatchReq, prev *dto.SignedTxnBatchRes) *dto.SignedTxnBatchRes {
+	if prev == nil || txnBatchReq.AllOrNothing {
+		return s.txnSrv.SignSerializedTxnBatch(ctx, txnBatchReq)
+	}
+
+	var (
+		indexes  []int
+		retryReq = &dto.TxnBatchReq{}
+	)
+	for i, result := range prev.Results {
+		if isTransient(result.Error) {
+			indexes = append(indexes, i)
+			retryReq.Txns = append(retryReq.Txns, txnBatchReq.Txns[i])
+		}
+	}
+
+	retryRes := s.txnSrv.SignSerializedTxnBatch(ctx, retryReq)
+	for j, result := range retryRes.Results {
+		result.Index = indexes[j]
+		prev.Results[indexes[j]] = result
+	}
+	return newSignedTxnBatchRes(prev.Results, false)
+}
+
+func (s *JobSrv) retryDelay(attempts int) time.Duration {
+	delay := s.config.RetryBaseDelay << (attempts - 1)
+	if delay <= 0 || delay > maxJobRetryDelay {
+		return maxJobRetryDelay
+	}
+	return delay
+}
+
+func (s *JobSrv) finish(job store.Job, status string, lastError string) error {
+	job.Status = status
+	job.LastError = lastError
+	job.UpdatedAt = time.Now()
+	if err := s.store.Save(job); err != nil {
+		return err
+	}
+	ctx := logger.WithRequestID(context.Background(), job.RequestID)
+	logger.Info().Ctx(ctx).D("jobID", job.ID).D("status", status).D("attempts", job.Attempts).W("job finished")
+
+	if job.CallbackURL == "" {
+		return nil
+	}
+	if err := s.notify(job); err != nil {
+		job.CallbackErr = err.Error()
+		logger.Warn().Ctx(ctx).E(err).D("jobID", job.ID).W("job callback failed")
+	} else {
+		job.CallbackDone = true
+	}
+	return s.store.Save(job)
+}
+
+// 작업 결과를 callbackURL 로 전달한다.
+// X-Signature 헤더는 "<X-Signature-Timestamp>.<body>" 의 HMAC-SHA256 (hex)
+func (s *JobSrv) notify(job store.Job) error {
+	body, err := json.Marshal(toJobRes(job))
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; attempt < webhookAttempts; attempt++ {
+		// 종료중이면 재시도를 기다리지 않는다
+		if attempt > 0 {
+			select {
+			case <-time.After(s.config.RetryBaseDelay << (attempt - 1)):
+			case <-s.stop:
+				return fmt.Errorf("job service stopped before the callback was delivered: %w", err)
+			}
+		}
+		err = s.postCallback(job, body)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// 콜백을 한번 보낸다. 2xx 가 아닌 응답은 에러
+func (s *JobSrv) postCallback(job store.Job, body []byte) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, job.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Job-ID", job.ID)
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+	req.Header.Set("X-Signature", "sha256="+SignWebhook(s.config.WebhookSecret, timestamp, body))
+
+	res, err := s.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("callback responded with status %v", res.StatusCode)
+	}
+	return nil
+}
+
+// 콜백 수신측에서 같은 방식으로 서명을 만들어 X-Signature 와 비교한다
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// kms 장애, 요청 한도 초과 등은 다시 시도하면 성공할 수 있다
+func isTransient(itemErr *dto.ItemErrRes) bool {
+	return itemErr != nil && itemErr.Retryable
+}
+
+func countTransient(results []dto.SignedTxnBatchItemRes) (transient int) {
+	for _, result := range results {
+		if isTransient(result.Error) {
+			transient++
+		}
+	}
+	return transient
+}
+
+func toJobRes(job store.Job) *dto.JobRes {
+	jobRes := &dto.JobRes{
+		ID:          job.ID,
+		Type:        job.Type,
+		Status:      job.Status,
+		Attempts:    job.Attempts,
+		LastError:   job.LastError,
+		CallbackErr: job.CallbackErr,
+		CreatedAt:   job.CreatedAt.Format(timeutil.DateFormat),
+		UpdatedAt:   job.UpdatedAt.Format(timeutil.DateFormat),
+	}
+	if job.Result != nil {
+		jobRes.Result = new(dto.SignedTxnBatchRes)
+		if err := json.Unmarshal(job.Result, jobRes.Result); err != nil {
+			jobRes.Result = nil
+		}
+	}
+	return jobRes
+}
@@ -0,0 +1,194 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+
+	"kms/wallet/app/api/model/dto"
+	"kms/wallet/app/tracing"
+	"kms/wallet/common/errs"
+)
+
+// 키를 만들 때 적용한 key policy 템플릿 이름을 남기는 태그. drift 를 확인할 때 비교할 템플릿을 찾는다
+const keyPolicyTag = "KeyPolicyTemplate"
+
+// placeholder 를 채운 key policy 템플릿들
+type KeyPolicies struct {
+	Default   string            // 새 키에 적용할 템플릿. 비어있으면 kms 의 기본 key policy
+	Documents map[string]string // 이름별 policy json
+}
+
+// 새 키(생성, 주입)에 policies.Default 를 적용하고 CheckKeyPolicy 에서 템플릿과 비교한다
+func WithKeyPolicies(policies KeyPolicies) KmsSrvOption {
+	return func(s *KmsSrv) {
+		s.keyPolicies = policies
+	}
+}
+
+// CreateKey 요청에 기본 템플릿의 policy 와 템플릿 태그를 더한다. 같은 태그가 있으면 덮어쓴다 (ex. 복제한 키)
+func (s *KmsSrv) withKeyPolicy(input *kms.CreateKeyInput) *kms.CreateKeyInput {
+	name := s.keyPolicies.Default
+	if name == "" {
+		return input
+	}
+	input.Policy = aws.String(s.keyPolicies.Documents[name])
+	tags := []types.Tag{{TagKey: aws.String(keyPolicyTag), TagValue: aws.String(name)}}
+	for _, tag := range input.Tags {
+		if aws.ToString(tag.TagKey) != keyPolicyTag {
+			tags = append(tags, tag)
+		}
+	}
+	input.Tags = tags
+	return input
+}
+
+// 키를 만들 때 적용한 템플릿 이름을 tag 에서 찾는다. tag 는 여러 페이지로 나눠서 올 수 있다
+func (s *KmsSrv) keyPolicyTemplate(ctx context.Context, keyID string) (string, error) {
+	tagsInput := &kms.ListResourceTagsInput{KeyId: aws.String(keyID)}
+	for {
+		tagsOutput, err := s.client.ListResourceTags(ctx, tagsInput)
+		if err != nil {
+			return "", errs.RouteAwsErr(err)
+		}
+		for _, tag := range tagsOutput.Tags {
+			if aws.ToString(tag.TagKey) == keyPolicyTag {
+				return aws.ToString(tag.TagValue), nil
+			}
+		}
+		if !tagsOutput.Truncated {
+			return "", nil
+		}
+		tagsInput.Marker = tagsOutput.NextMarker
+	}
+}
+
+// 키의 key policy 를 템플릿과 비교한다. template 이 비어있으면 키를 만들 때 적용한 템플릿, 그것도 없으면 기본 템플릿과 비교한다
+func (s *KmsSrv) CheckKeyPolicy(ctx context.Context, keyIdDTO *dto.KeyIdReq, template string) (_ *dto.KeyPolicyDriftRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.CheckKeyPolicy", tracing.KeyID(keyIdDTO.KeyID))
+	defer func() { tracing.End(span, err) }()
+
+	if template == "" {
+		template, err = s.keyPolicyTemplate(ctx, keyIdDTO.KeyID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if template == "" {
+		template = s.keyPolicies.Default
+	}
+	if template == "" {
+		return nil, errs.BadRequestErr(fmt.Errorf("no key policy template is configured"))
+	}
+	expected, ok := s.keyPolicies.Documents[template]
+	if !ok {
+		return nil, errs.BadRequestErr(fmt.Errorf("unknown key policy template '%v'", template))
+	}
+
+	policy, err := s.client.GetKeyPolicy(ctx, &kms.GetKeyPolicyInput{
+		KeyId:      aws.String(keyIdDTO.KeyID),
+		PolicyName: aws.String("default"), // key 마다 default 하나만 있다
+	})
+	if err != nil {
+		return nil, errs.RouteAwsErr(err)
+	}
+
+	driftRes, err := diffKeyPolicy(expected, aws.ToString(policy.Policy))
+	if err != nil {
+		return nil, errs.InternalServerErr(err)
+	}
+	driftRes.KeyID = keyIdDTO.KeyID
+	driftRes.Template = template
+	return driftRes, nil
+}
+
+// statement 를 Sid(없으면 순서)로 짝지어 비교한다. 공백, 목록 순서, 한개짜리 목록과 값의 차이는 무시한다
+func diffKeyPolicy(expected, actual string) (*dto.KeyPolicyDriftRes, error) {
+	var expectedDoc, actualDoc map[string]any
+	if err := json.Unmarshal([]byte(expected), &expectedDoc); err != nil {
+		return nil, fmt.Errorf("invalid template policy: %w", err)
+	}
+	if err := json.Unmarshal([]byte(actual), &actualDoc); err != nil {
+		return nil, fmt.Errorf("invalid key policy: %w", err)
+	}
+
+	driftRes := &dto.KeyPolicyDriftRes{}
+	expectedStatements, actualStatements := policyStatements(expectedDoc), policyStatements(actualDoc)
+	for sid, statement := range expectedStatements {
+		if actualStatement, ok := actualStatements[sid]; !ok {
+			driftRes.Missing = append(driftRes.Missing, sid)
+		} else if !reflect.DeepEqual(normalizePolicy(statement), normalizePolicy(actualStatement)) {
+			driftRes.Changed = append(driftRes.Changed, sid)
+		}
+	}
+	for sid := range actualStatements {
+		if _, ok := expectedStatements[sid]; !ok {
+			driftRes.Unexpected = append(driftRes.Unexpected, sid)
+		}
+	}
+	delete(expectedDoc, "Statement")
+	delete(actualDoc, "Statement")
+	delete(expectedDoc, "Id") // kms 가 정하는 값
+	delete(actualDoc, "Id")
+	if !reflect.DeepEqual(normalizePolicy(expectedDoc), normalizePolicy(actualDoc)) {
+		driftRes.Changed = append(driftRes.Changed, "Version")
+	}
+
+	sort.Strings(driftRes.Missing)
+	sort.Strings(driftRes.Unexpected)
+	sort.Strings(driftRes.Changed)
+	driftRes.Drifted = len(driftRes.Missing)+len(driftRes.Unexpected)+len(driftRes.Changed) > 0
+	return driftRes, nil
+}
+
+func policyStatements(doc map[string]any) map[string]any {
+	var list []any
+	switch statement := doc["Statement"].(type) {
+	case []any:
+		list = statement
+	case map[string]any:
+		list = []any{statement}
+	}
+
+	statements := make(map[string]any, len(list))
+	for i, statement := range list {
+		sid := fmt.Sprintf("#%d", i)
+		if m, ok := statement.(map[string]any); ok {
+			if id, ok := m["Sid"].(string); ok && id != "" {
+				sid = id
+			}
+		}
+		statements[sid] = statement
+	}
+	return statements
+}
+
+func normalizePolicy(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		normalized := make(map[string]any, len(v))
+		for k, inner := range v {
+			normalized[k] = normalizePolicy(inner)
+		}
+		return normalized
+	case []any:
+		if len(v) == 1 {
+			return normalizePolicy(v[0])
+		}
+		normalized := make([]string, 0, len(v))
+		for _, inner := range v {
+			data, _ := json.Marshal(normalizePolicy(inner))
+			normalized = append(normalized, string(data))
+		}
+		sort.Strings(normalized)
+		return normalized
+	default:
+		return value
+	}
+}
@@ -3,22 +3,33 @@ package srv
 import (
 	"context"
 	"crypto/ecdsa"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
-	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/secp256k1"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
 
 	"kms/wallet/app/api/model/dto"
 	"kms/wallet/app/cache"
+	"kms/wallet/app/kmsclient"
+	"kms/wallet/app/store"
+	"kms/wallet/app/tracing"
+	"kms/wallet/common/audit"
 	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"kms/wallet/common/utils/keyutil"
+	"kms/wallet/common/utils/timeutil"
 )
 
 type ans1PubKeyInfoFormat struct {
@@ -36,62 +47,189 @@ type asn1SigFormat struct {
 	S asn1.RawValue
 }
 
-type asn1PKFormat struct {
-	Version       int
-	PrivateKey    []byte
-	NamedCurveOID asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
-	PublicKey     asn1.BitString        `asn1:"optional,explicit,tag:1"`
+type KmsSrv struct {
+	client      kmsclient.Client
+	pubKeyCache *cache.PubKeyCache
+	freezeStore *store.FreezeStore
+	pubKeyGroup singleflight.Group // 같은 keyID 에 대한 동시 GetPublicKey 호출을 하나로 합친다
+	replicas    []ReplicaRegion    // 다중 리전 키를 복제하고 서명을 넘길 region
+	keyPolicies KeyPolicies
 }
 
-type pkcs8Asn1PKFormat struct {
-	Version    int
-	Algo       pkix.AlgorithmIdentifier
-	PrivateKey []byte
+type KmsSrvOption func(*KmsSrv)
+
+// 기본 설정(크기 제한, 만료 없음, 디스크 저장 없음) 대신 사용할 public key 캐시
+func WithPubKeyCache(pubKeyCache *cache.PubKeyCache) KmsSrvOption {
+	return func(s *KmsSrv) {
+		s.pubKeyCache = pubKeyCache
+	}
 }
 
-type KmsSrv struct {
-	client      *kms.Client
-	pubKeyCache *cache.PubKeyCache
+// 기본 설정(메모리에만 보관) 대신 사용할 동결 기록 저장소
+func WithFreezeStore(freezeStore *store.FreezeStore) KmsSrvOption {
+	return func(s *KmsSrv) {
+		s.freezeStore = freezeStore
+	}
 }
 
-func NewKmsSrv(kmsClient *kms.Client) *KmsSrv {
-	return &KmsSrv{kmsClient, cache.NewPubKeyCache()}
+func NewKmsSrv(kmsClient kmsclient.Client, opts ...KmsSrvOption) *KmsSrv {
+	pubKeyCache, _ := cache.NewPubKeyCache(cache.PubKeyCacheConfig{}) // 스냅샷 경로가 없으면 에러가 발생하지 않는다
+	freezeStore, _ := store.NewFreezeStore("")                        // 경로가 없으면 에러가 발생하지 않는다
+	s := &KmsSrv{client: kmsClient, pubKeyCache: pubKeyCache, freezeStore: freezeStore}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
+func (s *KmsSrv) PubKeyCacheStats() cache.PubKeyCacheStats {
+	return s.pubKeyCache.Stats()
+}
+
+const (
+	accountBatchConcurrency = 5
+)
+
 // 새로운 계정 생성
-func (s *KmsSrv) CreateAccount() (*dto.AccountRes, error) {
-	key, err := s.client.CreateKey(context.TODO(), &kms.CreateKeyInput{
-		KeyUsage: types.KeyUsageTypeSignVerify,
-		KeySpec:  types.KeySpecEccSecgP256k1,
+func (s *KmsSrv) CreateAccount(ctx context.Context, opts ...CreateAccountOption) (_ *dto.AccountRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.CreateAccount")
+	defer func() { tracing.End(span, err) }()
+
+	var options createAccountOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.replicate && len(s.replicas) == 0 {
+		return nil, errs.BadRequestErr(fmt.Errorf("no replica region is configured"))
+	}
+	return s.createAccount(ctx, nil, options)
+}
+
+// 여러 계정을 한번에 생성. 일부가 실패해도 전체를 실패시키지 않고 항목별 결과를 리턴한다
+func (s *KmsSrv) CreateAccountBatch(ctx context.Context, batchDTO *dto.AccountBatchReq) *dto.AccountBatchRes {
+	ctx, span := tracing.Start(ctx, "KmsSrv.CreateAccountBatch")
+	defer span.End()
+
+	var (
+		results = make([]dto.AccountBatchItemRes, batchDTO.Count)
+		sem     = make(chan struct{}, accountBatchConcurrency)
+		wg      sync.WaitGroup
+	)
+
+	for i := 0; i < batchDTO.Count; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(index int) {
+			defer func() { <-sem; wg.Done() }()
+
+			tags := make([]types.Tag, len(batchDTO.Tags))
+			for j, tag := range batchDTO.Tags {
+				tags[j] = types.Tag{
+					TagKey:   aws.String(tag.Key),
+					TagValue: aws.String(strings.ReplaceAll(tag.Value, "{index}", strconv.Itoa(index))),
+				}
+			}
+
+			accountRes, err := s.createAccount(ctx, tags, createAccountOptions{})
+			if err != nil {
+				results[index] = dto.AccountBatchItemRes{Index: index, Error: dto.NewItemErrRes(err)}
+				return
+			}
+			results[index] = dto.AccountBatchItemRes{Index: index, Account: accountRes}
+		}(i)
+	}
+	wg.Wait()
+
+	batchRes := &dto.AccountBatchRes{Results: results}
+	for _, result := range results {
+		if result.Error != nil {
+			batchRes.Failed++
+		} else {
+			batchRes.Succeeded++
+		}
+	}
+	return batchRes
+}
+
+func (s *KmsSrv) createAccount(ctx context.Context, tags []types.Tag, options createAccountOptions) (*dto.AccountRes, error) {
+	return s.createAccountWithDescription(ctx, nil, tags, options)
+}
+
+func (s *KmsSrv) createAccountWithDescription(ctx context.Context, description *string, tags []types.Tag, options createAccountOptions) (*dto.AccountRes, error) {
+	input := s.withKeyPolicy(&kms.CreateKeyInput{
+		KeyUsage:    types.KeyUsageTypeSignVerify,
+		KeySpec:     types.KeySpecEccSecgP256k1,
+		Description: description,
+		Tags:        tags,
 	})
+	if options.multiRegion || options.replicate {
+		input.MultiRegion = aws.Bool(true)
+	}
+
+	key, err := s.client.CreateKey(ctx, input)
 	if err != nil {
 		return nil, errs.RouteAwsErr(err)
-
 	}
 	keyID := *key.KeyMetadata.KeyId
 
-	accountRes, err := s.GetAccount(&dto.KeyIdReq{KeyID: keyID})
+	accountRes, err := s.account(ctx, keyID, key.KeyMetadata)
 	if err != nil {
 		return nil, err
 	}
 
+	if options.replicate {
+		if err := s.replicate(ctx, keyID, input); err != nil {
+			return nil, err
+		}
+		for _, replica := range s.replicas {
+			accountRes.ReplicaRegions = append(accountRes.ReplicaRegions, replica.Region)
+		}
+	}
 	return accountRes, nil
 }
 
 // keyID와 매칭되는 account 리턴
-func (s *KmsSrv) GetAccount(keyIdDTO *dto.KeyIdReq) (*dto.AccountRes, error) {
-	pubkey, err := s.getPubKey(keyIdDTO.KeyID)
+func (s *KmsSrv) GetAccount(ctx context.Context, keyIdDTO *dto.KeyIdReq) (_ *dto.AccountRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.GetAccount", tracing.KeyID(keyIdDTO.KeyID))
+	defer func() { tracing.End(span, err) }()
+
+	keyInfo, err := s.client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(keyIdDTO.KeyID)})
+	if err != nil {
+		return nil, errs.RouteAwsErr(err)
+	}
+	return s.account(ctx, keyIdDTO.KeyID, keyInfo.KeyMetadata)
+}
+
+// 이미 조회한 키 정보로 account 를 만든다. 동결 기록은 keyID(ARN, alias 일 수 있다) 대신 키 정보의 key id 로 찾는다
+func (s *KmsSrv) account(ctx context.Context, keyID string, keyInfo *types.KeyMetadata) (*dto.AccountRes, error) {
+	canonicalID := aws.ToString(keyInfo.KeyId)
+
+	// 비활성화된 키는 public key 를 조회할 수 없기 때문에 캐시나 동결할 때 기록한 주소를 쓴다
+	if keyInfo.KeyState == types.KeyStateDisabled {
+		accountRes := &dto.AccountRes{KeyID: keyID}
+		if pubkey := s.pubKeyCache.Get(keyID); pubkey != nil {
+			accountRes.Address = crypto.PubkeyToAddress(*pubkey).String()
+		} else if freeze, ok := s.freezeStore.Get(canonicalID); ok {
+			accountRes.Address = freeze.Address
+		}
+		return s.withFreezeInfo(accountRes, canonicalID, keyInfo.KeyState), nil
+	}
+
+	pubkey, err := s.getPubKey(ctx, keyID)
 	if err != nil {
 		return nil, err
 	}
 
 	addr := crypto.PubkeyToAddress(*pubkey)
-	return &dto.AccountRes{Address: addr.String(), KeyID: keyIdDTO.KeyID}, nil
+	return s.withFreezeInfo(&dto.AccountRes{Address: addr.String(), KeyID: keyID}, canonicalID, keyInfo.KeyState), nil
 }
 
 // aws kms에 저장된 키들의 ID 리스트를 리턴
-func (s *KmsSrv) GetAccountList(accountListDTO *dto.AccountListReq) (*dto.AccountListRes, error) {
-	keyList, err := s.client.ListKeys(context.TODO(), &kms.ListKeysInput{
+func (s *KmsSrv) GetAccountList(ctx context.Context, accountListDTO *dto.AccountListReq) (_ *dto.AccountListRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.GetAccountList")
+	defer func() { tracing.End(span, err) }()
+
+	keyList, err := s.client.ListKeys(ctx, &kms.ListKeysInput{
 		Limit:  accountListDTO.Limit,
 		Marker: accountListDTO.Marker,
 	})
@@ -103,19 +241,19 @@ func (s *KmsSrv) GetAccountList(accountListDTO *dto.AccountListReq) (*dto.Accoun
 	for i, key := range keyList.Keys {
 		if key.KeyId != nil {
 			// 사용 불가능한 키는 필터링 한다
-			keyInfo, err := s.client.DescribeKey(context.TODO(), &kms.DescribeKeyInput{KeyId: key.KeyId})
+			keyInfo, err := s.client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: key.KeyId})
 			if err != nil {
 				return nil, errs.RouteAwsErr(err)
 			}
 			if keyInfo.KeyMetadata.Enabled && keyInfo.KeyMetadata.KeySpec == types.KeySpecEccSecgP256k1 {
-				accountRes, err := s.GetAccount(&dto.KeyIdReq{KeyID: *key.KeyId})
+				accountRes, err := s.account(ctx, *key.KeyId, keyInfo.KeyMetadata)
 				if err != nil {
 					return nil, err
 				}
 				accountsList[i] = *accountRes
 			} else {
 				// 사용불가한 계정은 address 를 빈값으로 리턴한다
-				accountsList[i] = dto.AccountRes{KeyID: *key.KeyId}
+				accountsList[i] = *s.withFreezeInfo(&dto.AccountRes{KeyID: *key.KeyId}, *key.KeyId, keyInfo.KeyMetadata.KeyState)
 			}
 		}
 	}
@@ -128,10 +266,29 @@ func (s *KmsSrv) GetAccountList(accountListDTO *dto.AccountListReq) (*dto.Accoun
 }
 
 // 외부 private key를 주입
-func (s *KmsSrv) ImportAccount(pkDTO *dto.PkReq) (*dto.AccountRes, error) {
+func (s *KmsSrv) ImportAccount(ctx context.Context, pkDTO *dto.PkReq) (_ *dto.AccountRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.ImportAccount")
+	defer func() { tracing.End(span, err) }()
+
+	ecdsaPK, err := crypto.HexToECDSA(pkDTO.PK)
+	if err != nil {
+		return nil, errs.InternalServerErr(err)
+	}
+
+	return s.importPrivateKey(ctx, ecdsaPK, pkDTO.ExpectedAddress)
+}
+
+// 외부키 주입은 트랜잭션처럼 동작한다. kms key 껍데기를 만든 이후 어느 단계에서든 실패하면 껍데기를 삭제 예약한다
+func (s *KmsSrv) importPrivateKey(ctx context.Context, ecdsaPK *ecdsa.PrivateKey, expectedAddr string) (*dto.AccountRes, error) {
 	// 특정 key-id 에 외부 pk를 주입한 이후 주입된 pk 를 삭제하고 다른 pk를 주입하는건 불가능하다
 	// 한번이라도 외부키가 주입된 key-id는 이후로 계속 같은 외부키만 주입받을 수 있다.
 
+	// 키를 만들기 전에 기대 주소부터 확인
+	derivedAddr := crypto.PubkeyToAddress(ecdsaPK.PublicKey)
+	if expectedAddr != "" && common.HexToAddress(expectedAddr) != derivedAddr {
+		return nil, errs.AddressMismatchErr(fmt.Errorf("derived address %v does not match expected address %v", derivedAddr, expectedAddr))
+	}
+
 	var (
 		keyID           *string
 		importParameter *kms.GetParametersForImportOutput
@@ -139,111 +296,347 @@ func (s *KmsSrv) ImportAccount(pkDTO *dto.PkReq) (*dto.AccountRes, error) {
 	)
 
 	go func() {
-		// kms key 껍데기 생성
-		key, err := s.client.CreateKey(context.TODO(), &kms.CreateKeyInput{
-			KeyUsage: types.KeyUsageTypeSignVerify,
-			KeySpec:  types.KeySpecEccSecgP256k1,
-			Origin:   types.OriginTypeExternal,
-		})
-		if err != nil {
-			errChan <- errs.RouteAwsErr(err)
-			return
-		}
-		keyID = key.KeyMetadata.KeyId
-
-		// private key 주입과정에서 필요한 파라미터값 요청
-		importParameter, err = s.client.GetParametersForImport(context.TODO(), &kms.GetParametersForImportInput{
-			KeyId:             keyID,
-			WrappingAlgorithm: types.AlgorithmSpecRsaesOaepSha256,
-			WrappingKeySpec:   types.WrappingKeySpecRsa2048,
-		})
-		if err != nil {
-			errChan <- errs.RouteAwsErr(err)
-			return
-		}
-
-		errChan <- nil
+		var err error
+		keyID, importParameter, err = s.createImportShell(ctx)
+		errChan <- err
 	}()
 
-	// ==== private key ASN.1 데이터 형식으로 DER 인코딩 ====
-	ecdsaPK, err := crypto.HexToECDSA(pkDTO.PK)
+	// private key ASN.1 데이터 형식으로 DER 인코딩
+	pkcs8Asn1EcPK, err := keyutil.MarshalPKCS8(ecdsaPK)
+	errWrap := <-errChan
 	if err != nil {
-		return nil, errs.InternalServerErr(err)
+		return nil, s.abortImport(ctx, keyID, errs.InternalServerErr(err))
+	}
+	if errWrap != nil {
+		return nil, s.abortImport(ctx, keyID, errWrap)
 	}
 
-	asn1EcPK, err := asn1.Marshal(asn1PKFormat{
-		Version:       1,
-		PrivateKey:    crypto.FromECDSA(ecdsaPK),
-		PublicKey:     asn1.BitString{Bytes: crypto.FromECDSAPub(&ecdsaPK.PublicKey)},
-		NamedCurveOID: asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1},
-	})
+	encryptedMaterial, err := keyutil.EncryptKeyMaterial(pkcs8Asn1EcPK, importParameter.PublicKey)
 	if err != nil {
-		return nil, errs.InternalServerErr(err)
+		return nil, s.abortImport(ctx, keyID, errs.InternalServerErr(err))
 	}
 
-	pkcs8Asn1EcPK, err := asn1.Marshal(pkcs8Asn1PKFormat{
-		Version: 0,
-		Algo: pkix.AlgorithmIdentifier{
-			Algorithm:  asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1},
-			Parameters: asn1.RawValue{Class: 0, Tag: 6, IsCompound: false, Bytes: []uint8{0x2b, 0x81, 0x4, 0x0, 0xa}, FullBytes: []uint8{0x6, 0x5, 0x2b, 0x81, 0x4, 0x0, 0xa}},
-		},
-		PrivateKey: asn1EcPK,
+	_, err = s.client.ImportKeyMaterial(ctx, &kms.ImportKeyMaterialInput{
+		ImportToken:          importParameter.ImportToken,
+		KeyId:                keyID,
+		EncryptedKeyMaterial: encryptedMaterial,
+		ExpirationModel:      types.ExpirationModelTypeKeyMaterialDoesNotExpire,
 	})
 	if err != nil {
-		return nil, errs.InternalServerErr(err)
+		return nil, s.abortImport(ctx, keyID, errs.RouteAwsErr(err))
 	}
-	// ================================================
 
-	if errWrap := <-errChan; errWrap != nil {
-		return nil, errWrap
+	return s.verifyImport(ctx, keyID, derivedAddr.String())
+}
+
+// 클라이언트가 직접 private key 를 암호화할 수 있도록 주입용 kms key 껍데기와 wrapping key, import token 을 리턴
+func (s *KmsSrv) GetImportParams(ctx context.Context) (_ *dto.ImportParamsRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.GetImportParams")
+	defer func() { tracing.End(span, err) }()
+
+	keyID, importParameter, err := s.createImportShell(ctx)
+	if err != nil {
+		return nil, s.abortImport(ctx, keyID, err)
 	}
 
-	rsaPubKey, err := x509.ParsePKIXPublicKey(importParameter.PublicKey)
+	return &dto.ImportParamsRes{
+		KeyID:             *keyID,
+		PublicKey:         base64.StdEncoding.EncodeToString(importParameter.PublicKey),
+		ImportToken:       base64.StdEncoding.EncodeToString(importParameter.ImportToken),
+		WrappingAlgorithm: string(types.AlgorithmSpecRsaesOaepSha256),
+		WrappingKeySpec:   string(types.WrappingKeySpecRsa2048),
+		ParametersValidTo: importParameter.ParametersValidTo.String(),
+	}, nil
+}
+
+// 클라이언트에서 암호화된 private key 를 주입. 서버는 평문 키를 볼 수 없기 때문에 expectedAddress 로 결과를 검증한다
+func (s *KmsSrv) ImportEncryptedAccount(ctx context.Context, encryptedDTO *dto.EncryptedImportReq) (_ *dto.AccountRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.ImportEncryptedAccount", tracing.KeyID(encryptedDTO.KeyID))
+	defer func() { tracing.End(span, err) }()
+
+	if encryptedDTO.ExpectedAddress == "" {
+		return nil, errs.BadRequestErr(fmt.Errorf("expectedAddress is required to verify the imported key"))
+	}
+	importToken, err := base64.StdEncoding.DecodeString(encryptedDTO.ImportToken)
 	if err != nil {
-		return nil, errs.InternalServerErr(err)
+		return nil, errs.BadRequestErr(err)
 	}
-	encryptedMaterial, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, rsaPubKey.(*rsa.PublicKey), pkcs8Asn1EcPK, nil)
+	encryptedMaterial, err := base64.StdEncoding.DecodeString(encryptedDTO.EncryptedKeyMaterial)
 	if err != nil {
-		return nil, errs.InternalServerErr(err)
+		return nil, errs.BadRequestErr(err)
 	}
 
-	_, err = s.client.ImportKeyMaterial(context.TODO(), &kms.ImportKeyMaterialInput{
-		ImportToken:          importParameter.ImportToken,
+	// 주입 대기중인 외부키 껍데기가 아니면 거부한다 (실패시 삭제 예약되기 때문에 기존 키가 지워지지 않도록)
+	keyInfo, err := s.client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(encryptedDTO.KeyID)})
+	if err != nil {
+		return nil, errs.RouteAwsErr(err)
+	}
+	if keyInfo.KeyMetadata.Origin != types.OriginTypeExternal || keyInfo.KeyMetadata.KeyState != types.KeyStatePendingImport {
+		return nil, errs.InvalidKeyErr(fmt.Errorf("keyId '%v' is not waiting for key material import", encryptedDTO.KeyID))
+	}
+
+	keyID := keyInfo.KeyMetadata.KeyId
+	_, err = s.client.ImportKeyMaterial(ctx, &kms.ImportKeyMaterialInput{
+		ImportToken:          importToken,
 		KeyId:                keyID,
 		EncryptedKeyMaterial: encryptedMaterial,
 		ExpirationModel:      types.ExpirationModelTypeKeyMaterialDoesNotExpire,
 	})
 	if err != nil {
-		return nil, errs.RouteAwsErr(err)
+		return nil, s.abortImport(ctx, keyID, errs.RouteAwsErr(err))
 	}
 
-	accountRes, errWrap := s.GetAccount(&dto.KeyIdReq{KeyID: *keyID})
-	if errWrap != nil {
-		return nil, errs.RouteAwsErr(err)
+	return s.verifyImport(ctx, keyID, encryptedDTO.ExpectedAddress)
+}
+
+// 외부키 주입용 kms key 껍데기 생성 후 주입에 필요한 파라미터 요청
+// 껍데기 생성 이후에 실패한 경우에도 정리할 수 있도록 keyID 를 함께 리턴한다
+func (s *KmsSrv) createImportShell(ctx context.Context) (*string, *kms.GetParametersForImportOutput, error) {
+	key, err := s.client.CreateKey(ctx, s.withKeyPolicy(&kms.CreateKeyInput{
+		KeyUsage: types.KeyUsageTypeSignVerify,
+		KeySpec:  types.KeySpecEccSecgP256k1,
+		Origin:   types.OriginTypeExternal,
+	}))
+	if err != nil {
+		return nil, nil, errs.RouteAwsErr(err)
+	}
+
+	importParameter, err := s.client.GetParametersForImport(ctx, &kms.GetParametersForImportInput{
+		KeyId:             key.KeyMetadata.KeyId,
+		WrappingAlgorithm: types.AlgorithmSpecRsaesOaepSha256,
+		WrappingKeySpec:   types.WrappingKeySpecRsa2048,
+	})
+	if err != nil {
+		return key.KeyMetadata.KeyId, nil, errs.RouteAwsErr(err)
+	}
+
+	return key.KeyMetadata.KeyId, importParameter, nil
+}
+
+// kms 가 리턴하는 주소가 주입한 키의 주소와 같은지 확인. expectedAddr 이 비어있으면 확인하지 않는다
+func (s *KmsSrv) verifyImport(ctx context.Context, keyID *string, expectedAddr string) (*dto.AccountRes, error) {
+	accountRes, err := s.GetAccount(ctx, &dto.KeyIdReq{KeyID: *keyID})
+	if err != nil {
+		return nil, s.abortImport(ctx, keyID, err)
+	}
+
+	if expectedAddr != "" && common.HexToAddress(expectedAddr) != common.HexToAddress(accountRes.Address) {
+		return nil, s.abortImport(ctx, keyID, errs.AddressMismatchErr(fmt.Errorf("imported address %v does not match expected address %v", accountRes.Address, expectedAddr)))
 	}
 	return accountRes, nil
 }
 
-func (s *KmsSrv) DeleteAccount(keyIdDTO *dto.KeyIdReq) (*dto.AccountDeletionRes, error) {
-	output, err := s.client.ScheduleKeyDeletion(context.TODO(), &kms.ScheduleKeyDeletionInput{
+// 주입에 실패한 kms key 껍데기를 삭제 예약한다. 삭제 예약도 실패하면 orphan 으로 남은 키를 로그로 남긴다
+func (s *KmsSrv) abortImport(ctx context.Context, keyID *string, cause error) error {
+	if keyID == nil {
+		return cause
+	}
+	s.pubKeyCache.Remove(*keyID)
+
+	// 요청이 취소되었더라도 껍데기는 정리해야 한다
+	output, err := s.client.ScheduleKeyDeletion(context.WithoutCancel(ctx), &kms.ScheduleKeyDeletionInput{
+		KeyId:               keyID,
+		PendingWindowInDays: aws.Int32(7),
+	})
+	if err != nil {
+		logger.Error().Ctx(ctx).E(err).D("keyID", *keyID).D("cause", cause.Error()).W("orphaned import key")
+		return errs.Annotate(cause, fmt.Sprintf("orphaned import key '%v' could not be scheduled for deletion", *keyID))
+	}
+
+	logger.Warn().Ctx(ctx).D("keyID", *keyID).D("deletionDate", output.DeletionDate.String()).D("cause", cause.Error()).W("import aborted")
+	return errs.Annotate(cause, fmt.Sprintf("import key '%v' scheduled for deletion", *keyID))
+}
+
+func (s *KmsSrv) DeleteAccount(ctx context.Context, keyIdDTO *dto.KeyIdReq) (_ *dto.AccountDeletionRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.DeleteAccount", tracing.KeyID(keyIdDTO.KeyID))
+	defer func() { tracing.End(span, err) }()
+
+	output, err := s.client.ScheduleKeyDeletion(ctx, &kms.ScheduleKeyDeletionInput{
 		KeyId:               &keyIdDTO.KeyID,
 		PendingWindowInDays: aws.Int32(7),
 	})
 	if err != nil {
 		return nil, errs.RouteAwsErr(err)
 	}
+	s.pubKeyCache.Remove(keyIdDTO.KeyID)
 
 	return &dto.AccountDeletionRes{KeyID: keyIdDTO.KeyID, DeletionDate: output.DeletionDate.String()}, nil
 }
 
+// 계정 동결. kms 키를 비활성화하고, kms 호출이 성공하더라도 서명을 거부하도록 kill switch 에 등록한다
+func (s *KmsSrv) DisableAccount(ctx context.Context, keyIdDTO *dto.KeyIdReq, freezeDTO *dto.FreezeReq) (_ *dto.AccountRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.DisableAccount", tracing.KeyID(keyIdDTO.KeyID))
+	defer func() { tracing.End(span, err) }()
+
+	keyID, err := s.canonicalKeyID(ctx, keyIdDTO.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 비활성화된 키는 public key 조회가 불가능하기 때문에 응답에 쓸 계정 정보를 미리 조회해둔다
+	accountRes, err := s.GetAccount(ctx, keyIdDTO)
+	if err != nil {
+		return nil, err
+	}
+
+	// kms 호출보다 먼저 동결해서, DisableKey 가 실패하더라도 서명은 차단된 상태를 유지한다
+	// 기록을 파일에 남기지 못했더라도 키는 비활성화해야 하기 때문에 에러는 마지막에 리턴한다
+	saveErr := s.freezeStore.Save(store.Freeze{
+		KeyID:    keyID,
+		Address:  accountRes.Address,
+		Reason:   freezeDTO.Reason,
+		Actor:    freezeDTO.Actor,
+		FrozenAt: time.Now(),
+	})
+	if err := audit.Record(ctx, "disable", keyID, freezeDTO.Actor, freezeDTO.Reason); err != nil {
+		return nil, errs.InternalServerErr(err)
+	}
+
+	_, err = s.client.DisableKey(ctx, &kms.DisableKeyInput{KeyId: aws.String(keyIdDTO.KeyID)})
+	if err != nil {
+		return nil, errs.RouteAwsErr(err)
+	}
+	s.pubKeyCache.Remove(keyIdDTO.KeyID)
+	if saveErr != nil {
+		return nil, errs.InternalServerErr(saveErr)
+	}
+
+	return s.withFreezeInfo(accountRes, keyID, types.KeyStateDisabled), nil
+}
+
+// 동결된 계정을 다시 활성화
+func (s *KmsSrv) EnableAccount(ctx context.Context, keyIdDTO *dto.KeyIdReq, freezeDTO *dto.FreezeReq) (_ *dto.AccountRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.EnableAccount", tracing.KeyID(keyIdDTO.KeyID))
+	defer func() { tracing.End(span, err) }()
+
+	keyID, err := s.canonicalKeyID(ctx, keyIdDTO.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.client.EnableKey(ctx, &kms.EnableKeyInput{KeyId: aws.String(keyIdDTO.KeyID)})
+	if err != nil {
+		return nil, errs.RouteAwsErr(err)
+	}
+
+	if err := s.freezeStore.Remove(keyID); err != nil {
+		return nil, errs.InternalServerErr(err)
+	}
+	if err := audit.Record(ctx, "enable", keyID, freezeDTO.Actor, freezeDTO.Reason); err != nil {
+		return nil, errs.InternalServerErr(err)
+	}
+
+	return s.GetAccount(ctx, keyIdDTO)
+}
+
+// 기존 키의 description 과 tag 를 복사한 새 계정을 생성 (키 로테이션용)
+func (s *KmsSrv) CloneAccount(ctx context.Context, keyIdDTO *dto.KeyIdReq, extraTags map[string]string) (_ *dto.AccountRes, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.CloneAccount", tracing.KeyID(keyIdDTO.KeyID))
+	defer func() { tracing.End(span, err) }()
+
+	keyInfo, err := s.client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(keyIdDTO.KeyID)})
+	if err != nil {
+		return nil, errs.RouteAwsErr(err)
+	}
+
+	var tags []types.Tag
+	tagsInput := &kms.ListResourceTagsInput{KeyId: aws.String(keyIdDTO.KeyID)}
+	for {
+		tagsOutput, err := s.client.ListResourceTags(ctx, tagsInput)
+		if err != nil {
+			return nil, errs.RouteAwsErr(err)
+		}
+		for _, tag := range tagsOutput.Tags {
+			if _, overridden := extraTags[aws.ToString(tag.TagKey)]; !overridden {
+				tags = append(tags, tag)
+			}
+		}
+		if !tagsOutput.Truncated {
+			break
+		}
+		tagsInput.Marker = tagsOutput.NextMarker
+	}
+	for k, v := range extraTags {
+		tags = append(tags, types.Tag{TagKey: aws.String(k), TagValue: aws.String(v)})
+	}
+
+	return s.createAccountWithDescription(ctx, keyInfo.KeyMetadata.Description, tags, createAccountOptions{})
+}
+
+// 로테이션이 끝난 키를 retired 로 태깅하고 서명을 차단한다
+// 동결 기록으로 서명을 막고, kms 키도 비활성화한다. 이후 입금된 자산을 다시 옮겨야 하면 EnableAccount 로 해제한다
+// 여러번 호출해도 되고, 이미 retired 로 기록된 키는 비활성화와 태깅만 다시 한다
+func (s *KmsSrv) RetireAccount(ctx context.Context, keyIdDTO *dto.KeyIdReq, newKeyID string, actor string) (err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.RetireAccount", tracing.KeyID(keyIdDTO.KeyID))
+	defer func() { tracing.End(span, err) }()
+
+	keyID, err := s.canonicalKeyID(ctx, keyIdDTO.KeyID)
+	if err != nil {
+		return err
+	}
+
+	// kms 호출보다 먼저 기록해서 비활성화나 태깅이 실패하더라도 서명은 차단된 상태를 유지한다
+	if freeze, ok := s.freezeStore.Get(keyID); !ok || !freeze.Retired {
+		// 비활성화된 키는 public key 를 조회할 수 없기 때문에 조회 응답에 쓸 주소를 같이 기록한다
+		var address string
+		if accountRes, err := s.GetAccount(ctx, keyIdDTO); err == nil {
+			address = accountRes.Address
+		} else {
+			logger.Warn().Ctx(ctx).E(err).D("keyID", keyID).W("failed to look up the address of the retiring key")
+		}
+
+		reason := fmt.Sprintf("rotated to %v", newKeyID)
+		if err := s.freezeStore.Save(store.Freeze{KeyID: keyID, Address: address, Reason: reason, Actor: actor, Retired: true, FrozenAt: time.Now()}); err != nil {
+			return errs.InternalServerErr(err)
+		}
+		if err := audit.Record(ctx, "retire", keyID, actor, reason); err != nil {
+			return errs.InternalServerErr(err)
+		}
+	}
+
+	_, err = s.client.DisableKey(ctx, &kms.DisableKeyInput{KeyId: aws.String(keyIdDTO.KeyID)})
+	if err != nil {
+		return errs.RouteAwsErr(err)
+	}
+	_, err = s.client.TagResource(ctx, &kms.TagResourceInput{
+		KeyId: aws.String(keyIdDTO.KeyID),
+		Tags: []types.Tag{
+			{TagKey: aws.String("Retired"), TagValue: aws.String("true")},
+			{TagKey: aws.String("RotatedTo"), TagValue: aws.String(newKeyID)},
+		},
+	})
+	if err != nil {
+		return errs.RouteAwsErr(err)
+	}
+	return nil
+}
+
 // 메세지에 서명 이후 R, S 값을 리턴
-func (s *KmsSrv) Sign(keyID string, msg []byte) ([]byte, []byte, error) {
-	signRes, err := s.client.Sign(context.TODO(), &kms.SignInput{
+func (s *KmsSrv) Sign(ctx context.Context, keyID string, msg []byte) (_, _ []byte, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.Sign", tracing.KeyID(keyID))
+	defer func() { tracing.End(span, err) }()
+
+	canonicalID, err := s.canonicalKeyID(ctx, keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info, frozen := s.freezeStore.Get(canonicalID); frozen {
+		return nil, nil, errs.WithDetails(
+			errs.FrozenKeyErr(fmt.Errorf("keyId '%v' is frozen by %v: %v", keyID, info.Actor, info.Reason)),
+			map[string]any{"keyID": keyID, "frozenBy": info.Actor},
+		)
+	}
+
+	input := &kms.SignInput{
 		KeyId:            aws.String(keyID),
 		SigningAlgorithm: types.SigningAlgorithmSpecEcdsaSha256,
 		MessageType:      types.MessageTypeDigest, // 해당필드 빼먹으면 aws_kms 에서 msg를 또다시 해시하여 잘못된 서명값을 리턴한다
 		Message:          msg,
-	})
+	}
+	signRes, err := s.client.Sign(ctx, input)
+	if err != nil && len(s.replicas) > 0 && isMultiRegionKey(keyID) && ctx.Err() == nil && failoverable(err) {
+		signRes, err = s.signOnReplica(ctx, keyID, input, err)
+	}
 	if err != nil {
 		return nil, nil, errs.RouteAwsErr(err)
 	}
@@ -258,23 +651,45 @@ func (s *KmsSrv) Sign(keyID string, msg []byte) ([]byte, []byte, error) {
 }
 
 // keyID와 매칭되는 public key(바이트)를 리턴
-func (s *KmsSrv) GetPubkey(keyIdDTO *dto.KeyIdReq) ([]byte, error) {
-	pubkey, err := s.getPubKey(keyIdDTO.KeyID)
+func (s *KmsSrv) GetPubkey(ctx context.Context, keyIdDTO *dto.KeyIdReq) (_ []byte, err error) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.GetPubkey", tracing.KeyID(keyIdDTO.KeyID))
+	defer func() { tracing.End(span, err) }()
+
+	pubkey, err := s.getPubKey(ctx, keyIdDTO.KeyID)
 	if err != nil {
 		return nil, err
 	}
 	return secp256k1.S256().Marshal(pubkey.X, pubkey.Y), nil
 }
 
-func (s *KmsSrv) getPubKey(keyID string) (*ecdsa.PublicKey, error) {
-	cached := s.pubKeyCache.Get(keyID)
-	if cached != nil {
+func (s *KmsSrv) getPubKey(ctx context.Context, keyID string) (*ecdsa.PublicKey, error) {
+	if cached := s.pubKeyCache.Get(keyID); cached != nil {
 		return cached, nil
+	}
 
+	// 조회 결과는 기다리는 모든 요청이 공유하기 때문에 처음 요청한 쪽이 취소되더라도 조회는 계속한다
+	resChan := s.pubKeyGroup.DoChan(keyID, func() (interface{}, error) {
+		return s.fetchPubKey(context.WithoutCancel(ctx), keyID)
+	})
+	select {
+	case res := <-resChan:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*ecdsa.PublicKey), nil
+	case <-ctx.Done():
+		return nil, errs.RouteCtxErr(ctx.Err())
 	}
-	pubKeyOut, err := s.client.GetPublicKey(context.TODO(), &kms.GetPublicKeyInput{
+}
+
+func (s *KmsSrv) fetchPubKey(ctx context.Context, keyID string) (*ecdsa.PublicKey, error) {
+	input := &kms.GetPublicKeyInput{
 		KeyId: aws.String(keyID),
-	})
+	}
+	pubKeyOut, err := s.client.GetPublicKey(ctx, input)
+	if err != nil && len(s.replicas) > 0 && isMultiRegionKey(keyID) && ctx.Err() == nil && failoverable(err) {
+		pubKeyOut, err = s.pubKeyOnReplica(ctx, keyID, input, err)
+	}
 	if err != nil {
 		return nil, errs.RouteAwsErr(err)
 	}
@@ -292,5 +707,67 @@ func (s *KmsSrv) getPubKey(keyID string) (*ecdsa.PublicKey, error) {
 	s.pubKeyCache.Add(keyID, pubKey)
 
 	return pubKey, nil
+}
+
+// 시작할 때 keyIDs 의 public key 를 미리 캐싱한다. 실패한 키는 로그만 남기고 첫 요청 때 다시 조회한다
+func (s *KmsSrv) WarmUpPubKeys(ctx context.Context, keyIDs []string) (loaded int) {
+	ctx, span := tracing.Start(ctx, "KmsSrv.WarmUpPubKeys", attribute.Int("wallet.key_count", len(keyIDs)))
+	defer span.End()
+
+	var (
+		sem   = make(chan struct{}, accountBatchConcurrency)
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
 
+	for _, keyID := range keyIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(keyID string) {
+			defer func() { <-sem; wg.Done() }()
+
+			if _, err := s.getPubKey(ctx, keyID); err != nil {
+				logger.Warn().Ctx(ctx).E(err).D("keyID", keyID).W("failed to warm up public key")
+				return
+			}
+			mutex.Lock()
+			loaded++
+			mutex.Unlock()
+		}(keyID)
+	}
+	wg.Wait()
+
+	return loaded
+}
+
+// 동결 기록은 키를 가리키는 방법(key id, ARN, alias)과 상관없이 같은 기록을 찾도록 key id 로 남긴다
+// ARN 은 key id 를 잘라내고, alias 는 kms 에 조회한다
+func (s *KmsSrv) canonicalKeyID(ctx context.Context, keyID string) (string, error) {
+	if !strings.HasPrefix(keyID, "alias/") && !strings.Contains(keyID, ":alias/") {
+		if i := strings.Index(keyID, ":key/"); strings.HasPrefix(keyID, "arn:") && i >= 0 {
+			return keyID[i+len(":key/"):], nil
+		}
+		return keyID, nil
+	}
+	keyInfo, err := s.client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(keyID)})
+	if err != nil {
+		return "", errs.RouteAwsErr(err)
+	}
+	return aws.ToString(keyInfo.KeyMetadata.KeyId), nil
+}
+
+// 동결 기록이 있거나 kms 에서 비활성화된 키는 동결된 것으로 본다 (콘솔 등에서 직접 비활성화한 경우 기록이 없다)
+// keyID 는 canonicalKeyID 로 정규화한 key id
+func (s *KmsSrv) withFreezeInfo(accountRes *dto.AccountRes, keyID string, keyState types.KeyState) *dto.AccountRes {
+	if info, frozen := s.freezeStore.Get(keyID); frozen {
+		accountRes.Frozen = true
+		accountRes.FreezeInfo = &dto.FreezeInfoRes{
+			Reason:   info.Reason,
+			Actor:    info.Actor,
+			FrozenAt: info.FrozenAt.Format(timeutil.DateFormat),
+		}
+	} else if keyState == types.KeyStateDisabled {
+		accountRes.Frozen = true
+	}
+	return accountRes
 }
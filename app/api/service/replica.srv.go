@@ -0,0 +1,201 @@
+package srv
+
+import (
+	"context"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"kms/wallet/app/kmsclient"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+)
+
+// 다중 리전 키의 replica 를 둘 region 과 그 region 의 kms
+type ReplicaRegion struct {
+	Region string
+	Client kmsclient.Client
+}
+
+// 다중 리전 키를 replicas 의 region 에 복제하고, 원래 region 의 서명이 실패하면 순서대로 replica 로 서명한다
+func WithReplicaRegions(replicas ...ReplicaRegion) KmsSrvOption {
+	return func(s *KmsSrv) {
+		s.replicas = replicas
+	}
+}
+
+type createAccountOptions struct {
+	multiRegion bool
+	replicate   bool
+}
+
+type CreateAccountOption func(*createAccountOptions)
+
+// 다중 리전 키(mrk-)로 만든다. replica 는 만들지 않는다
+func MultiRegion() CreateAccountOption {
+	return func(o *createAccountOptions) {
+		o.multiRegion = true
+	}
+}
+
+// 다중 리전 키로 만들고 WithReplicaRegions 의 모든 region 에 복제한다
+func Replicate() CreateAccountOption {
+	return func(o *createAccountOptions) {
+		o.replicate = true
+	}
+}
+
+// replica 는 같은 key material 을 공유하기 때문에 address 도 같다
+// kms 는 replica 에 원래 키의 tag 와 key policy 를 복사하지 않기 때문에 원래 키를 만들 때의 값(input)을 같이 넘긴다
+// 복제에 실패하면 장애시 서명을 넘길 수 없는 키가 남지 않도록 키를 삭제 예약한다
+func (s *KmsSrv) replicate(ctx context.Context, keyID string, input *kms.CreateKeyInput) error {
+	for i, replica := range s.replicas {
+		_, err := s.client.ReplicateKey(ctx, &kms.ReplicateKeyInput{
+			KeyId:         aws.String(keyID),
+			ReplicaRegion: aws.String(replica.Region),
+			Description:   input.Description,
+			Policy:        input.Policy,
+			Tags:          input.Tags,
+		})
+		if err != nil {
+			cause := errs.WithDetails(errs.RouteAwsErr(err), map[string]any{"keyID": keyID, "replicaRegion": replica.Region})
+			return s.abortReplicate(ctx, keyID, s.replicas[:i], cause)
+		}
+	}
+	return nil
+}
+
+// 이미 만든 replica 와 원래 키를 삭제 예약한다. 삭제 예약도 실패하면 orphan 으로 남은 키를 로그로 남긴다
+func (s *KmsSrv) abortReplicate(ctx context.Context, keyID string, replicated []ReplicaRegion, cause error) error {
+	s.pubKeyCache.Remove(keyID)
+
+	// 요청이 취소되었더라도 키는 정리해야 한다
+	ctx = context.WithoutCancel(ctx)
+	var orphaned []string
+	for _, replica := range replicated {
+		id, _ := replicaKeyID(keyID, replica.Region)
+		_, err := replica.Client.ScheduleKeyDeletion(ctx, &kms.ScheduleKeyDeletionInput{KeyId: aws.String(id), PendingWindowInDays: aws.Int32(7)})
+		if err != nil {
+			logger.Error().Ctx(ctx).E(err).D("keyID", keyID).D("region", replica.Region).D("cause", cause.Error()).W("orphaned replica key")
+			orphaned = append(orphaned, replica.Region)
+		}
+	}
+	_, err := s.client.ScheduleKeyDeletion(ctx, &kms.ScheduleKeyDeletionInput{KeyId: aws.String(keyID), PendingWindowInDays: aws.Int32(7)})
+	if err != nil {
+		logger.Error().Ctx(ctx).E(err).D("keyID", keyID).D("cause", cause.Error()).W("orphaned multi-region key")
+		return errs.Annotate(cause, fmt.Sprintf("orphaned multi-region key '%v' could not be scheduled for deletion", keyID))
+	}
+	if len(orphaned) > 0 {
+		return errs.Annotate(cause, fmt.Sprintf("multi-region key '%v' scheduled for deletion but its replicas in %v could not be", keyID, strings.Join(orphaned, ", ")))
+	}
+
+	logger.Warn().Ctx(ctx).D("keyID", keyID).D("cause", cause.Error()).W("replication aborted")
+	return errs.Annotate(cause, fmt.Sprintf("multi-region key '%v' scheduled for deletion", keyID))
+}
+
+// 원래 region 에서 실패한 서명을 replica 에서 다시 시도한다. 모든 replica 가 실패하면 원래 에러를 리턴한다
+func (s *KmsSrv) signOnReplica(ctx context.Context, keyID string, input *kms.SignInput, primaryErr error) (*kms.SignOutput, error) {
+	for _, replica := range s.replicas {
+		id, ok := replicaKeyID(keyID, replica.Region)
+		if !ok {
+			continue
+		}
+		replicaInput := *input
+		replicaInput.KeyId = aws.String(id)
+		signRes, err := replica.Client.Sign(ctx, &replicaInput)
+		if err != nil {
+			logger.Warn().Ctx(ctx).E(err).D("keyID", keyID).D("region", replica.Region).W("replica sign failed")
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := s.verifyReplicaSignature(ctx, keyID, input.Message, signRes.Signature); err != nil {
+			return nil, err
+		}
+		logger.Warn().Ctx(ctx).D("keyID", keyID).D("region", replica.Region).D("cause", primaryErr.Error()).W("signed with replica key")
+		return signRes, nil
+	}
+	return nil, primaryErr
+}
+
+// 원래 region 에서 실패한 public key 조회를 replica 에서 다시 시도한다. 모든 replica 가 실패하면 원래 에러를 리턴한다
+// replica 는 key material 을 공유하기 때문에 public key 도 같다
+func (s *KmsSrv) pubKeyOnReplica(ctx context.Context, keyID string, input *kms.GetPublicKeyInput, primaryErr error) (*kms.GetPublicKeyOutput, error) {
+	for _, replica := range s.replicas {
+		id, ok := replicaKeyID(keyID, replica.Region)
+		if !ok {
+			continue
+		}
+		replicaInput := *input
+		replicaInput.KeyId = aws.String(id)
+		pubKeyOut, err := replica.Client.GetPublicKey(ctx, &replicaInput)
+		if err != nil {
+			logger.Warn().Ctx(ctx).E(err).D("keyID", keyID).D("region", replica.Region).W("replica public key lookup failed")
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+		logger.Warn().Ctx(ctx).D("keyID", keyID).D("region", replica.Region).D("cause", primaryErr.Error()).W("public key read from replica key")
+		return pubKeyOut, nil
+	}
+	return nil, primaryErr
+}
+
+// 계정의 public key(address)로 replica 의 서명을 확인한다. 캐시에 없으면 조회하고(원래 region 이 응답하지 않으면 replica 에서),
+// 조회할 수 없으면 확인하지 못한 서명을 리턴하지 않도록 실패한다
+func (s *KmsSrv) verifyReplicaSignature(ctx context.Context, keyID string, digest, signature []byte) error {
+	pubKey, err := s.getPubKey(ctx, keyID)
+	if err != nil {
+		return errs.Annotate(err, fmt.Sprintf("public key of '%v' is needed to verify the replica signature", keyID))
+	}
+	var sigAsn1 asn1SigFormat
+	if _, err := asn1.Unmarshal(signature, &sigAsn1); err != nil {
+		return errs.InternalServerErr(err)
+	}
+	// VerifySignature 는 S 가 N/2 이하인 서명만 받는다
+	R, S := new(big.Int).SetBytes(sigAsn1.R.Bytes), new(big.Int).SetBytes(sigAsn1.S.Bytes)
+	if S.Cmp(new(big.Int).Rsh(crypto.S256().Params().N, 1)) > 0 {
+		S.Sub(crypto.S256().Params().N, S)
+	}
+	sig := append(R.FillBytes(make([]byte, 32)), S.FillBytes(make([]byte, 32))...)
+	if !crypto.VerifySignature(crypto.FromECDSAPub(pubKey), digest, sig) {
+		return errs.InternalServerErr(fmt.Errorf("replica signature of '%v' does not match the account address", keyID))
+	}
+	return nil
+}
+
+// 다중 리전 키는 key id 가 mrk- 로 시작한다
+func isMultiRegionKey(keyID string) bool {
+	return strings.HasPrefix(keyID, "mrk-") || strings.Contains(keyID, ":key/mrk-")
+}
+
+// replica 는 key id 가 같고 ARN 의 region 만 다르다. 같은 region 의 ARN 이면 false
+func replicaKeyID(keyID, region string) (string, bool) {
+	parts := strings.SplitN(keyID, ":", 6)
+	if len(parts) != 6 {
+		return keyID, true
+	}
+	if parts[3] == region {
+		return "", false
+	}
+	parts[3] = region
+	return strings.Join(parts, ":"), true
+}
+
+// 다른 region 으로 넘겨볼 만한 에러 (요청 한도 초과, 5xx, 타임아웃, circuit breaker)
+func failoverable(err error) bool {
+	var respErr interface{ HTTPStatusCode() int }
+	if errs.IsThrottling(err) || (errors.As(err, &respErr) && respErr.HTTPStatusCode() >= 500) {
+		return true
+	}
+	var cusErr *errs.CusErr
+	return errors.As(errs.RouteAwsErr(err), &cusErr) && cusErr.Kind == errs.KindKmsUnavailable
+}
@@ -0,0 +1,315 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kms/wallet/app/api/model/dto"
+	"kms/wallet/app/store"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/utils/ethutil"
+	"kms/wallet/common/utils/timeutil"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	nativeAsset     = "native"
+	nativeTxnGasFee = 21000
+)
+
+// 스윕 트렌젝션 전송에 필요한 rpc api (*ethclient.Client 혹은 테스트용 SimulatedBackend)
+type ChainClient interface {
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+type RotationSrv struct {
+	kmsSrv  *KmsSrv
+	txnSrv  *TxnSrv
+	chain   ChainClient
+	tokens  []common.Address
+	store   *store.RotationStore
+	running map[string]bool
+	mutex   sync.Mutex
+}
+
+func NewRotationSrv(kmsSrv *KmsSrv, txnSrv *TxnSrv, chain ChainClient, tokens []common.Address, rotationStore *store.RotationStore) *RotationSrv {
+	return &RotationSrv{
+		kmsSrv:  kmsSrv,
+		txnSrv:  txnSrv,
+		chain:   chain,
+		tokens:  tokens,
+		store:   rotationStore,
+		running: make(map[string]bool),
+	}
+}
+
+// 새 kms 키를 만들고 기존 키의 자산(erc20, native)을 옮긴 뒤 기존 키를 retired 처리한다
+// kms 는 비대칭키 로테이션을 지원하지 않기 때문에 자산 이동으로 로테이션을 대신한다
+// 각 단계가 끝날 때마다 진행상황을 저장하기 때문에 실패한 경우 다시 호출하면 이어서 진행된다
+func (s *RotationSrv) Rotate(ctx context.Context, keyIdDTO *dto.KeyIdReq, rotateDTO *dto.RotateReq) (*dto.RotationRes, error) {
+	s.mutex.Lock()
+	if s.running[keyIdDTO.KeyID] {
+		s.mutex.Unlock()
+		return nil, errs.BadRequestErr(fmt.Errorf("rotation of keyId '%v' is already in progress", keyIdDTO.KeyID))
+	}
+	s.running[keyIdDTO.KeyID] = true
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.running, keyIdDTO.KeyID)
+		s.mutex.Unlock()
+	}()
+
+	rotation, ok := s.store.Get(keyIdDTO.KeyID)
+	if !ok {
+		rotation = store.Rotation{OldKeyID: keyIdDTO.KeyID, Actor: rotateDTO.Actor, StartedAt: timeutil.FormatNow()}
+	}
+
+	if err := s.run(ctx, &rotation); err != nil {
+		rotation.LastError = err.Error()
+		if saveErr := s.save(&rotation); saveErr != nil {
+			return nil, errs.InternalServerErr(saveErr)
+		}
+		return nil, err
+	}
+
+	rotation.LastError = ""
+	if err := s.save(&rotation); err != nil {
+		return nil, errs.InternalServerErr(err)
+	}
+	return toRotationRes(rotation), nil
+}
+
+// 시작할 때 retired 단계까지 끝난 로테이션의 기존 키를 다시 retired 처리한다
+// 동결 기록을 파일에 남기지 않는 설정이더라도 재시작 이후 기존 키로 서명할 수 없도록 한다
+func (s *RotationSrv) ReapplyRetirements(ctx context.Context) error {
+	for _, rotation := range s.store.ListByStep(store.RotationStepRetired) {
+		if err := s.kmsSrv.RetireAccount(ctx, &dto.KeyIdReq{KeyID: rotation.OldKeyID}, rotation.NewKeyID, rotation.Actor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RotationSrv) GetRotation(keyIdDTO *dto.KeyIdReq) (*dto.RotationRes, error) {
+	rotation, ok := s.store.Get(keyIdDTO.KeyID)
+	if !ok {
+		return nil, errs.KeyIdNotFoundErr(fmt.Errorf("rotation of keyId '%v' not found", keyIdDTO.KeyID))
+	}
+	return toRotationRes(rotation), nil
+}
+
+func (s *RotationSrv) run(ctx context.Context, rotation *store.Rotation) error {
+	oldKeyIdDTO := &dto.KeyIdReq{KeyID: rotation.OldKeyID}
+
+	if rotation.NewKeyID == "" {
+		oldAccount, err := s.kmsSrv.GetAccount(ctx, oldKeyIdDTO)
+		if err != nil {
+			return err
+		}
+		newAccount, err := s.kmsSrv.CloneAccount(ctx, oldKeyIdDTO, map[string]string{"RotatedFrom": rotation.OldKeyID})
+		if err != nil {
+			return err
+		}
+
+		rotation.OldAddress, rotation.NewKeyID, rotation.NewAddress = oldAccount.Address, newAccount.KeyID, newAccount.Address
+		rotation.Step = store.RotationStepCreated
+		if err := s.save(rotation); err != nil {
+			return errs.InternalServerErr(err)
+		}
+	}
+
+	if rotation.Step == store.RotationStepCreated {
+		if err := s.sweep(ctx, rotation); err != nil {
+			return err
+		}
+		rotation.Step = store.RotationStepSwept
+		if err := s.save(rotation); err != nil {
+			return errs.InternalServerErr(err)
+		}
+	}
+
+	// 이미 retired 된 로테이션도 다시 적용해서, 동결 기록이 사라진 경우에도 기존 키의 서명을 다시 차단한다
+	if rotation.Step == store.RotationStepSwept || rotation.Step == store.RotationStepRetired {
+		if err := s.kmsSrv.RetireAccount(ctx, oldKeyIdDTO, rotation.NewKeyID, rotation.Actor); err != nil {
+			return err
+		}
+		rotation.Step = store.RotationStepRetired
+	}
+	return nil
+}
+
+// 등록된 erc20 토큰을 먼저 옮기고, 남은 native 잔고에서 수수료를 제외한 만큼 옮긴다
+func (s *RotationSrv) sweep(ctx context.Context, rotation *store.Rotation) error {
+	var (
+		from = common.HexToAddress(rotation.OldAddress)
+		to   = common.HexToAddress(rotation.NewAddress)
+	)
+
+	gasPrice, err := s.chain.SuggestGasPrice(ctx)
+	if err != nil {
+		return errs.InternalServerErr(err)
+	}
+
+	for _, token := range s.tokens {
+		if sweep := findSweep(rotation, token.Hex()); sweep != nil && sweep.Sent {
+			continue
+		}
+
+		ret, err := s.chain.CallContract(ctx, ethereum.CallMsg{To: &token, Data: ethutil.ERC20BalanceOfCallData(from)}, nil)
+		if err != nil {
+			return errs.InternalServerErr(err)
+		}
+		balance := new(big.Int).SetBytes(ret)
+		if balance.Sign() == 0 {
+			continue
+		}
+
+		calldata := ethutil.ERC20TransferCallData(to, balance)
+		gas, err := s.chain.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &token, Data: calldata})
+		if err != nil {
+			return errs.InternalServerErr(err)
+		}
+		if err := s.signAndSend(ctx, rotation, token.Hex(), balance, &types.LegacyTx{To: &token, Gas: gas, GasPrice: gasPrice, Data: calldata}); err != nil {
+			return err
+		}
+	}
+
+	if sweep := findSweep(rotation, nativeAsset); sweep != nil && sweep.Sent {
+		return nil
+	}
+
+	balance, err := s.chain.BalanceAt(ctx, from, nil)
+	if err != nil {
+		return errs.InternalServerErr(err)
+	}
+	// 아직 채굴되지 않은 토큰 스윕 트렌젝션의 수수료는 잔고에 반영되지 않았기 때문에 따로 빼준다
+	reserved, err := s.pendingFees(ctx, rotation)
+	if err != nil {
+		return err
+	}
+	amount := new(big.Int).Sub(balance, reserved)
+	amount.Sub(amount, new(big.Int).Mul(gasPrice, big.NewInt(nativeTxnGasFee)))
+	if amount.Sign() <= 0 {
+		return nil
+	}
+
+	return s.signAndSend(ctx, rotation, nativeAsset, amount, &types.LegacyTx{To: &to, Gas: nativeTxnGasFee, GasPrice: gasPrice, Value: amount})
+}
+
+// TxnSrv 로 서명한 뒤 전송한다. 서명된 트렌젝션을 먼저 저장해두기 때문에 전송 전에 실패해도 같은 트렌젝션을 다시 보낸다
+func (s *RotationSrv) signAndSend(ctx context.Context, rotation *store.Rotation, asset string, amount *big.Int, txn *types.LegacyTx) error {
+	sweep := findSweep(rotation, asset)
+	if sweep == nil {
+		nonce, err := s.chain.PendingNonceAt(ctx, common.HexToAddress(rotation.OldAddress))
+		if err != nil {
+			return errs.InternalServerErr(err)
+		}
+		txn.Nonce = nonce
+
+		serializedTxn, err := types.NewTx(txn).MarshalBinary()
+		if err != nil {
+			return errs.InternalServerErr(err)
+		}
+		signedTxnRes, err := s.txnSrv.SignSerializedTxn(ctx, &dto.TxnReq{KeyID: rotation.OldKeyID, SerializedTxn: common.Bytes2Hex(serializedTxn)})
+		if err != nil {
+			return err
+		}
+
+		signedTxn := new(types.Transaction)
+		if err := signedTxn.UnmarshalBinary(common.FromHex(signedTxnRes.SignedTxn)); err != nil {
+			return errs.InternalServerErr(err)
+		}
+		rotation.Sweeps = append(rotation.Sweeps, store.Sweep{
+			Asset:     asset,
+			Amount:    amount.String(),
+			SignedTxn: signedTxnRes.SignedTxn,
+			TxHash:    signedTxn.Hash().Hex(),
+		})
+		if err := s.save(rotation); err != nil {
+			return errs.InternalServerErr(err)
+		}
+		sweep = &rotation.Sweeps[len(rotation.Sweeps)-1]
+	}
+
+	signedTxn := new(types.Transaction)
+	if err := signedTxn.UnmarshalBinary(common.FromHex(sweep.SignedTxn)); err != nil {
+		return errs.InternalServerErr(err)
+	}
+	if err := s.chain.SendTransaction(ctx, signedTxn); err != nil {
+		// 이전 시도에서 이미 전송되어 채굴된 경우
+		if _, receiptErr := s.chain.TransactionReceipt(ctx, signedTxn.Hash()); receiptErr != nil {
+			return errs.InternalServerErr(err)
+		}
+	}
+
+	sweep.Sent = true
+	if err := s.save(rotation); err != nil {
+		return errs.InternalServerErr(err)
+	}
+	return nil
+}
+
+// 전송은 되었지만 아직 receipt 가 없는 스윕 트렌젝션들의 최대 수수료 합
+func (s *RotationSrv) pendingFees(ctx context.Context, rotation *store.Rotation) (*big.Int, error) {
+	fees := new(big.Int)
+	for _, sweep := range rotation.Sweeps {
+		if !sweep.Sent || sweep.Asset == nativeAsset {
+			continue
+		}
+		signedTxn := new(types.Transaction)
+		if err := signedTxn.UnmarshalBinary(common.FromHex(sweep.SignedTxn)); err != nil {
+			return nil, errs.InternalServerErr(err)
+		}
+		if _, err := s.chain.TransactionReceipt(ctx, signedTxn.Hash()); err == nil {
+			continue
+		} else if !errors.Is(err, ethereum.NotFound) {
+			return nil, errs.InternalServerErr(err)
+		}
+		fees.Add(fees, new(big.Int).Mul(signedTxn.GasPrice(), new(big.Int).SetUint64(signedTxn.Gas())))
+	}
+	return fees, nil
+}
+
+func (s *RotationSrv) save(rotation *store.Rotation) error {
+	rotation.UpdatedAt = timeutil.FormatNow()
+	return s.store.Save(*rotation)
+}
+
+func findSweep(rotation *store.Rotation, asset string) *store.Sweep {
+	for i := range rotation.Sweeps {
+		if rotation.Sweeps[i].Asset == asset {
+			return &rotation.Sweeps[i]
+		}
+	}
+	return nil
+}
+
+func toRotationRes(rotation store.Rotation) *dto.RotationRes {
+	sweeps := make([]dto.SweepRes, len(rotation.Sweeps))
+	for i, sweep := range rotation.Sweeps {
+		sweeps[i] = dto.SweepRes{Asset: sweep.Asset, Amount: sweep.Amount, TxHash: sweep.TxHash, Sent: sweep.Sent}
+	}
+	return &dto.RotationRes{
+		OldKeyID:   rotation.OldKeyID,
+		OldAddress: rotation.OldAddress,
+		NewKeyID:   rotation.NewKeyID,
+		NewAddress: rotation.NewAddress,
+		Step:       rotation.Step,
+		Sweeps:     sweeps,
+		StartedAt:  rotation.StartedAt,
+		UpdatedAt:  rotation.UpdatedAt,
+		LastError:  rotation.LastError,
+	}
+}
@@ -2,17 +2,22 @@ package srv
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"kms/wallet/app/api/model/dto"
+	"kms/wallet/app/metrics"
+	"kms/wallet/app/tracing"
 	"kms/wallet/common/errs"
 	"kms/wallet/common/utils/ethutil"
 	"math/big"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/holiman/uint256"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type TxnSrv struct {
@@ -70,42 +75,166 @@ type BlobTxnOptionalSig struct {
 	V, R, S    *big.Int             `rlp:"optional"`
 }
 
+const txnBatchConcurrency = 10
+
 func NewTxnSrv(chainID *big.Int, kmsSrv *KmsSrv) *TxnSrv {
 	return &TxnSrv{chainID, kmsSrv}
 }
 
 // 서명되지 않은 트렌젝션을 받아서, 서명한뒤 리턴
-func (s *TxnSrv) SignSerializedTxn(txnDTO *dto.TxnReq) (*dto.SingedTxnRes, error) {
+func (s *TxnSrv) SignSerializedTxn(ctx context.Context, txnDTO *dto.TxnReq) (_ *dto.SingedTxnRes, err error) {
+	ctx, span := tracing.Start(ctx, "TxnSrv.SignSerializedTxn", tracing.KeyID(txnDTO.KeyID), tracing.ChainID(s.chainID))
+	defer func() { tracing.End(span, err) }()
+
 	// 퍼블릭 키에 대한 요청 먼저 고루틴으로
-	var (
-		pubKey  []byte
-		errChan = make(chan error)
-	)
+	pubKeyChan := make(chan []byte, 1)
+	errChan := make(chan error, 1)
 	go func() {
-		var err error
-		pubKey, err = s.kmsSrv.GetPubkey(&dto.KeyIdReq{KeyID: txnDTO.KeyID})
-		if err != nil {
-			errChan <- err
-			return
-		}
-		errChan <- nil
+		pubKey, err := s.kmsSrv.GetPubkey(ctx, &dto.KeyIdReq{KeyID: txnDTO.KeyID})
+		pubKeyChan <- pubKey
+		errChan <- err
 	}()
+	// 서명을 다시 받아오는 경우에도 고루틴 결과는 한번만 읽는다
+	getPubKey := sync.OnceValues(func() ([]byte, error) {
+		return <-pubKeyChan, <-errChan
+	})
 
 	parsedTxn, err := s.parseTxn(txnDTO.SerializedTxn)
 	if err != nil {
 		return nil, errs.InvalidTxnErr(err)
 	}
-
-	signer := types.NewCancunSigner(s.chainID)
-	txnMsg := signer.Hash(parsedTxn).Bytes()
+	span.SetAttributes(tracing.TxType(parsedTxn.Type()))
 
 	// ret, _ := json.MarshalIndent(parsedTxn, "", "\t")
 	// fmt.Println("parsed Txn: ", string(ret))
 
+	return s.signTxn(ctx, txnDTO.KeyID, parsedTxn, getPubKey)
+}
+
+// 여러 트렌젝션을 한번에 서명. 같은 keyID 의 public key 는 한번만 조회하고, 결과는 요청 순서대로 항목별로 리턴한다
+func (s *TxnSrv) SignSerializedTxnBatch(ctx context.Context, batchDTO *dto.TxnBatchReq) *dto.SignedTxnBatchRes {
+	ctx, span := tracing.Start(ctx, "TxnSrv.SignSerializedTxnBatch", tracing.ChainID(s.chainID), attribute.Int("wallet.batch.size", len(batchDTO.Txns)))
+	defer span.End()
+
+	var (
+		results    = make([]dto.SignedTxnBatchItemRes, len(batchDTO.Txns))
+		parsedTxns = make([]*types.Transaction, len(batchDTO.Txns))
+		keyIDs     []string
+		pubKeys    = make(map[string][]byte)
+		pubKeyErrs = make(map[string]error)
+		sem        = make(chan struct{}, txnBatchConcurrency)
+		mutex      sync.Mutex
+		wg         sync.WaitGroup
+	)
+
+	// 1. 항목별 검증 및 파싱
+	for i := range batchDTO.Txns {
+		txnDTO := &batchDTO.Txns[i]
+		results[i] = dto.SignedTxnBatchItemRes{Index: i, KeyID: txnDTO.KeyID}
+		if err := dto.Validate(txnDTO); err != nil {
+			results[i].Error = dto.NewItemErrRes(err)
+			continue
+		}
+		parsedTxn, err := s.parseTxn(txnDTO.SerializedTxn)
+		if err != nil {
+			results[i].Error = dto.NewItemErrRes(errs.InvalidTxnErr(err))
+			continue
+		}
+		parsedTxns[i] = parsedTxn
+		if _, ok := pubKeys[txnDTO.KeyID]; !ok {
+			pubKeys[txnDTO.KeyID] = nil
+			keyIDs = append(keyIDs, txnDTO.KeyID)
+		}
+	}
+
+	// 2. keyID 별로 public key 를 한번씩만 조회
+	for _, keyID := range keyIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(keyID string) {
+			defer func() { <-sem; wg.Done() }()
+			pubKey, err := s.kmsSrv.GetPubkey(ctx, &dto.KeyIdReq{KeyID: keyID})
+			mutex.Lock()
+			defer mutex.Unlock()
+			pubKeys[keyID], pubKeyErrs[keyID] = pubKey, err
+		}(keyID)
+	}
+	wg.Wait()
+
+	for i := range results {
+		if results[i].Error == nil {
+			if err := pubKeyErrs[results[i].KeyID]; err != nil {
+				results[i].Error = dto.NewItemErrRes(err)
+			}
+		}
+	}
+	if batchDTO.AllOrNothing && countFailed(results) > 0 {
+		return newSignedTxnBatchRes(results, true)
+	}
+
+	// 3. 서명
+	for i := range results {
+		if results[i].Error != nil {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			keyID := results[i].KeyID
+			signedTxnRes, err := s.signTxn(ctx, keyID, parsedTxns[i], func() ([]byte, error) { return pubKeys[keyID], nil })
+			if err != nil {
+				results[i].Error = dto.NewItemErrRes(err)
+				return
+			}
+			results[i].SignedTxn = signedTxnRes.SignedTxn
+		}(i)
+	}
+	wg.Wait()
+
+	if batchDTO.AllOrNothing && countFailed(results) > 0 {
+		return newSignedTxnBatchRes(results, true)
+	}
+	return newSignedTxnBatchRes(results, false)
+}
+
+// aborted 이면 성공한 항목의 서명도 버린다
+func newSignedTxnBatchRes(results []dto.SignedTxnBatchItemRes, aborted bool) *dto.SignedTxnBatchRes {
+	batchRes := &dto.SignedTxnBatchRes{Aborted: aborted, Results: results}
+	for i := range results {
+		if aborted {
+			results[i].SignedTxn = ""
+		}
+		if results[i].Error != nil {
+			batchRes.Failed++
+		} else if !aborted {
+			batchRes.Succeeded++
+		}
+	}
+	return batchRes
+}
+
+func countFailed(results []dto.SignedTxnBatchItemRes) (failed int) {
+	for _, result := range results {
+		if result.Error != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// kms 로 서명한 뒤 v 값을 찾아 서명된 트렌젝션을 만든다. getPubKey 는 첫 서명을 받은 뒤에 호출된다
+func (s *TxnSrv) signTxn(ctx context.Context, keyID string, parsedTxn *types.Transaction, getPubKey func() ([]byte, error)) (_ *dto.SingedTxnRes, err error) {
+	ctx, span := tracing.Start(ctx, "TxnSrv.signTxn", tracing.KeyID(keyID), tracing.ChainID(s.chainID), tracing.TxType(parsedTxn.Type()))
+	defer func() { tracing.End(span, err) }()
+
+	signer := types.NewCancunSigner(s.chainID)
+	txnMsg := signer.Hash(parsedTxn).Bytes()
+
 	// kms로부터 서명을 받아온다
 	var retry int
 	for {
-		R, S, err := s.kmsSrv.Sign(txnDTO.KeyID, txnMsg)
+		R, S, err := s.kmsSrv.Sign(ctx, keyID, txnMsg)
 		if err != nil {
 			return nil, err
 		}
@@ -120,7 +249,8 @@ func (s *TxnSrv) SignSerializedTxn(txnDTO *dto.TxnReq) (*dto.SingedTxnRes, error
 		}
 
 		// V 값을 유추해서 완전한 이더리움 서명을 만든다
-		if err := <-errChan; err != nil {
+		pubKey, err := getPubKey()
+		if err != nil {
 			return nil, err
 		}
 
@@ -134,6 +264,7 @@ func (s *TxnSrv) SignSerializedTxn(txnDTO *dto.TxnReq) (*dto.SingedTxnRes, error
 				return nil, errs.InternalServerErr(fmt.Errorf("invalid signature more than 5 times"))
 			}
 			retry++
+			metrics.IncSignRetry()
 			// v값이 0 혹은 1 에서 안나오면 3 혹은 4 인데 evm 에서는 해당 값이 나오는 서명값은 invalid 한 것으로 취급함으로 서명 r, s 값부터 다시 받아와야 한다.
 			continue
 		}
@@ -148,6 +279,7 @@ func (s *TxnSrv) SignSerializedTxn(txnDTO *dto.TxnReq) (*dto.SingedTxnRes, error
 		if err != nil {
 			return nil, errs.InternalServerErr(err)
 		}
+		metrics.IncSignByKey(keyID)
 		return &dto.SingedTxnRes{SignedTxn: "0x" + common.Bytes2Hex(byteSignedTxn)}, nil
 	}
 }
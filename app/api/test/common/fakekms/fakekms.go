@@ -0,0 +1,661 @@
+package fakekms
+
+// 테스트용 in-memory aws kms. secp256k1 키 생성, 외부키 주입, 서명을 실제로 수행하고
+// 각 api 호출에 에러를 주입할 수 있다.
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"kms/wallet/app/kmsclient"
+	"kms/wallet/common/utils/keyutil"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	Region    = "us-east-1"
+	AccountID = "111122223333"
+)
+
+var _ kmsclient.Client = (*FakeKms)(nil)
+
+type key struct {
+	id          string
+	pk          *ecdsa.PrivateKey
+	metadata    types.KeyMetadata
+	tags        []types.Tag
+	policy      string
+	wrappingKey *rsa.PrivateKey
+	importToken []byte
+}
+
+type FakeKms struct {
+	region  string
+	account string
+	peers   map[string]*FakeKms // ReplicateKey 로 복제할 수 있는 다른 region
+	keys    map[string]*key
+	faults  map[string][]error
+	calls   map[string]int
+	latency map[string]time.Duration
+	mutex   sync.Mutex
+}
+
+func New() *FakeKms {
+	return NewIn(Region, AccountID)
+}
+
+// region, 계정이 다른 kms (ex. 여러 backend 테스트)
+func NewIn(region, account string) *FakeKms {
+	return &FakeKms{
+		region:  region,
+		account: account,
+		peers:   make(map[string]*FakeKms),
+		keys:    make(map[string]*key),
+		faults:  make(map[string][]error),
+		calls:   make(map[string]int),
+		latency: make(map[string]time.Duration),
+	}
+}
+
+// 다중 리전 키를 peer 의 region 에 복제할 수 있도록 한다 (같은 계정)
+func (f *FakeKms) AddReplicaRegion(peer *FakeKms) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.peers[peer.region] = peer
+}
+
+// op(ex. "CreateKey") 의 다음 호출들이 순서대로 errs 를 리턴하도록 한다
+func (f *FakeKms) FailNext(op string, errs ...error) {
+	f.FailNextAfter(op, 0, errs...)
+}
+
+// skip 번의 호출은 정상 처리한 뒤 그 다음 호출들이 순서대로 errs 를 리턴하도록 한다
+func (f *FakeKms) FailNextAfter(op string, skip int, errs ...error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for i := 0; i < skip; i++ {
+		f.faults[op] = append(f.faults[op], nil)
+	}
+	f.faults[op] = append(f.faults[op], errs...)
+}
+
+// op 의 모든 호출이 d 만큼 지연된 뒤 처리되도록 한다
+func (f *FakeKms) SetLatency(op string, d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.latency[op] = d
+}
+
+func (f *FakeKms) Calls(op string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.calls[op]
+}
+
+func (f *FakeKms) KeyState(keyID string) types.KeyState {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if k, ok := f.keys[keyID]; ok {
+		return k.metadata.KeyState
+	}
+	return ""
+}
+
+func (f *FakeKms) KeyIDs() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	keyIDs := make([]string, 0, len(f.keys))
+	for keyID := range f.keys {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+	return keyIDs
+}
+
+// 호출 횟수를 기록하고 주입된 에러가 있으면 리턴한다. ctx 가 지연 도중 끝나면 ctx 에러를 리턴한다. mutex 를 잡은 상태에서 호출해야 한다
+func (f *FakeKms) enter(ctx context.Context, op string) error {
+	f.calls[op]++
+	if d := f.latency[op]; d > 0 {
+		// 지연되는 동안 다른 호출이 처리될 수 있도록 mutex 를 잠시 놓는다
+		f.mutex.Unlock()
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			f.mutex.Lock()
+			return ctx.Err()
+		}
+		f.mutex.Lock()
+	}
+	if queued := f.faults[op]; len(queued) > 0 {
+		f.faults[op] = queued[1:]
+		return queued[0]
+	}
+	return nil
+}
+
+func (f *FakeKms) arn(keyID string) string {
+	return fmt.Sprintf("arn:aws:kms:%s:%s:key/%s", f.region, f.account, keyID)
+}
+
+// keyID 는 key id 혹은 이 kms 의 key ARN
+func (f *FakeKms) get(keyID *string) (*key, error) {
+	id := strings.TrimPrefix(aws.ToString(keyID), f.arn(""))
+	if k, ok := f.keys[id]; ok {
+		return k, nil
+	}
+	return nil, &types.NotFoundException{Message: aws.String(fmt.Sprintf("Key '%s' does not exist", f.arn(id)))}
+}
+
+func (f *FakeKms) usable(k *key) error {
+	switch k.metadata.KeyState {
+	case types.KeyStateEnabled:
+		return nil
+	case types.KeyStateDisabled:
+		return &types.DisabledException{Message: aws.String(fmt.Sprintf("%s is disabled.", f.arn(k.id)))}
+	default:
+		return &types.KMSInvalidStateException{Message: aws.String(fmt.Sprintf("%s is %s", f.arn(k.id), k.metadata.KeyState))}
+	}
+}
+
+// policy 가 없으면 계정의 IAM 권한으로 모든 작업을 허용하는 기본 policy
+func (f *FakeKms) keyPolicy(policy *string) (string, error) {
+	if aws.ToString(policy) == "" {
+		return fmt.Sprintf(`{"Version":"2012-10-17","Id":"key-default-1","Statement":[{"Sid":"Enable IAM User Permissions","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::%s:root"},"Action":"kms:*","Resource":"*"}]}`, f.account), nil
+	}
+	if !json.Valid([]byte(*policy)) {
+		return "", &types.MalformedPolicyDocumentException{Message: aws.String("the policy is not valid json")}
+	}
+	return *policy, nil
+}
+
+func newKeyID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func (f *FakeKms) CreateKey(ctx context.Context, params *kms.CreateKeyInput, optFns ...func(*kms.Options)) (*kms.CreateKeyOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "CreateKey"); err != nil {
+		return nil, err
+	}
+
+	k := &key{id: newKeyID()}
+	if aws.ToBool(params.MultiRegion) {
+		k.id = "mrk-" + strings.ReplaceAll(newKeyID(), "-", "")
+	}
+	k.metadata = types.KeyMetadata{
+		KeyId:        aws.String(k.id),
+		Arn:          aws.String(f.arn(k.id)),
+		AWSAccountId: aws.String(f.account),
+		CreationDate: aws.Time(time.Now()),
+		KeySpec:      params.KeySpec,
+		KeyUsage:     params.KeyUsage,
+		Origin:       params.Origin,
+		Description:  params.Description,
+	}
+	k.tags = append(k.tags, params.Tags...)
+	policy, err := f.keyPolicy(params.Policy)
+	if err != nil {
+		return nil, err
+	}
+	k.policy = policy
+	if aws.ToBool(params.MultiRegion) {
+		k.metadata.MultiRegion = aws.Bool(true)
+		k.metadata.MultiRegionConfiguration = &types.MultiRegionConfiguration{
+			MultiRegionKeyType: types.MultiRegionKeyTypePrimary,
+			PrimaryKey:         &types.MultiRegionKey{Arn: k.metadata.Arn, Region: aws.String(f.region)},
+		}
+	}
+	if params.Origin == types.OriginTypeExternal {
+		k.metadata.KeyState = types.KeyStatePendingImport
+	} else {
+		pk, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		k.pk = pk
+		k.metadata.Origin = types.OriginTypeAwsKms
+		k.metadata.KeyState = types.KeyStateEnabled
+		k.metadata.Enabled = true
+	}
+	f.keys[k.id] = k
+
+	metadata := k.metadata
+	return &kms.CreateKeyOutput{KeyMetadata: &metadata}, nil
+}
+
+// 같은 key id, 같은 키로 peer region 에 replica 를 만든다
+// aws 처럼 tag 와 key policy 는 원래 키에서 복사하지 않고 요청한 값을 쓴다
+func (f *FakeKms) ReplicateKey(ctx context.Context, params *kms.ReplicateKeyInput, optFns ...func(*kms.Options)) (*kms.ReplicateKeyOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "ReplicateKey"); err != nil {
+		return nil, err
+	}
+
+	k, err := f.get(params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	multiRegion := k.metadata.MultiRegionConfiguration
+	if multiRegion == nil || multiRegion.MultiRegionKeyType != types.MultiRegionKeyTypePrimary {
+		return nil, &types.UnsupportedOperationException{Message: aws.String(fmt.Sprintf("%s is not a multi-Region primary key", f.arn(k.id)))}
+	}
+	region := aws.ToString(params.ReplicaRegion)
+	peer, ok := f.peers[region]
+	if !ok {
+		return nil, &types.UnsupportedOperationException{Message: aws.String(fmt.Sprintf("region %s is not enabled", region))}
+	}
+
+	peer.mutex.Lock()
+	defer peer.mutex.Unlock()
+	if _, ok := peer.keys[k.id]; ok {
+		return nil, &types.AlreadyExistsException{Message: aws.String(fmt.Sprintf("%s already exists", peer.arn(k.id)))}
+	}
+	replica := &key{id: k.id, pk: k.pk, tags: append([]types.Tag{}, params.Tags...)}
+	if replica.policy, err = peer.keyPolicy(params.Policy); err != nil {
+		return nil, err
+	}
+	replica.metadata = k.metadata
+	replica.metadata.Arn = aws.String(peer.arn(k.id))
+	replica.metadata.MultiRegionConfiguration = &types.MultiRegionConfiguration{
+		MultiRegionKeyType: types.MultiRegionKeyTypeReplica,
+		PrimaryKey:         multiRegion.PrimaryKey,
+	}
+	peer.keys[k.id] = replica
+
+	k.metadata.MultiRegionConfiguration = &types.MultiRegionConfiguration{
+		MultiRegionKeyType: types.MultiRegionKeyTypePrimary,
+		PrimaryKey:         multiRegion.PrimaryKey,
+		ReplicaKeys:        append(append([]types.MultiRegionKey{}, multiRegion.ReplicaKeys...), types.MultiRegionKey{Arn: replica.metadata.Arn, Region: aws.String(region)}),
+	}
+
+	metadata := replica.metadata
+	return &kms.ReplicateKeyOutput{ReplicaKeyMetadata: &metadata}, nil
+}
+
+func (f *FakeKms) DescribeKey(ctx context.Context, params *kms.DescribeKeyInput, optFns ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "DescribeKey"); err != nil {
+		return nil, err
+	}
+
+	k, err := f.get(params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	metadata := k.metadata
+	return &kms.DescribeKeyOutput{KeyMetadata: &metadata}, nil
+}
+
+func (f *FakeKms) DisableKey(ctx context.Context, params *kms.DisableKeyInput, optFns ...func(*kms.Options)) (*kms.DisableKeyOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "DisableKey"); err != nil {
+		return nil, err
+	}
+
+	k, err := f.get(params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	if k.metadata.KeyState != types.KeyStateEnabled && k.metadata.KeyState != types.KeyStateDisabled {
+		return nil, f.usable(k)
+	}
+	k.metadata.KeyState, k.metadata.Enabled = types.KeyStateDisabled, false
+	return &kms.DisableKeyOutput{}, nil
+}
+
+func (f *FakeKms) EnableKey(ctx context.Context, params *kms.EnableKeyInput, optFns ...func(*kms.Options)) (*kms.EnableKeyOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "EnableKey"); err != nil {
+		return nil, err
+	}
+
+	k, err := f.get(params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	if k.metadata.KeyState != types.KeyStateEnabled && k.metadata.KeyState != types.KeyStateDisabled {
+		return nil, f.usable(k)
+	}
+	k.metadata.KeyState, k.metadata.Enabled = types.KeyStateEnabled, true
+	return &kms.EnableKeyOutput{}, nil
+}
+
+func (f *FakeKms) GetParametersForImport(ctx context.Context, params *kms.GetParametersForImportInput, optFns ...func(*kms.Options)) (*kms.GetParametersForImportOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "GetParametersForImport"); err != nil {
+		return nil, err
+	}
+
+	k, err := f.get(params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	if k.metadata.Origin != types.OriginTypeExternal || k.metadata.KeyState != types.KeyStatePendingImport {
+		return nil, &types.UnsupportedOperationException{Message: aws.String("key is not waiting for import")}
+	}
+
+	wrappingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	wrappingPubKey, err := x509.MarshalPKIXPublicKey(&wrappingKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	k.wrappingKey = wrappingKey
+	k.importToken = make([]byte, 32)
+	rand.Read(k.importToken)
+
+	return &kms.GetParametersForImportOutput{
+		KeyId:             aws.String(k.id),
+		PublicKey:         wrappingPubKey,
+		ImportToken:       k.importToken,
+		ParametersValidTo: aws.Time(time.Now().Add(24 * time.Hour)),
+	}, nil
+}
+
+func (f *FakeKms) ImportKeyMaterial(ctx context.Context, params *kms.ImportKeyMaterialInput, optFns ...func(*kms.Options)) (*kms.ImportKeyMaterialOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "ImportKeyMaterial"); err != nil {
+		return nil, err
+	}
+
+	k, err := f.get(params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	if k.wrappingKey == nil || string(k.importToken) != string(params.ImportToken) {
+		return nil, &types.InvalidImportTokenException{Message: aws.String("import token is invalid")}
+	}
+
+	der, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, k.wrappingKey, params.EncryptedKeyMaterial, nil)
+	if err != nil {
+		return nil, &types.InvalidCiphertextException{Message: aws.String(err.Error())}
+	}
+	pk, err := keyutil.ParsePKCS8(der)
+	if err != nil {
+		return nil, &types.IncorrectKeyMaterialException{Message: aws.String(err.Error())}
+	}
+
+	k.pk = pk
+	k.metadata.KeyState, k.metadata.Enabled = types.KeyStateEnabled, true
+	return &kms.ImportKeyMaterialOutput{}, nil
+}
+
+type subjectPublicKeyInfo struct {
+	Algorithm struct {
+		Algorithm  asn1.ObjectIdentifier
+		Parameters asn1.ObjectIdentifier
+	}
+	PublicKey asn1.BitString
+}
+
+func (f *FakeKms) GetPublicKey(ctx context.Context, params *kms.GetPublicKeyInput, optFns ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "GetPublicKey"); err != nil {
+		return nil, err
+	}
+
+	k, err := f.get(params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.usable(k); err != nil {
+		return nil, err
+	}
+
+	var spki subjectPublicKeyInfo
+	spki.Algorithm.Algorithm = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	spki.Algorithm.Parameters = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+	pubKey := crypto.FromECDSAPub(&k.pk.PublicKey)
+	spki.PublicKey = asn1.BitString{Bytes: pubKey, BitLength: len(pubKey) * 8}
+	der, err := asn1.Marshal(spki)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.GetPublicKeyOutput{
+		KeyId:     k.metadata.Arn,
+		KeySpec:   k.metadata.KeySpec,
+		KeyUsage:  k.metadata.KeyUsage,
+		PublicKey: der,
+	}, nil
+}
+
+func (f *FakeKms) ListKeys(ctx context.Context, params *kms.ListKeysInput, optFns ...func(*kms.Options)) (*kms.ListKeysOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "ListKeys"); err != nil {
+		return nil, err
+	}
+
+	keyIDs := make([]string, 0, len(f.keys))
+	for keyID := range f.keys {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+
+	start := 0
+	if params.Marker != nil {
+		var err error
+		if start, err = strconv.Atoi(*params.Marker); err != nil || start > len(keyIDs) {
+			return nil, &types.InvalidMarkerException{Message: aws.String("invalid marker")}
+		}
+	}
+	limit := 100
+	if params.Limit != nil {
+		limit = int(*params.Limit)
+	}
+	end := start + limit
+	if end > len(keyIDs) {
+		end = len(keyIDs)
+	}
+
+	output := &kms.ListKeysOutput{}
+	for _, keyID := range keyIDs[start:end] {
+		output.Keys = append(output.Keys, types.KeyListEntry{KeyId: aws.String(keyID), KeyArn: f.keys[keyID].metadata.Arn})
+	}
+	if end < len(keyIDs) {
+		output.Truncated = true
+		output.NextMarker = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
+}
+
+func (f *FakeKms) ListResourceTags(ctx context.Context, params *kms.ListResourceTagsInput, optFns ...func(*kms.Options)) (*kms.ListResourceTagsOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "ListResourceTags"); err != nil {
+		return nil, err
+	}
+
+	k, err := f.get(params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	// aws 처럼 한번에 최대 50개씩 나눠서 리턴한다
+	start := 0
+	if params.Marker != nil {
+		if start, err = strconv.Atoi(*params.Marker); err != nil || start > len(k.tags) {
+			return nil, &types.InvalidMarkerException{Message: aws.String("invalid marker")}
+		}
+	}
+	limit := 50
+	if params.Limit != nil {
+		limit = int(*params.Limit)
+	}
+	end := start + limit
+	if end > len(k.tags) {
+		end = len(k.tags)
+	}
+
+	output := &kms.ListResourceTagsOutput{Tags: append([]types.Tag{}, k.tags[start:end]...)}
+	if end < len(k.tags) {
+		output.Truncated = true
+		output.NextMarker = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
+}
+
+func (f *FakeKms) TagResource(ctx context.Context, params *kms.TagResourceInput, optFns ...func(*kms.Options)) (*kms.TagResourceOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "TagResource"); err != nil {
+		return nil, err
+	}
+
+	k, err := f.get(params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range params.Tags {
+		replaced := false
+		for i := range k.tags {
+			if aws.ToString(k.tags[i].TagKey) == aws.ToString(tag.TagKey) {
+				k.tags[i].TagValue, replaced = tag.TagValue, true
+			}
+		}
+		if !replaced {
+			k.tags = append(k.tags, tag)
+		}
+	}
+	return &kms.TagResourceOutput{}, nil
+}
+
+func (f *FakeKms) GetKeyPolicy(ctx context.Context, params *kms.GetKeyPolicyInput, optFns ...func(*kms.Options)) (*kms.GetKeyPolicyOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "GetKeyPolicy"); err != nil {
+		return nil, err
+	}
+
+	k, err := f.get(params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.GetKeyPolicyOutput{Policy: aws.String(k.policy)}, nil
+}
+
+// 콘솔 등에서 policy 를 직접 바꾼 상황을 만든다
+func (f *FakeKms) PutKeyPolicyForTest(keyID, policy string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.keys[keyID].policy = policy
+}
+
+func (f *FakeKms) TagResourceForTest(keyID, tagKey, tagValue string) error {
+	_, err := f.TagResource(context.Background(), &kms.TagResourceInput{
+		KeyId: aws.String(keyID),
+		Tags:  []types.Tag{{TagKey: aws.String(tagKey), TagValue: aws.String(tagValue)}},
+	})
+	return err
+}
+
+// key 의 tag 를 map 으로 리턴
+func (f *FakeKms) Tags(keyID string) map[string]string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	tags := map[string]string{}
+	if k, ok := f.keys[keyID]; ok {
+		for _, tag := range k.tags {
+			tags[aws.ToString(tag.TagKey)] = aws.ToString(tag.TagValue)
+		}
+	}
+	return tags
+}
+
+func (f *FakeKms) ScheduleKeyDeletion(ctx context.Context, params *kms.ScheduleKeyDeletionInput, optFns ...func(*kms.Options)) (*kms.ScheduleKeyDeletionOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "ScheduleKeyDeletion"); err != nil {
+		return nil, err
+	}
+
+	k, err := f.get(params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	days := int32(30)
+	if params.PendingWindowInDays != nil {
+		days = *params.PendingWindowInDays
+	}
+	k.metadata.KeyState, k.metadata.Enabled = types.KeyStatePendingDeletion, false
+	deletionDate := time.Now().Add(time.Duration(days) * 24 * time.Hour)
+	k.metadata.DeletionDate = &deletionDate
+
+	return &kms.ScheduleKeyDeletionOutput{
+		KeyId:               k.metadata.Arn,
+		KeyState:            k.metadata.KeyState,
+		DeletionDate:        &deletionDate,
+		PendingWindowInDays: aws.Int32(days),
+	}, nil
+}
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+func (f *FakeKms) Sign(ctx context.Context, params *kms.SignInput, optFns ...func(*kms.Options)) (*kms.SignOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.enter(ctx, "Sign"); err != nil {
+		return nil, err
+	}
+
+	k, err := f.get(params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.usable(k); err != nil {
+		return nil, err
+	}
+	if params.MessageType != types.MessageTypeDigest || len(params.Message) != 32 {
+		return nil, &types.UnsupportedOperationException{Message: aws.String("fakekms only signs 32 byte digests")}
+	}
+
+	sig, err := crypto.Sign(params.Message, k.pk)
+	if err != nil {
+		return nil, err
+	}
+	der, err := asn1.Marshal(ecdsaSignature{R: new(big.Int).SetBytes(sig[:32]), S: new(big.Int).SetBytes(sig[32:64])})
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.SignOutput{
+		KeyId:            k.metadata.Arn,
+		Signature:        der,
+		SigningAlgorithm: params.SigningAlgorithm,
+	}, nil
+}
@@ -0,0 +1,125 @@
+package accountbatch_test
+
+// 계정 일괄 생성에서 일부가 실패했을 때 항목별 결과와 207 응답을 fakekms 로 확인하는 테스트
+
+import (
+	"bytes"
+	"encoding/json"
+	ctrl "kms/wallet/app/api/controller"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/common/logger"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type AccountBatchTestSuite struct {
+	suite.Suite
+	fake *fakekms.FakeKms
+	app  *fiber.App
+}
+
+func (t *AccountBatchTestSuite) SetupSuite() {
+	logger.Init("test")
+	dto.Init()
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *AccountBatchTestSuite) SetupTest() {
+	t.fake = fakekms.New()
+	t.app = fiber.New(fiber.Config{ErrorHandler: func(ctx *fiber.Ctx, err error) error {
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}})
+	ctrl.NewKmsCtrl(srv.NewKmsSrv(t.fake)).BootStrap(t.app)
+}
+
+func (t *AccountBatchTestSuite) createBatch(batchReq dto.AccountBatchReq) (int, dto.AccountBatchRes) {
+	data, err := json.Marshal(batchReq)
+	t.Require().NoError(err)
+	req := httptest.NewRequest(fiber.MethodPost, "/accounts/batch", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := t.app.Test(req, -1)
+	t.Require().NoError(err)
+
+	var batchRes dto.AccountBatchRes
+	if res.StatusCode != fiber.StatusBadRequest {
+		t.Require().NoError(json.NewDecoder(res.Body).Decode(&batchRes))
+	}
+	return res.StatusCode, batchRes
+}
+
+func (t *AccountBatchTestSuite) Test_AllSucceeded() {
+	status, batchRes := t.createBatch(dto.AccountBatchReq{
+		Count: 3,
+		Tags:  []dto.TagReq{{Key: "Name", Value: "wallet-{index}"}},
+	})
+	t.Require().Equal(fiber.StatusCreated, status)
+	t.Equal(3, batchRes.Succeeded)
+	t.Zero(batchRes.Failed)
+	t.Require().Len(batchRes.Results, 3)
+
+	for i, result := range batchRes.Results {
+		t.Equal(i, result.Index)
+		t.Nil(result.Error)
+		t.Require().NotNil(result.Account)
+		t.NotEmpty(result.Account.Address)
+		t.Equal("wallet-"+strconv.Itoa(i), t.fake.Tags(result.Account.KeyID)["Name"])
+	}
+}
+
+func (t *AccountBatchTestSuite) Test_PartialFailure() {
+	t.fake.FailNextAfter("CreateKey", 2, &types.KMSInternalException{Message: aws.String("injected failure")})
+
+	status, batchRes := t.createBatch(dto.AccountBatchReq{Count: 5})
+	t.Require().Equal(fiber.StatusMultiStatus, status)
+	t.Equal(4, batchRes.Succeeded)
+	t.Equal(1, batchRes.Failed)
+	t.Require().Len(batchRes.Results, 5)
+	t.Len(t.fake.KeyIDs(), 4)
+
+	// 동시에 만들기 때문에 어느 항목이 실패할지는 정해져 있지 않다
+	var failed []dto.AccountBatchItemRes
+	for i, result := range batchRes.Results {
+		t.Equal(i, result.Index)
+		if result.Error != nil {
+			t.Nil(result.Account)
+			failed = append(failed, result)
+		} else {
+			t.Require().NotNil(result.Account)
+			t.NotEmpty(result.Account.Address)
+		}
+	}
+	t.Require().Len(failed, 1)
+	t.Equal(fiber.StatusServiceUnavailable, failed[0].Error.Status)
+	t.True(failed[0].Error.Retryable)
+}
+
+func (t *AccountBatchTestSuite) Test_AllFailed() {
+	err := &types.KMSInternalException{Message: aws.String("injected failure")}
+	t.fake.FailNext("CreateKey", err, err)
+
+	status, batchRes := t.createBatch(dto.AccountBatchReq{Count: 2})
+	t.Require().Equal(fiber.StatusMultiStatus, status)
+	t.Zero(batchRes.Succeeded)
+	t.Equal(2, batchRes.Failed)
+	t.Empty(t.fake.KeyIDs())
+}
+
+func (t *AccountBatchTestSuite) Test_Validation() {
+	for _, batchReq := range []dto.AccountBatchReq{{Count: 0}, {Count: 501}} {
+		status, _ := t.createBatch(batchReq)
+		t.Equal(fiber.StatusBadRequest, status)
+	}
+	t.Zero(t.fake.Calls("CreateKey"))
+}
+
+func TestAccountBatchTestSuite(t *testing.T) {
+	suite.Run(t, new(AccountBatchTestSuite))
+}
@@ -0,0 +1,177 @@
+package awscreds_test
+
+// 고정 키, 기본 credential chain, assume-role 순서로 kms 자격증명을 만드는지 확인하는 테스트
+
+import (
+	"context"
+	"fmt"
+	"kms/wallet/app/kmsclient"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	roleARN    = "arn:aws:iam::111122223333:role/wallet-signer"
+	assumedARN = "arn:aws:sts::111122223333:assumed-role/wallet-signer/kms-wallet"
+)
+
+type AwsCredsTestSuite struct {
+	suite.Suite
+	stsServer *httptest.Server
+
+	mutex    sync.Mutex
+	requests []url.Values
+	authKeys []string // 요청에 서명한 access key
+}
+
+func (t *AwsCredsTestSuite) SetupSuite() {
+	// AssumeRole, GetCallerIdentity 에만 응답하는 sts 서버
+	t.stsServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		t.mutex.Lock()
+		t.requests = append(t.requests, r.PostForm)
+		_, credential, _ := strings.Cut(r.Header.Get("Authorization"), "Credential=")
+		accessKey, _, _ := strings.Cut(credential, "/")
+		t.authKeys = append(t.authKeys, accessKey)
+		t.mutex.Unlock()
+
+		w.Header().Set("Content-Type", "text/xml")
+		switch r.PostForm.Get("Action") {
+		case "AssumeRole":
+			fmt.Fprint(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>assumed-access-key</AccessKeyId>
+      <SecretAccessKey>assumed-secret-key</SecretAccessKey>
+      <SessionToken>assumed-session-token</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <Arn>`+assumedARN+`</Arn>
+      <AssumedRoleId>AROAEXAMPLE:kms-wallet</AssumedRoleId>
+    </AssumedRoleUser>
+  </AssumeRoleResult>
+  <ResponseMetadata><RequestId>assume-1</RequestId></ResponseMetadata>
+</AssumeRoleResponse>`)
+		case "GetCallerIdentity":
+			arn := "arn:aws:iam::111122223333:user/base"
+			if accessKey == "assumed-access-key" {
+				arn = assumedARN
+			}
+			fmt.Fprint(w, `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult>
+    <Arn>`+arn+`</Arn>
+    <UserId>AIDAEXAMPLE</UserId>
+    <Account>111122223333</Account>
+  </GetCallerIdentityResult>
+  <ResponseMetadata><RequestId>identity-1</RequestId></ResponseMetadata>
+</GetCallerIdentityResponse>`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+func (t *AwsCredsTestSuite) TearDownSuite() {
+	t.stsServer.Close()
+}
+
+// 각 테스트 실행전에 실행됨. 실행 환경의 자격증명이 섞이지 않도록 한다
+func (t *AwsCredsTestSuite) SetupTest() {
+	missing := filepath.Join(t.T().TempDir(), "missing")
+	for _, key := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE", "AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE"} {
+		t.T().Setenv(key, "")
+	}
+	t.T().Setenv("AWS_CONFIG_FILE", missing)
+	t.T().Setenv("AWS_SHARED_CREDENTIALS_FILE", missing)
+	t.T().Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	t.mutex.Lock()
+	t.requests, t.authKeys = nil, nil
+	t.mutex.Unlock()
+}
+
+func (t *AwsCredsTestSuite) Test_StaticKeys() {
+	t.T().Setenv("AWS_ACCESS_KEY_ID", "env-access-key")
+	t.T().Setenv("AWS_SECRET_ACCESS_KEY", "env-secret-key")
+
+	// 고정 키가 있으면 기본 chain 보다 먼저 사용한다
+	awsCfg, err := kmsclient.LoadAwsConfig(context.Background(), kmsclient.AwsConfig{
+		Region:    "ap-northeast-2",
+		AccessKey: "static-access-key",
+		SecretKey: "static-secret-key",
+	})
+	t.Require().NoError(err)
+	creds, err := awsCfg.Credentials.Retrieve(context.Background())
+	t.Require().NoError(err)
+	t.Equal("static-access-key", creds.AccessKeyID)
+	t.Equal("ap-northeast-2", awsCfg.Region)
+}
+
+func (t *AwsCredsTestSuite) Test_DefaultChain() {
+	t.T().Setenv("AWS_ACCESS_KEY_ID", "env-access-key")
+	t.T().Setenv("AWS_SECRET_ACCESS_KEY", "env-secret-key")
+
+	awsCfg, err := kmsclient.LoadAwsConfig(context.Background(), kmsclient.AwsConfig{Region: "ap-northeast-2"})
+	t.Require().NoError(err)
+	creds, err := awsCfg.Credentials.Retrieve(context.Background())
+	t.Require().NoError(err)
+	t.Equal("env-access-key", creds.AccessKeyID)
+
+	// 자격증명이 하나도 없으면 호출할 때 에러가 난다
+	t.T().Setenv("AWS_ACCESS_KEY_ID", "")
+	t.T().Setenv("AWS_SECRET_ACCESS_KEY", "")
+	awsCfg, err = kmsclient.LoadAwsConfig(context.Background(), kmsclient.AwsConfig{Region: "ap-northeast-2"})
+	t.Require().NoError(err)
+	_, err = awsCfg.Credentials.Retrieve(context.Background())
+	t.Error(err)
+}
+
+func (t *AwsCredsTestSuite) Test_AssumeRole() {
+	awsCfg, err := kmsclient.LoadAwsConfig(context.Background(), kmsclient.AwsConfig{
+		Region:        "ap-northeast-2",
+		AccessKey:     "base-access-key",
+		SecretKey:     "base-secret-key",
+		AssumeRoleARN: roleARN,
+		ExternalID:    "external-1",
+		SessionName:   "kms-wallet",
+		StsEndpoint:   t.stsServer.URL,
+	})
+	t.Require().NoError(err)
+
+	creds, err := awsCfg.Credentials.Retrieve(context.Background())
+	t.Require().NoError(err)
+	t.Equal("assumed-access-key", creds.AccessKeyID)
+	t.Equal("assumed-session-token", creds.SessionToken)
+
+	// 캐시된 임시 자격증명을 다시 사용한다
+	_, err = awsCfg.Credentials.Retrieve(context.Background())
+	t.Require().NoError(err)
+
+	identity, err := kmsclient.GetCallerIdentity(context.Background(), awsCfg, t.stsServer.URL)
+	t.Require().NoError(err)
+	t.Equal("111122223333", identity.Account)
+	t.Equal(assumedARN, identity.ARN)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.Require().Len(t.requests, 2)
+	assumeReq := t.requests[0]
+	t.Equal("AssumeRole", assumeReq.Get("Action"))
+	t.Equal(roleARN, assumeReq.Get("RoleArn"))
+	t.Equal("external-1", assumeReq.Get("ExternalId"))
+	t.Equal("kms-wallet", assumeReq.Get("RoleSessionName"))
+	// assume 은 기본 자격증명으로, 이후 호출은 assume 한 자격증명으로 서명한다
+	t.Equal([]string{"base-access-key", "assumed-access-key"}, t.authKeys)
+}
+
+func TestAwsCredsTestSuite(t *testing.T) {
+	suite.Run(t, new(AwsCredsTestSuite))
+}
@@ -0,0 +1,130 @@
+package awserr_test
+
+// kms 예외가 종류별로 정확한 에러 코드와 노출 가능한 메세지로 변환되는지 확인하는 테스트
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kms/wallet/common/errs"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	keyID = "1234abcd-12ab-34cd-56ef-1234567890ab"
+	arn   = "arn:aws:kms:ap-northeast-2:111122223333:key/" + keyID
+)
+
+type AwsErrTestSuite struct {
+	suite.Suite
+}
+
+// sdk 가 리턴하는 형태와 같이 OperationError 로 감싼다
+func wrap(err error) error {
+	return &smithy.OperationError{ServiceID: "KMS", OperationName: "Sign", Err: err}
+}
+
+func (t *AwsErrTestSuite) route(err error) *errs.CusErr {
+	var cusErr *errs.CusErr
+	t.Require().True(errors.As(errs.RouteAwsErr(wrap(err)), &cusErr), "%v", err)
+	return cusErr
+}
+
+func (t *AwsErrTestSuite) Test_Exceptions() {
+	msg := aws.String(arn + " is not usable")
+	tests := []struct {
+		err  error
+		kind errs.Kind
+	}{
+		{&types.NotFoundException{Message: aws.String("Key '" + arn + "' does not exist")}, errs.KindKeyNotFound},
+		{&types.InvalidArnException{Message: msg}, errs.KindBadRequest},
+		{&types.KMSInvalidStateException{Message: msg}, errs.KindKeyInvalid},
+		{&types.DisabledException{Message: msg}, errs.KindKeyDisabled},
+		{&types.KeyUnavailableException{Message: msg}, errs.KindKeyUnavailable},
+		{&types.InvalidKeyUsageException{Message: msg}, errs.KindKeyUsageInvalid},
+		{&types.UnsupportedOperationException{Message: msg}, errs.KindOperationUnsupported},
+		{&types.AlreadyExistsException{Message: msg}, errs.KindAlreadyExists},
+		{&types.IncorrectKeyMaterialException{Message: msg}, errs.KindKeyMaterialIncorrect},
+		{&types.IncorrectKeyException{Message: msg}, errs.KindKeyMaterialIncorrect},
+		{&types.InvalidCiphertextException{Message: msg}, errs.KindKeyMaterialIncorrect},
+		{&types.ExpiredImportTokenException{Message: msg}, errs.KindImportTokenExpired},
+		{&types.InvalidImportTokenException{Message: msg}, errs.KindImportTokenInvalid},
+		{&types.InvalidMarkerException{Message: msg}, errs.KindMarkerInvalid},
+		{&types.TagException{Message: msg}, errs.KindBadRequest},
+		{&types.MalformedPolicyDocumentException{Message: msg}, errs.KindBadRequest},
+		{&types.InvalidAliasNameException{Message: msg}, errs.KindBadRequest},
+		{&types.InvalidGrantIdException{Message: msg}, errs.KindBadRequest},
+		{&types.InvalidGrantTokenException{Message: msg}, errs.KindBadRequest},
+		{&types.KMSInvalidSignatureException{Message: msg}, errs.KindBadRequest},
+		{&types.KMSInvalidMacException{Message: msg}, errs.KindBadRequest},
+		{&types.DryRunOperationException{Message: msg}, errs.KindBadRequest},
+		{&types.LimitExceededException{Message: msg}, errs.KindKmsLimitExceeded},
+		{&types.KMSInternalException{Message: msg}, errs.KindKmsUnavailable},
+		{&types.DependencyTimeoutException{Message: msg}, errs.KindKmsUnavailable},
+		{&types.CloudHsmClusterNotActiveException{Message: msg}, errs.KindKeyStoreUnavailable},
+		{&types.CloudHsmClusterInvalidConfigurationException{Message: msg}, errs.KindKeyStoreUnavailable},
+		{&types.CustomKeyStoreInvalidStateException{Message: msg}, errs.KindKeyStoreUnavailable},
+		{&types.CustomKeyStoreNotFoundException{Message: msg}, errs.KindKeyStoreUnavailable},
+		{&types.XksProxyUriUnreachableException{Message: msg}, errs.KindKeyStoreUnavailable},
+		{&types.XksKeyNotFoundException{Message: msg}, errs.KindKeyStoreUnavailable},
+		{&types.IncorrectTrustAnchorException{Message: msg}, errs.KindKeyStoreUnavailable},
+		// 타입이 없는 공통 예외
+		{&smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}, errs.KindKmsThrottled},
+		{&smithy.GenericAPIError{Code: "ValidationException", Message: *msg}, errs.KindBadRequest},
+		{&smithy.GenericAPIError{Code: "AccessDeniedException", Message: *msg}, errs.KindKmsAccessDenied},
+		{&smithy.GenericAPIError{Code: "UnrecognizedClientException", Message: *msg}, errs.KindKmsAccessDenied},
+		{&smithy.GenericAPIError{Code: "ExpiredTokenException", Message: *msg}, errs.KindKmsAccessDenied},
+		{&smithy.GenericAPIError{Code: "SomethingNewException", Message: *msg}, errs.KindKmsError},
+	}
+
+	for _, test := range tests {
+		cusErr := t.route(test.err)
+		t.Equal(test.kind, cusErr.Kind, "%T %v", test.err, test.err)
+
+		// 4xx 는 메세지가 응답에 포함되므로 arn, 계정 id 가 노출되면 안된다
+		if cusErr.Kind.Status/100 == 4 {
+			t.NotContains(cusErr.Inner.Error(), "arn:", "%T", test.err)
+			t.NotContains(cusErr.Inner.Error(), "111122223333", "%T", test.err)
+		}
+	}
+}
+
+func (t *AwsErrTestSuite) Test_KeyIDInMessage() {
+	cusErr := t.route(&types.NotFoundException{Message: aws.String("Key '" + arn + "' does not exist")})
+	t.Equal(fmt.Sprintf("keyId '%v' not found", keyID), cusErr.Inner.Error())
+
+	cusErr = t.route(&types.DisabledException{Message: aws.String(arn + " is disabled.")})
+	t.Equal(fmt.Sprintf("keyId '%v' is disabled", keyID), cusErr.Inner.Error())
+
+	// 5xx 는 로그를 위해 원래 에러를 유지한다
+	internalErr := &types.KMSInternalException{Message: aws.String("internal")}
+	cusErr = t.route(internalErr)
+	t.ErrorIs(cusErr, internalErr)
+}
+
+func (t *AwsErrTestSuite) Test_NonApiErrors() {
+	tests := []struct {
+		err  error
+		kind errs.Kind
+	}{
+		{errs.ErrCircuitOpen, errs.KindKmsUnavailable},
+		{fmt.Errorf("%w: %w", errs.ErrKmsTimeout, context.DeadlineExceeded), errs.KindKmsUnavailable},
+		{context.DeadlineExceeded, errs.KindRequestTimeout},
+		{context.Canceled, errs.KindRequestCanceled},
+		{fmt.Errorf("connection reset"), errs.KindKmsError},
+		// 이미 분류된 에러는 그대로 리턴한다
+		{errs.KeyIdNotFoundErr(fmt.Errorf("keyId 'x' not found")), errs.KindKeyNotFound},
+	}
+	for _, test := range tests {
+		t.Equal(test.kind, t.route(test.err).Kind, "%v", test.err)
+	}
+}
+
+func TestAwsErrTestSuite(t *testing.T) {
+	suite.Run(t, new(AwsErrTestSuite))
+}
@@ -0,0 +1,311 @@
+package config_test
+
+// 설정 파일, 환경변수, flag 의 우선순위와 검증, --print-config 의 secret 가림을 확인하는 테스트
+
+import (
+	"bytes"
+	"fmt"
+	"kms/wallet/common/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+// 각 테스트 실행전에 실행됨. 필수 항목만 환경변수로 설정한다
+func (t *ConfigTestSuite) SetupTest() {
+	t.dir = t.T().TempDir()
+	for key, value := range map[string]string{
+		"ENV":            "dev",
+		"AWS_REGION":     "ap-northeast-2",
+		"AWS_ACCESS_KEY": "access-key-value",
+		"AWS_SECRET_KEY": "secret-key-value",
+		"CHAIN_ID":       "1",
+		"CONFIG_FILE":    "",
+	} {
+		t.T().Setenv(key, value)
+	}
+}
+
+func (t *ConfigTestSuite) writeFile(content string) string {
+	path := filepath.Join(t.dir, "config.yaml")
+	t.Require().NoError(os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func (t *ConfigTestSuite) Test_Defaults() {
+	cfg, printConfig, err := config.Load(nil)
+	t.Require().NoError(err)
+	t.False(printConfig)
+	t.Equal("dev", cfg.Environment)
+	t.Equal(7777, cfg.Server.Port)
+	t.Equal(30*time.Second, cfg.Server.RequestTimeout)
+	t.Equal(30*time.Second, cfg.Server.ShutdownTimeout)
+	t.True(cfg.Logging.Requests)
+	t.Equal(512, cfg.Logging.MaxValueLen)
+	t.Equal("", cfg.Kms.Endpoint)
+	t.Equal(uint64(1), cfg.Chains.ChainID)
+
+	t.Equal("kms-wallet", cfg.Kms.SessionName)
+
+	// local 환경은 로컬 kms 를 사용한다
+	cfg, _, err = config.Load([]string{"--env", "local"})
+	t.Require().NoError(err)
+	t.Equal("http://localhost:8080", cfg.Kms.Endpoint)
+
+	// 다른 환경은 KMS_ENDPOINT 로 지정한다
+	t.T().Setenv("KMS_ENDPOINT", "http://localhost:8081")
+	cfg, _, err = config.Load([]string{"--env", "prd"})
+	t.Require().NoError(err)
+	t.Equal("http://localhost:8081", cfg.Kms.Endpoint)
+}
+
+func (t *ConfigTestSuite) Test_Credentials() {
+	// 고정 키가 없으면 기본 credential chain 을 사용한다
+	t.T().Setenv("AWS_ACCESS_KEY", "")
+	t.T().Setenv("AWS_SECRET_KEY", "")
+	_, _, err := config.Load([]string{"--kms.assume_role_arn", "arn:aws:iam::111122223333:role/wallet-signer", "--kms.external_id", "ext-1"})
+	t.NoError(err)
+
+	for _, invalid := range [][]string{
+		{"--kms.access_key", "only-access-key"},
+		{"--kms.external_id", "ext-1"},
+		{"--kms.assume_role_arn", "wallet-signer"},
+		{"--kms.assume_role_arn", "arn:aws:iam::111122223333:role/wallet-signer", "--kms.session_name="},
+	} {
+		_, _, err := config.Load(invalid)
+		t.Error(err, invalid)
+	}
+}
+
+func (t *ConfigTestSuite) Test_KmsBackends() {
+	path := t.writeFile(`
+kms:
+  account: "111122223333"
+  backends:
+    dr:
+      region: us-west-2
+      account: "444455556666"
+      assume_role_arn: arn:aws:iam::444455556666:role/wallet-signer
+  key_backends:
+    key-1: dr
+`)
+	cfg, _, err := config.Load([]string{"--config", path})
+	t.Require().NoError(err)
+	t.Equal(map[string]config.KmsBackend{
+		"dr": {Region: "us-west-2", Account: "444455556666", AssumeRoleARN: "arn:aws:iam::444455556666:role/wallet-signer"},
+	}, cfg.Kms.Backends)
+	t.Equal(map[string]string{"key-1": "dr"}, cfg.Kms.KeyBackends)
+
+	// 환경변수에서는 항목을 '|' 로 구분한다
+	t.T().Setenv("KMS_BACKENDS", "dr=region:us-west-2|account:444455556666, local=region:us-east-1|endpoint:http://localhost:8080")
+	t.T().Setenv("KMS_KEY_BACKENDS", "key-1=dr,key-2=default")
+	cfg, _, err = config.Load(nil)
+	t.Require().NoError(err)
+	t.Equal(map[string]config.KmsBackend{
+		"dr":    {Region: "us-west-2", Account: "444455556666"},
+		"local": {Region: "us-east-1", Endpoint: "http://localhost:8080"},
+	}, cfg.Kms.Backends)
+
+	// replica region 은 같은 계정의 backend 가 있어야 한다
+	_, _, err = config.Load([]string{"--kms.account=444455556666", "--kms.replica_regions=us-west-2"})
+	t.NoError(err)
+
+	for _, invalid := range [][]string{
+		{"--kms.backends", "dr=zone:us-west-2"},
+		{"--kms.backends", "dr=account:444455556666"},
+		{"--kms.backends", "default=region:us-west-2"},
+		{"--kms.backends", "dr=region:us-west-2|account:4444"},
+		{"--kms.key_backends", "key-1=unknown"},
+		{"--kms.account", "account-1"},
+		{"--kms.replica_regions", "eu-west-1"},
+		{"--kms.replica_regions", "ap-northeast-2"},
+		{"--kms.account=111122223333", "--kms.replica_regions=us-west-2"},
+	} {
+		_, _, err := config.Load(invalid)
+		t.Error(err, invalid)
+	}
+}
+
+func (t *ConfigTestSuite) Test_KeyPolicy() {
+	const template = `
+kms:
+  key_policy:
+    template: signer
+    service_role_arn: arn:aws:iam::111122223333:role/wallet-signer
+    templates:
+      signer: |
+        {"Version": "2012-10-17", "Statement": [
+          {"Sid": "EnableRoot", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::${ACCOUNT_ID}:root"}, "Action": "kms:*", "Resource": "*"},
+          {"Sid": "AllowServiceSign", "Effect": "Allow", "Principal": {"AWS": "${SERVICE_ROLE_ARN}"}, "Action": "kms:Sign", "Resource": "*"}%v
+        ]}
+`
+	cfg, _, err := config.Load([]string{"--config", t.writeFile(fmt.Sprintf(template, ""))})
+	t.Require().NoError(err)
+	policy, err := cfg.Kms.KeyPolicy.Render("signer", "111122223333")
+	t.Require().NoError(err)
+	t.Contains(policy, `"arn:aws:iam::111122223333:root"`)
+	t.Contains(policy, `"arn:aws:iam::111122223333:role/wallet-signer"`)
+
+	for _, invalid := range []string{
+		`,{"Sid": "AllowAdmin", "Principal": {"AWS": "${ADMIN_ROLE_ARN}"}}`, // 설정하지 않은 값
+		`,{"Sid": "AllowOther", "Principal": {"AWS": "${OTHER_ROLE_ARN}"}}`, // 알 수 없는 placeholder
+		`,{"Sid": "AllowAdmin",}`,
+	} {
+		_, _, err := config.Load([]string{"--config", t.writeFile(fmt.Sprintf(template, invalid))})
+		t.ErrorContains(err, "kms.key_policy.templates", invalid)
+	}
+
+	path := t.writeFile(fmt.Sprintf(template, ""))
+	for _, invalid := range [][]string{
+		{"--config", path, "--kms.key_policy.template", "unknown"},
+		{"--config", path, "--kms.key_policy.service_role_arn", "wallet-signer"},
+		{"--kms.key_policy.template", "signer"},
+	} {
+		_, _, err := config.Load(invalid)
+		t.Error(err, invalid)
+	}
+}
+
+func (t *ConfigTestSuite) Test_Precedence() {
+	path := t.writeFile(`
+server:
+  port: 8000
+  request_timeouts:
+    /api/sign: 1m
+kms:
+  region: us-east-1
+  timeout: 2s
+chains:
+  chain_id: 5
+logging:
+  redact_routes:
+    /api/sign: "-resBody|serializedTxn"
+    /api/jobs:
+      mask: [callbackUrl]
+`)
+	t.T().Setenv("PORT", "8100")
+	t.T().Setenv("AWS_REGION", "")
+	t.T().Setenv("KMS_TIMEOUT", "3s")
+	t.T().Setenv("REQUEST_TIMEOUTS", "/api/sign=1m, /api/sign/txns=2m")
+
+	cfg, _, err := config.Load([]string{"--config", path, "--server.port=8200", "--logging.requests=false"})
+	t.Require().NoError(err)
+
+	// flag > 환경변수 > 설정 파일 > 기본값
+	t.Equal(8200, cfg.Server.Port)
+	t.Equal(3*time.Second, cfg.Kms.Timeout)
+	t.Equal("us-east-1", cfg.Kms.Region) // 빈 환경변수는 설정하지 않은 것으로 본다
+	t.Equal(uint64(1), cfg.Chains.ChainID)
+	t.False(cfg.Logging.Requests)
+	t.Equal(map[string]time.Duration{"/api/sign": time.Minute, "/api/sign/txns": 2 * time.Minute}, cfg.Server.RequestTimeouts)
+	t.Equal(map[string]config.RedactRoute{
+		"/api/sign": {Drop: []string{"resBody"}, Mask: []string{"serializedTxn"}},
+		"/api/jobs": {Mask: []string{"callbackUrl"}},
+	}, cfg.Logging.RedactRoutes)
+
+	// CONFIG_FILE 로도 지정할 수 있다
+	t.T().Setenv("CONFIG_FILE", path)
+	t.T().Setenv("PORT", "")
+	cfg, _, err = config.Load(nil)
+	t.Require().NoError(err)
+	t.Equal(8000, cfg.Server.Port)
+}
+
+func (t *ConfigTestSuite) Test_Environment() {
+	path := t.writeFile("env: stg\n")
+	t.T().Setenv("ENV", "")
+	os.Unsetenv("ENV") // env 파일은 이미 있는 환경변수를 덮어쓰지 않는다
+
+	// flag, ENV 가 없으면 설정 파일의 env 로 env 파일을 고른다
+	cfg, _, err := config.Load([]string{"--config", path})
+	t.Require().NoError(err)
+	t.Equal("stg", cfg.Environment)
+
+	// flag 가 설정 파일보다 우선한다
+	cfg, _, err = config.Load([]string{"--config", path, "--env", "dev"})
+	t.Require().NoError(err)
+	t.Equal("dev", cfg.Environment)
+
+	// env 파일이 env 파일을 고른 환경과 다른 환경을 지정하면 받지 않는다
+	envFile := filepath.Join(t.dir, ".env.stg")
+	t.Require().NoError(os.WriteFile(envFile, []byte("ENV=prd\n"), 0o600))
+	_, _, err = config.Load([]string{"--config", path, "--env-file", envFile})
+	t.ErrorContains(err, "env: 'prd' conflicts with 'stg'")
+}
+
+func (t *ConfigTestSuite) Test_ValidationReportsAllErrors() {
+	t.T().Setenv("AWS_REGION", "")
+	t.T().Setenv("REQUEST_TIMEOUTS", "/api/sign")
+	t.T().Setenv("LOG_REDACT_ROUTES", "=reqBody")
+	t.T().Setenv("LOG_MAX_VALUE_LEN", "abc")
+	t.T().Setenv("SHUTDOWN_TIMEOUT", "0s")
+	t.T().Setenv("JOB_STORE", "sqlite")
+	t.T().Setenv("SWEEP_TOKENS", "0x1234")
+
+	cfg, _, err := config.Load([]string{"--server.port=70000", "--server.request_timeout=soon"})
+	t.Require().Error(err)
+	t.NotNil(cfg)
+	for _, expected := range []string{
+		"server.request_timeouts (env REQUEST_TIMEOUTS)",
+		"logging.redact_routes (env LOG_REDACT_ROUTES)",
+		"logging.max_value_len (env LOG_MAX_VALUE_LEN)",
+		"server.request_timeout (flag)",
+		"server.port: must be between 1 and 65535",
+		"server.shutdown_timeout: must be positive",
+		"kms.region: required",
+		"jobs.db_path: required for sqlite job store",
+		"chains.sweep_tokens: invalid address '0x1234'",
+	} {
+		t.Contains(err.Error(), expected)
+	}
+
+	for _, invalid := range [][]string{{"--env", "qa"}, {"--server.error_codes", "numeric"}, {"--logging.max_value_len=-1"}, {"--unknown"}, {"extra"}} {
+		_, _, err := config.Load(invalid)
+		t.Error(err, invalid)
+	}
+}
+
+func (t *ConfigTestSuite) Test_InvalidFile() {
+	_, _, err := config.Load([]string{"--config", t.writeFile("server:\n  prot: 8000\n")})
+	t.ErrorContains(err, "prot")
+
+	_, _, err = config.Load([]string{"--config", filepath.Join(t.dir, "missing.yaml")})
+	t.Error(err)
+	_, _, err = config.Load([]string{"--env-file", filepath.Join(t.dir, ".env.missing")})
+	t.Error(err)
+}
+
+func (t *ConfigTestSuite) Test_PrintConfig() {
+	t.T().Setenv("JOB_WEBHOOK_SECRET", "webhook-secret-value")
+	t.T().Setenv("RPC_URL", "https://rpc.example.com/v1/api-key-value")
+
+	cfg, printConfig, err := config.Load([]string{"--print-config", "--health.rpc_urls", "https://rpc.example.com/v1/api-key-value"})
+	t.Require().NoError(err)
+	t.True(printConfig)
+
+	var out bytes.Buffer
+	t.Require().NoError(cfg.Print(&out))
+	printed := out.String()
+	for _, secret := range []string{"access-key-value", "secret-key-value", "webhook-secret-value", "api-key-value"} {
+		t.NotContains(printed, secret)
+	}
+	t.Contains(printed, "region: ap-northeast-2")
+	t.Contains(printed, "request_timeout: 30s")
+	t.Contains(printed, "secret_key: '[REDACTED]'")
+
+	// 출력할 때만 가리고 설정값은 그대로 둔다
+	t.Equal("secret-key-value", cfg.Kms.SecretKey)
+	t.Equal([]string{"https://rpc.example.com/v1/api-key-value"}, cfg.Health.RPCURLs)
+}
+
+func TestConfigTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigTestSuite))
+}
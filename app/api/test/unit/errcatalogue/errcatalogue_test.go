@@ -0,0 +1,111 @@
+package errcatalogue_test
+
+// 에러 카탈로그의 코드, http status 매핑과 ErrHandler 응답 형식을 확인하는 테스트
+
+import (
+	"encoding/json"
+	"fmt"
+	"kms/wallet/app/api/model/dto"
+	"kms/wallet/app/server"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrCatalogueTestSuite struct {
+	suite.Suite
+	app *fiber.App
+}
+
+func (t *ErrCatalogueTestSuite) SetupSuite() {
+	logger.Init("test")
+
+	t.app = fiber.New(fiber.Config{ErrorHandler: server.ErrHandler})
+	t.app.Get("/notfound", func(c *fiber.Ctx) error {
+		return errs.KeyIdNotFoundErr(fmt.Errorf("keyId 'abc' not found"))
+	})
+	t.app.Get("/frozen", func(c *fiber.Ctx) error {
+		return errs.WithDetails(errs.FrozenKeyErr(fmt.Errorf("keyId 'abc' is frozen")), map[string]any{"keyID": "abc"})
+	})
+	t.app.Get("/kms", func(c *fiber.Ctx) error {
+		return errs.UnhandledAwsKmsErr(fmt.Errorf("boom"))
+	})
+	t.app.Get("/plain", func(c *fiber.Ctx) error {
+		return fmt.Errorf("boom")
+	})
+}
+
+func (t *ErrCatalogueTestSuite) TearDownTest() {
+	errs.LegacyStatus = false
+}
+
+func (t *ErrCatalogueTestSuite) get(path string) (int, dto.ErrRes) {
+	res, err := t.app.Test(httptest.NewRequest(fiber.MethodGet, path, nil), -1)
+	t.Require().NoError(err)
+	var errRes dto.ErrRes
+	t.Require().NoError(json.NewDecoder(res.Body).Decode(&errRes))
+	t.Equal(res.StatusCode, errRes.Status)
+	return res.StatusCode, errRes
+}
+
+func (t *ErrCatalogueTestSuite) Test_Catalogue() {
+	codeFormat := regexp.MustCompile(`^[A-Z]+(_[A-Z]+)*$`)
+	codes := make(map[string]bool)
+	for _, kind := range errs.Catalogue {
+		t.Regexp(codeFormat, kind.Code)
+		t.False(codes[kind.Code], "duplicated code %v", kind.Code)
+		codes[kind.Code] = true
+		// 표준 http status 범위만 사용한다
+		t.True(kind.Status >= 400 && kind.Status < 600, "%v: %v", kind.Code, kind.Status)
+	}
+}
+
+func (t *ErrCatalogueTestSuite) Test_ErrRes() {
+	status, errRes := t.get("/notfound")
+	t.Equal(fiber.StatusNotFound, status)
+	t.Equal(errs.KindKeyNotFound.Code, errRes.Code)
+	t.False(errRes.Retryable)
+	t.Equal([]string{errs.Errs["KeyIdNotFoundErr"].Type, "keyId 'abc' not found"}, errRes.Message)
+
+	status, errRes = t.get("/frozen")
+	t.Equal(errs.KindKeyFrozen.Status, status)
+	t.Equal(map[string]any{"keyID": "abc"}, errRes.Details)
+
+	// 내부 에러 메세지는 노출하지 않는다
+	status, errRes = t.get("/kms")
+	t.Equal(fiber.StatusBadGateway, status)
+	t.Equal(errs.KindKmsError.Code, errRes.Code)
+	t.True(errRes.Retryable)
+	t.Equal([]string{errs.Errs["UnhandledAwsKmsErr"].Type}, errRes.Message)
+
+	status, errRes = t.get("/plain")
+	t.Equal(fiber.StatusInternalServerError, status)
+	t.Equal(errs.KindUnhandled.Code, errRes.Code)
+
+	status, errRes = t.get("/unknown/route")
+	t.Equal(fiber.StatusNotFound, status)
+	t.Equal(errs.KindRouteNotFound.Code, errRes.Code)
+}
+
+func (t *ErrCatalogueTestSuite) Test_LegacyStatus() {
+	errs.LegacyStatus = true
+
+	status, errRes := t.get("/notfound")
+	t.Equal(errs.Errs["KeyIdNotFoundErr"].Code, status)
+	t.Equal(errs.KindKeyNotFound.Code, errRes.Code)
+
+	status, _ = t.get("/kms")
+	t.Equal(errs.Errs["UnhandledAwsKmsErr"].Code, status)
+
+	status, _ = t.get("/plain")
+	t.Equal(errs.Errs["UnhandledServerErr"].Code, status)
+}
+
+func TestErrCatalogueTestSuite(t *testing.T) {
+	suite.Run(t, new(ErrCatalogueTestSuite))
+}
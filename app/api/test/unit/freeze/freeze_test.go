@@ -0,0 +1,247 @@
+package freeze_test
+
+// 계정 동결/해제 api, kill switch 에 의한 서명 거부, 재시작 이후 동결 유지를 fakekms 로 확인하는 테스트
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	ctrl "kms/wallet/app/api/controller"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/app/store"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type FreezeTestSuite struct {
+	suite.Suite
+	fake      *fakekms.FakeKms
+	storePath string
+	kmsSrv    *srv.KmsSrv
+	app       *fiber.App
+}
+
+func (t *FreezeTestSuite) SetupSuite() {
+	logger.Init("test")
+	dto.Init()
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *FreezeTestSuite) SetupTest() {
+	t.fake = fakekms.New()
+	t.storePath = filepath.Join(t.T().TempDir(), "freeze.json")
+	t.restart()
+}
+
+// 같은 kms, 같은 동결 기록 파일로 서비스를 다시 띄운다
+func (t *FreezeTestSuite) restart() {
+	freezeStore, err := store.NewFreezeStore(t.storePath)
+	t.Require().NoError(err)
+	t.kmsSrv = srv.NewKmsSrv(t.fake, srv.WithFreezeStore(freezeStore))
+
+	t.app = fiber.New(fiber.Config{ErrorHandler: func(ctx *fiber.Ctx, err error) error {
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}})
+	ctrl.NewKmsCtrl(t.kmsSrv).BootStrap(t.app)
+}
+
+func (t *FreezeTestSuite) post(path string, body any) (int, dto.AccountRes) {
+	data, err := json.Marshal(body)
+	t.Require().NoError(err)
+	req := httptest.NewRequest(fiber.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := t.app.Test(req, -1)
+	t.Require().NoError(err)
+
+	var account dto.AccountRes
+	if res.StatusCode == fiber.StatusOK {
+		t.Require().NoError(json.NewDecoder(res.Body).Decode(&account))
+	}
+	return res.StatusCode, account
+}
+
+func (t *FreezeTestSuite) requireErrCode(name string, err error) {
+	var cusErr *errs.CusErr
+	t.Require().True(errors.As(err, &cusErr), "%v", err)
+	t.Equal(errs.Errs[name].Code, cusErr.Code)
+}
+
+func (t *FreezeTestSuite) Test_DisableEnable() {
+	account, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+
+	status, frozen := t.post("/accounts/"+account.KeyID+"/disable", dto.FreezeReq{Reason: "leaked", Actor: "alice"})
+	t.Require().Equal(fiber.StatusOK, status)
+	t.True(frozen.Frozen)
+	t.Equal(account.Address, frozen.Address)
+	t.Require().NotNil(frozen.FreezeInfo)
+	t.Equal("leaked", frozen.FreezeInfo.Reason)
+	t.Equal("alice", frozen.FreezeInfo.Actor)
+	t.Equal(types.KeyStateDisabled, t.fake.KeyState(account.KeyID))
+
+	_, _, err = t.kmsSrv.Sign(context.Background(), account.KeyID, make([]byte, 32))
+	t.requireErrCode("FrozenKeyErr", err)
+
+	// reason, actor 는 필수
+	status, _ = t.post("/accounts/"+account.KeyID+"/enable", dto.FreezeReq{Reason: "resolved"})
+	t.NotEqual(fiber.StatusOK, status)
+
+	status, enabled := t.post("/accounts/"+account.KeyID+"/enable", dto.FreezeReq{Reason: "resolved", Actor: "bob"})
+	t.Require().Equal(fiber.StatusOK, status)
+	t.False(enabled.Frozen)
+	t.Nil(enabled.FreezeInfo)
+	t.Equal(types.KeyStateEnabled, t.fake.KeyState(account.KeyID))
+
+	_, _, err = t.kmsSrv.Sign(context.Background(), account.KeyID, make([]byte, 32))
+	t.NoError(err)
+}
+
+// kms 키가 다시 활성화되더라도 kill switch 가 서명을 막는다
+func (t *FreezeTestSuite) Test_KillSwitch() {
+	account, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+
+	// DisableKey 가 실패해도 동결은 유지된다
+	t.fake.FailNext("DisableKey", &types.KMSInternalException{Message: aws.String("injected failure")})
+	_, err = t.kmsSrv.DisableAccount(context.Background(), &dto.KeyIdReq{KeyID: account.KeyID}, &dto.FreezeReq{Reason: "leaked", Actor: "alice"})
+	t.Error(err)
+	t.Equal(types.KeyStateEnabled, t.fake.KeyState(account.KeyID))
+
+	signCalls := t.fake.Calls("Sign")
+	_, _, err = t.kmsSrv.Sign(context.Background(), account.KeyID, make([]byte, 32))
+	t.requireErrCode("FrozenKeyErr", err)
+	t.Equal(signCalls, t.fake.Calls("Sign"))
+}
+
+func (t *FreezeTestSuite) Test_PersistAcrossRestart() {
+	account, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	_, err = t.kmsSrv.DisableAccount(context.Background(), &dto.KeyIdReq{KeyID: account.KeyID}, &dto.FreezeReq{Reason: "leaked", Actor: "alice"})
+	t.Require().NoError(err)
+
+	// 재시작 이후 누군가 kms 에서 키를 다시 활성화한 경우
+	_, err = t.fake.EnableKey(context.Background(), &kms.EnableKeyInput{KeyId: aws.String(account.KeyID)})
+	t.Require().NoError(err)
+	t.restart()
+
+	_, _, err = t.kmsSrv.Sign(context.Background(), account.KeyID, make([]byte, 32))
+	t.requireErrCode("FrozenKeyErr", err)
+
+	restored, err := t.kmsSrv.GetAccount(context.Background(), &dto.KeyIdReq{KeyID: account.KeyID})
+	t.Require().NoError(err)
+	t.True(restored.Frozen)
+	t.Require().NotNil(restored.FreezeInfo)
+	t.Equal("leaked", restored.FreezeInfo.Reason)
+	t.Equal("alice", restored.FreezeInfo.Actor)
+
+	// 해제도 재시작 이후까지 유지된다
+	_, err = t.kmsSrv.EnableAccount(context.Background(), &dto.KeyIdReq{KeyID: account.KeyID}, &dto.FreezeReq{Reason: "resolved", Actor: "bob"})
+	t.Require().NoError(err)
+	t.restart()
+	_, _, err = t.kmsSrv.Sign(context.Background(), account.KeyID, make([]byte, 32))
+	t.NoError(err)
+}
+
+// 동결 기록 없이 kms 에서 직접 비활성화된 키도 동결된 것으로 보여준다
+func (t *FreezeTestSuite) Test_FrozenFromKeyState() {
+	account, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	_, err = t.fake.DisableKey(context.Background(), &kms.DisableKeyInput{KeyId: aws.String(account.KeyID)})
+	t.Require().NoError(err)
+
+	accountList, err := t.kmsSrv.GetAccountList(context.Background(), &dto.AccountListReq{})
+	t.Require().NoError(err)
+	t.Require().Len(accountList.Accounts, 1)
+	t.Equal(account.KeyID, accountList.Accounts[0].KeyID)
+	t.True(accountList.Accounts[0].Frozen)
+	t.Nil(accountList.Accounts[0].FreezeInfo)
+}
+
+// 비활성화된 키는 public key 를 조회할 수 없기 때문에 동결할 때 기록한 주소로 응답한다
+func (t *FreezeTestSuite) Test_GetFrozenAccount() {
+	account, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	status, _ := t.post("/accounts/"+account.KeyID+"/disable", dto.FreezeReq{Reason: "leaked", Actor: "alice"})
+	t.Require().Equal(fiber.StatusOK, status)
+
+	pubKeyCalls := t.fake.Calls("GetPublicKey")
+	res, err := t.app.Test(httptest.NewRequest(fiber.MethodGet, "/accounts/"+account.KeyID, nil), -1)
+	t.Require().NoError(err)
+	t.Require().Equal(fiber.StatusOK, res.StatusCode)
+	var frozen dto.AccountRes
+	t.Require().NoError(json.NewDecoder(res.Body).Decode(&frozen))
+	t.True(frozen.Frozen)
+	t.Equal(account.Address, frozen.Address)
+	t.Require().NotNil(frozen.FreezeInfo)
+	t.Equal("leaked", frozen.FreezeInfo.Reason)
+
+	// 재시작 이후에도 같다
+	t.restart()
+	restored, err := t.kmsSrv.GetAccount(context.Background(), &dto.KeyIdReq{KeyID: account.KeyID})
+	t.Require().NoError(err)
+	t.True(restored.Frozen)
+	t.Equal(account.Address, restored.Address)
+	t.Equal(pubKeyCalls, t.fake.Calls("GetPublicKey"))
+}
+
+// key id 로 동결한 키는 ARN 으로 가리켜도 동결되어 있다
+func (t *FreezeTestSuite) Test_FrozenByARN() {
+	account, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	keyInfo, err := t.fake.DescribeKey(context.Background(), &kms.DescribeKeyInput{KeyId: aws.String(account.KeyID)})
+	t.Require().NoError(err)
+	arn := aws.ToString(keyInfo.KeyMetadata.Arn)
+
+	_, err = t.kmsSrv.DisableAccount(context.Background(), &dto.KeyIdReq{KeyID: account.KeyID}, &dto.FreezeReq{Reason: "leaked", Actor: "alice"})
+	t.Require().NoError(err)
+	// kms 에서 키를 다시 활성화해도 kill switch 가 막는다
+	_, err = t.fake.EnableKey(context.Background(), &kms.EnableKeyInput{KeyId: aws.String(account.KeyID)})
+	t.Require().NoError(err)
+
+	signCalls := t.fake.Calls("Sign")
+	_, _, err = t.kmsSrv.Sign(context.Background(), arn, make([]byte, 32))
+	t.requireErrCode("FrozenKeyErr", err)
+	t.Equal(signCalls, t.fake.Calls("Sign"))
+
+	frozen, err := t.kmsSrv.GetAccount(context.Background(), &dto.KeyIdReq{KeyID: arn})
+	t.Require().NoError(err)
+	t.True(frozen.Frozen)
+
+	// ARN 으로 해제해도 key id 의 동결 기록이 지워진다
+	enabled, err := t.kmsSrv.EnableAccount(context.Background(), &dto.KeyIdReq{KeyID: arn}, &dto.FreezeReq{Reason: "resolved", Actor: "bob"})
+	t.Require().NoError(err)
+	t.False(enabled.Frozen)
+	_, _, err = t.kmsSrv.Sign(context.Background(), account.KeyID, make([]byte, 32))
+	t.NoError(err)
+}
+
+// ARN 으로 동결해도 key id 로 서명할 수 없다
+func (t *FreezeTestSuite) Test_FrozenByARNSignByKeyID() {
+	account, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	keyInfo, err := t.fake.DescribeKey(context.Background(), &kms.DescribeKeyInput{KeyId: aws.String(account.KeyID)})
+	t.Require().NoError(err)
+
+	t.fake.FailNext("DisableKey", &types.KMSInternalException{Message: aws.String("injected failure")})
+	_, err = t.kmsSrv.DisableAccount(context.Background(), &dto.KeyIdReq{KeyID: aws.ToString(keyInfo.KeyMetadata.Arn)}, &dto.FreezeReq{Reason: "leaked", Actor: "alice"})
+	t.Error(err)
+
+	_, _, err = t.kmsSrv.Sign(context.Background(), account.KeyID, make([]byte, 32))
+	t.requireErrCode("FrozenKeyErr", err)
+}
+
+func TestFreezeTestSuite(t *testing.T) {
+	suite.Run(t, new(FreezeTestSuite))
+}
@@ -0,0 +1,191 @@
+package health_test
+
+// liveness, readiness 의 항목별 결과(kms, canary 서명, rpc, audit 파일)를 확인하는 테스트
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	ctrl "kms/wallet/app/api/controller"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/common/audit"
+	"kms/wallet/common/logger"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type HealthTestSuite struct {
+	suite.Suite
+	fake        *fakekms.FakeKms
+	kmsSrv      *srv.KmsSrv
+	canaryKeyID string
+	rpcServer   *httptest.Server
+	rpcChainID  string
+	auditPath   string
+}
+
+func (t *HealthTestSuite) SetupSuite() {
+	logger.Init("test")
+
+	// eth_chainId 에만 응답하는 rpc 서버
+	t.rpcServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": t.rpcChainID})
+	}))
+}
+
+func (t *HealthTestSuite) TearDownSuite() {
+	t.rpcServer.Close()
+}
+
+func (t *HealthTestSuite) SetupTest() {
+	t.fake = fakekms.New()
+	t.kmsSrv = srv.NewKmsSrv(t.fake)
+	accountRes, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	t.canaryKeyID = accountRes.KeyID
+	t.rpcChainID = "0x1"
+
+	t.auditPath = filepath.Join(t.T().TempDir(), "audit.log")
+	t.Require().NoError(audit.Init(t.auditPath))
+}
+
+func (t *HealthTestSuite) TearDownTest() {
+	audit.Close()
+}
+
+func (t *HealthTestSuite) config() srv.HealthSrvConfig {
+	return srv.HealthSrvConfig{
+		CanaryKeyID: t.canaryKeyID,
+		RPCURLs:     []string{t.rpcServer.URL + "/v3/secret-api-key"},
+		ChainID:     big.NewInt(1),
+	}
+}
+
+func (t *HealthTestSuite) get(config srv.HealthSrvConfig, path string) (int, []byte) {
+	app := fiber.New()
+	ctrl.NewHealthCtrl(srv.NewHealthSrv(t.kmsSrv, config)).BootStrap(app)
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil), -1)
+	t.Require().NoError(err)
+	body, err := io.ReadAll(res.Body)
+	t.Require().NoError(err)
+	return res.StatusCode, body
+}
+
+func (t *HealthTestSuite) ready(config srv.HealthSrvConfig) (int, map[string]dto.HealthCheckRes) {
+	status, body := t.get(config, "/health/ready")
+	var readinessRes dto.ReadinessRes
+	t.Require().NoError(json.Unmarshal(body, &readinessRes))
+	t.Equal(status == fiber.StatusOK, readinessRes.Ready)
+
+	checks := make(map[string]dto.HealthCheckRes)
+	for _, check := range readinessRes.Checks {
+		checks[check.Name] = check
+	}
+	return status, checks
+}
+
+func (t *HealthTestSuite) Test_Ready() {
+	status, checks := t.ready(t.config())
+	t.Equal(fiber.StatusOK, status)
+	t.Len(checks, 4)
+	for _, name := range []string{"kms", "kms_canary", "rpc:" + t.rpcServer.Listener.Addr().String(), "audit"} {
+		t.Equal("ok", checks[name].Status, name)
+		t.Empty(checks[name].Error, name)
+		t.GreaterOrEqual(checks[name].LatencyMs, int64(0), name)
+	}
+	t.Equal(1, t.fake.Calls("Sign"))
+}
+
+func (t *HealthTestSuite) Test_Optional() {
+	status, checks := t.ready(srv.HealthSrvConfig{})
+	t.Equal(fiber.StatusOK, status)
+	t.Len(checks, 2)
+	t.Contains(checks, "kms")
+	t.Contains(checks, "audit")
+	t.Zero(t.fake.Calls("Sign"))
+}
+
+func (t *HealthTestSuite) Test_KmsFailure() {
+	t.fake.FailNext("ListKeys", &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "User: arn:aws:iam::123456789012:user/wallet is not authorized"})
+	status, checks := t.ready(t.config())
+	t.Equal(fiber.StatusServiceUnavailable, status)
+	t.Equal("fail", checks["kms"].Status)
+	t.Equal("KMS_ACCESS_DENIED", checks["kms"].Error)
+	t.Equal("ok", checks["audit"].Status)
+
+	// liveness 는 외부 시스템과 상관없다
+	t.fake.FailNext("ListKeys", &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "denied"})
+	status, body := t.get(t.config(), "/health/live")
+	t.Equal(fiber.StatusOK, status)
+	t.JSONEq(`{"alive":true}`, string(body))
+}
+
+func (t *HealthTestSuite) Test_CanaryFailure() {
+	_, err := t.kmsSrv.DisableAccount(context.Background(), &dto.KeyIdReq{KeyID: t.canaryKeyID}, &dto.FreezeReq{Actor: "tester", Reason: "test"})
+	t.Require().NoError(err)
+
+	status, checks := t.ready(t.config())
+	t.Equal(fiber.StatusServiceUnavailable, status)
+	t.Equal("ok", checks["kms"].Status)
+	t.Equal("fail", checks["kms_canary"].Status)
+	t.Equal("KEY_FROZEN", checks["kms_canary"].Error)
+}
+
+func (t *HealthTestSuite) Test_RPCFailure() {
+	rpcCheck := "rpc:" + t.rpcServer.Listener.Addr().String()
+
+	t.rpcChainID = "0x5"
+	status, checks := t.ready(t.config())
+	t.Equal(fiber.StatusServiceUnavailable, status)
+	t.Equal("fail", checks[rpcCheck].Status)
+	t.Contains(checks[rpcCheck].Error, "does not match CHAIN_ID")
+
+	config := t.config()
+	config.RPCURLs = []string{"http://127.0.0.1:1/v3/secret-api-key"}
+	status, checks = t.ready(config)
+	t.Equal(fiber.StatusServiceUnavailable, status)
+	t.Equal("fail", checks["rpc:127.0.0.1:1"].Status)
+	t.NotContains(checks["rpc:127.0.0.1:1"].Error, "secret-api-key")
+}
+
+func (t *HealthTestSuite) Test_AuditFailure() {
+	t.Require().NoError(os.Remove(t.auditPath))
+	status, checks := t.ready(t.config())
+	t.Equal(fiber.StatusServiceUnavailable, status)
+	t.Equal("fail", checks["audit"].Status)
+	t.NotEmpty(checks["audit"].Error)
+}
+
+func (t *HealthTestSuite) Test_Timeout() {
+	t.fake.SetLatency("ListKeys", time.Second)
+	config := t.config()
+	config.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	status, checks := t.ready(config)
+	t.Less(time.Since(start), 500*time.Millisecond)
+	t.Equal(fiber.StatusServiceUnavailable, status)
+	t.Equal("fail", checks["kms"].Status)
+	t.Equal("REQUEST_TIMEOUT", checks["kms"].Error)
+	t.Equal("ok", checks["kms_canary"].Status)
+}
+
+func TestHealthTestSuite(t *testing.T) {
+	suite.Run(t, new(HealthTestSuite))
+}
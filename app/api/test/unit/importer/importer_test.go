@@ -0,0 +1,119 @@
+package importer_test
+
+// 클라이언트에서 key material 을 암호화해서 주입하는 헬퍼를 fakekms 를 쓰는 서버로 확인하는 테스트
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	ctrl "kms/wallet/app/api/controller"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/app/client"
+	"kms/wallet/app/server"
+	"kms/wallet/common/config"
+	"kms/wallet/common/logger"
+	"net"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/suite"
+)
+
+type ImporterTestSuite struct {
+	suite.Suite
+	fake   *fakekms.FakeKms
+	kmsSrv *srv.KmsSrv
+	server *server.Server
+	addr   string
+}
+
+func (t *ImporterTestSuite) SetupSuite() {
+	logger.Init("test")
+	dto.Init()
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *ImporterTestSuite) SetupTest() {
+	t.fake = fakekms.New()
+	t.kmsSrv = srv.NewKmsSrv(t.fake)
+
+	config.Env = config.Default()
+	config.Env.Environment = "test"
+	t.server = server.New()
+	ctrl.NewKmsCtrl(t.kmsSrv).BootStrap(t.server.App.Group("/api"))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	t.Require().NoError(err)
+	go t.server.Serve(ln)
+	t.addr = "http://" + ln.Addr().String()
+}
+
+func (t *ImporterTestSuite) TearDownTest() {
+	t.NoError(t.server.Shutdown())
+}
+
+func (t *ImporterTestSuite) Test_Import() {
+	ecdsaPK, err := crypto.GenerateKey()
+	t.Require().NoError(err)
+
+	accountRes, err := client.NewImporter(t.addr+"/", nil).Import(ecdsaPK)
+	t.Require().NoError(err)
+	t.Equal(crypto.PubkeyToAddress(ecdsaPK.PublicKey).String(), accountRes.Address)
+	t.Equal(types.KeyStateEnabled, t.fake.KeyState(accountRes.KeyID))
+
+	// 주입된 키로 서명할 수 있다
+	_, _, err = t.kmsSrv.Sign(context.Background(), accountRes.KeyID, make([]byte, 32))
+	t.NoError(err)
+}
+
+// 서버 에러는 상태코드와 에러 코드를 담아 리턴하고, 주입에 실패한 껍데기는 삭제 예약된다
+func (t *ImporterTestSuite) Test_ImportFails() {
+	ecdsaPK, err := crypto.GenerateKey()
+	t.Require().NoError(err)
+	t.fake.FailNext("ImportKeyMaterial", &types.KMSInternalException{Message: aws.String("injected failure")})
+
+	_, err = client.NewImporter(t.addr, nil).Import(ecdsaPK)
+	t.ErrorContains(err, "POST /api/import/account/encrypted failed with status 503")
+	t.Require().Len(t.fake.KeyIDs(), 1)
+	t.Equal(types.KeyStatePendingDeletion, t.fake.KeyState(t.fake.KeyIDs()[0]))
+}
+
+func (t *ImporterTestSuite) Test_WrapForImport() {
+	ecdsaPK, err := crypto.GenerateKey()
+	t.Require().NoError(err)
+	importParamsRes, err := t.kmsSrv.GetImportParams(context.Background())
+	t.Require().NoError(err)
+
+	encryptedReq, err := client.WrapForImport(ecdsaPK, importParamsRes)
+	t.Require().NoError(err)
+	t.Equal(importParamsRes.KeyID, encryptedReq.KeyID)
+	t.Equal(importParamsRes.ImportToken, encryptedReq.ImportToken)
+	t.Equal(crypto.PubkeyToAddress(ecdsaPK.PublicKey).String(), encryptedReq.ExpectedAddress)
+	t.NoError(dto.Validate(encryptedReq))
+
+	// 평문 key material 이 요청에 들어가면 안된다
+	encryptedMaterial, err := base64.StdEncoding.DecodeString(encryptedReq.EncryptedKeyMaterial)
+	t.Require().NoError(err)
+	t.NotContains(string(encryptedMaterial), string(crypto.FromECDSA(ecdsaPK)))
+
+	// wrapping key 가 잘못된 경우
+	_, err = client.WrapForImport(ecdsaPK, &dto.ImportParamsRes{PublicKey: "not base64"})
+	t.Error(err)
+	p256PK, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	t.Require().NoError(err)
+	p256PubKey, err := x509.MarshalPKIXPublicKey(&p256PK.PublicKey)
+	t.Require().NoError(err)
+	_, err = client.WrapForImport(ecdsaPK, &dto.ImportParamsRes{PublicKey: base64.StdEncoding.EncodeToString(p256PubKey)})
+	t.ErrorContains(err, "not an rsa public key")
+}
+
+func TestImporterTestSuite(t *testing.T) {
+	suite.Run(t, new(ImporterTestSuite))
+}
@@ -0,0 +1,243 @@
+package job_test
+
+// 비동기 서명 작업의 처리, 일시적 에러 재시도, 재시작 이후 재개, HMAC 서명된 콜백을 확인하는 테스트 (fakekms 사용)
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/app/store"
+	"kms/wallet/common/logger"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/suite"
+)
+
+const webhookSecret = "test-secret"
+
+type JobTestSuite struct {
+	suite.Suite
+	fake    *fakekms.FakeKms
+	txnSrv  *srv.TxnSrv
+	account *dto.AccountRes
+}
+
+var errInjected = &types.KMSInternalException{Message: aws.String("injected failure")}
+
+func (t *JobTestSuite) SetupSuite() {
+	logger.Init("test")
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *JobTestSuite) SetupTest() {
+	t.fake = fakekms.New()
+	kmsSrv := srv.NewKmsSrv(t.fake)
+	t.txnSrv = srv.NewTxnSrv(big.NewInt(1337), kmsSrv)
+
+	var err error
+	t.account, err = kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+}
+
+func (t *JobTestSuite) newJobSrv(jobStore store.JobStore) *srv.JobSrv {
+	return srv.NewJobSrv(t.txnSrv, jobStore, srv.JobSrvConfig{
+		Workers:        2,
+		RetryBaseDelay: 10 * time.Millisecond,
+		WebhookSecret:  webhookSecret,
+	})
+}
+
+func (t *JobTestSuite) signJobReq(count int) *dto.SignJobReq {
+	to := common.HexToAddress("0x39e243a7f209932df41e1fc0a1ada51b3a04b46d")
+	signJobReq := &dto.SignJobReq{}
+	for i := 0; i < count; i++ {
+		rawTxn, err := ethtypes.NewTx(&ethtypes.LegacyTx{Nonce: uint64(i), GasPrice: big.NewInt(1e9), Gas: 21000, To: &to, Value: big.NewInt(1)}).MarshalBinary()
+		t.Require().NoError(err)
+		signJobReq.Txns = append(signJobReq.Txns, dto.TxnReq{KeyID: t.account.KeyID, SerializedTxn: "0x" + common.Bytes2Hex(rawTxn)})
+	}
+	return signJobReq
+}
+
+// 작업이 끝날때까지 기다린 뒤 결과를 리턴
+func (t *JobTestSuite) waitFinished(jobSrv *srv.JobSrv, id string) *dto.JobRes {
+	var jobRes *dto.JobRes
+	t.Require().Eventually(func() bool {
+		var err error
+		jobRes, err = jobSrv.GetJob(&dto.JobIdReq{ID: id})
+		t.Require().NoError(err)
+		return jobRes.Status == store.JobStatusSucceeded || jobRes.Status == store.JobStatusFailed
+	}, 5*time.Second, 10*time.Millisecond)
+	return jobRes
+}
+
+func (t *JobTestSuite) Test_SignJobWithCallback() {
+	callbacks := make(chan *dto.JobRes, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		expected := "sha256=" + srv.SignWebhook(webhookSecret, r.Header.Get("X-Signature-Timestamp"), body)
+		if r.Header.Get("X-Signature") != expected {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var jobRes dto.JobRes
+		json.Unmarshal(body, &jobRes)
+		callbacks <- &jobRes
+	}))
+	defer server.Close()
+
+	jobSrv := t.newJobSrv(store.NewMemoryJobStore())
+	t.Require().NoError(jobSrv.Start())
+	defer jobSrv.Stop()
+
+	signJobReq := t.signJobReq(3)
+	signJobReq.CallbackURL = server.URL
+	jobRes, err := jobSrv.EnqueueSignJob(context.Background(), signJobReq)
+	t.Require().NoError(err)
+	t.Equal(store.JobStatusQueued, jobRes.Status)
+
+	jobRes = t.waitFinished(jobSrv, jobRes.ID)
+	t.Equal(store.JobStatusSucceeded, jobRes.Status)
+	t.Equal(3, jobRes.Result.Succeeded)
+
+	select {
+	case callback := <-callbacks:
+		t.Equal(jobRes.ID, callback.ID)
+		t.Equal(store.JobStatusSucceeded, callback.Status)
+		t.Len(callback.Result.Results, 3)
+	case <-time.After(5 * time.Second):
+		t.Fail("callback not received")
+	}
+}
+
+// kms 일시적 에러로 실패한 항목만 다시 서명한다
+func (t *JobTestSuite) Test_RetryTransientError() {
+	jobSrv := t.newJobSrv(store.NewMemoryJobStore())
+	t.Require().NoError(jobSrv.Start())
+	defer jobSrv.Stop()
+
+	signCalls := t.fake.Calls("Sign")
+	t.fake.FailNext("Sign", errInjected)
+	jobRes, err := jobSrv.EnqueueSignJob(context.Background(), t.signJobReq(2))
+	t.Require().NoError(err)
+
+	jobRes = t.waitFinished(jobSrv, jobRes.ID)
+	t.Equal(store.JobStatusSucceeded, jobRes.Status)
+	t.Equal(2, jobRes.Attempts)
+	t.Equal(2, jobRes.Result.Succeeded)
+	t.Equal(3, t.fake.Calls("Sign")-signCalls)
+}
+
+func (t *JobTestSuite) Test_PermanentErrorNotRetried() {
+	jobSrv := t.newJobSrv(store.NewMemoryJobStore())
+	t.Require().NoError(jobSrv.Start())
+	defer jobSrv.Stop()
+
+	signJobReq := t.signJobReq(1)
+	signJobReq.Txns[0].KeyID = "f50a9229-e7c7-45ba-b06c-8036b894424e"
+	jobRes, err := jobSrv.EnqueueSignJob(context.Background(), signJobReq)
+	t.Require().NoError(err)
+
+	jobRes = t.waitFinished(jobSrv, jobRes.ID)
+	t.Equal(store.JobStatusFailed, jobRes.Status)
+	t.Equal(1, jobRes.Attempts)
+	t.Equal(1, jobRes.Result.Failed)
+}
+
+// sqlite 에 남은 작업은 재시작 이후 처리된다. 실행 도중 중단된 작업도 다시 실행한다
+func (t *JobTestSuite) Test_ResumeAfterRestart() {
+	dbPath := filepath.Join(t.T().TempDir(), "jobs.db")
+	jobStore, err := store.NewSQLiteJobStore(dbPath)
+	t.Require().NoError(err)
+
+	// worker 없이 작업만 등록
+	stopped := t.newJobSrv(jobStore)
+	queued, err := stopped.EnqueueSignJob(context.Background(), t.signJobReq(1))
+	t.Require().NoError(err)
+	running, err := stopped.EnqueueSignJob(context.Background(), t.signJobReq(2))
+	t.Require().NoError(err)
+	stopped.Stop()
+
+	job, ok, err := jobStore.Get(running.ID)
+	t.Require().NoError(err)
+	t.Require().True(ok)
+	job.Status = store.JobStatusRunning
+	t.Require().NoError(jobStore.Save(job))
+	t.Require().NoError(jobStore.Close())
+
+	jobStore, err = store.NewSQLiteJobStore(dbPath)
+	t.Require().NoError(err)
+	defer jobStore.Close()
+	jobSrv := t.newJobSrv(jobStore)
+	t.Require().NoError(jobSrv.Start())
+	defer jobSrv.Stop()
+
+	t.Equal(store.JobStatusSucceeded, t.waitFinished(jobSrv, queued.ID).Status)
+	t.Equal(2, t.waitFinished(jobSrv, running.ID).Result.Succeeded)
+
+	_, err = jobSrv.GetJob(&dto.JobIdReq{ID: "0d6ae3c8-0c1f-4bb4-a1ad-1e4f5b5a0c55"})
+	t.Error(err)
+}
+
+// Stop 이후에 종료 hook 이 다시 호출되어도 된다
+func (t *JobTestSuite) Test_StopTwice() {
+	jobSrv := t.newJobSrv(store.NewMemoryJobStore())
+	t.Require().NoError(jobSrv.Start())
+	jobSrv.Stop()
+	t.NoError(jobSrv.Shutdown(context.Background()))
+	t.NoError(jobSrv.Shutdown(context.Background()))
+}
+
+// 콜백 재시도를 기다리는 중에 종료되면 바로 멈추고 콜백 에러를 남긴다
+func (t *JobTestSuite) Test_ShutdownDuringCallbackRetry() {
+	attempts := make(chan struct{}, 3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts <- struct{}{}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	jobStore := store.NewMemoryJobStore()
+	jobSrv := srv.NewJobSrv(t.txnSrv, jobStore, srv.JobSrvConfig{
+		Workers:        1,
+		RetryBaseDelay: time.Minute,
+		WebhookSecret:  webhookSecret,
+	})
+	t.Require().NoError(jobSrv.Start())
+	signJobReq := t.signJobReq(1)
+	signJobReq.CallbackURL = server.URL
+	jobRes, err := jobSrv.EnqueueSignJob(context.Background(), signJobReq)
+	t.Require().NoError(err)
+
+	select {
+	case <-attempts:
+	case <-time.After(5 * time.Second):
+		t.FailNow("callback not received")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	t.Require().NoError(jobSrv.Shutdown(ctx))
+
+	job, ok, err := jobStore.Get(jobRes.ID)
+	t.Require().NoError(err)
+	t.Require().True(ok)
+	t.Equal(store.JobStatusSucceeded, job.Status)
+	t.False(job.CallbackDone)
+	t.Contains(job.CallbackErr, "stopped before the callback was delivered")
+	t.Len(attempts, 0)
+}
+
+func Test(t *testing.T) {
+	suite.Run(t, new(JobTestSuite))
+}
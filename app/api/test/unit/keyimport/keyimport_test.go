@@ -0,0 +1,183 @@
+package keyimport_test
+
+// keystore, PEM, 니모닉으로 외부키를 주입하는 api 를 fakekms 로 확인하는 테스트
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	ctrl "kms/wallet/app/api/controller"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"kms/wallet/common/utils/keyutil"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+const testMnemonic = "test test test test test test test test test test test junk"
+
+type KeyImportTestSuite struct {
+	suite.Suite
+	fake   *fakekms.FakeKms
+	kmsSrv *srv.KmsSrv
+	app    *fiber.App
+}
+
+func (t *KeyImportTestSuite) SetupSuite() {
+	logger.Init("test")
+	dto.Init()
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *KeyImportTestSuite) SetupTest() {
+	t.fake = fakekms.New()
+	t.kmsSrv = srv.NewKmsSrv(t.fake)
+	t.app = fiber.New(fiber.Config{ErrorHandler: func(ctx *fiber.Ctx, err error) error {
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}})
+	ctrl.NewKmsCtrl(t.kmsSrv).BootStrap(t.app)
+}
+
+func (t *KeyImportTestSuite) post(path string, body any, res any) int {
+	data, err := json.Marshal(body)
+	t.Require().NoError(err)
+	req := httptest.NewRequest(fiber.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	httpRes, err := t.app.Test(req, -1)
+	t.Require().NoError(err)
+	if httpRes.StatusCode != fiber.StatusBadRequest {
+		t.Require().NoError(json.NewDecoder(httpRes.Body).Decode(res))
+	}
+	return httpRes.StatusCode
+}
+
+func (t *KeyImportTestSuite) requireCode(err error, errName string) {
+	var customErr *errs.CusErr
+	t.Require().True(errors.As(err, &customErr), "expected errs.CusErr but got %v", err)
+	t.Equal(errs.Errs[errName].Code, customErr.Code)
+}
+
+func (t *KeyImportTestSuite) Test_ImportKeystore() {
+	ecdsaPK, err := crypto.GenerateKey()
+	t.Require().NoError(err)
+	address := crypto.PubkeyToAddress(ecdsaPK.PublicKey)
+	keystoreJSON, err := keystore.EncryptKey(&keystore.Key{Address: address, PrivateKey: ecdsaPK}, "passphrase", keystore.LightScryptN, keystore.LightScryptP)
+	t.Require().NoError(err)
+
+	var importRes dto.ImportAccountRes
+	status := t.post("/import/account/keystore", dto.KeystoreImportReq{
+		Keystore:        string(keystoreJSON),
+		Passphrase:      "passphrase",
+		ExpectedAddress: address.String(),
+	}, &importRes)
+	t.Require().Equal(fiber.StatusCreated, status)
+	t.Equal(address.String(), importRes.DerivedAddress)
+	t.Require().NotNil(importRes.Account)
+	t.Equal(address.String(), importRes.Account.Address)
+
+	// passphrase 가 틀리면 키를 만들지 않는다
+	createCalls := t.fake.Calls("CreateKey")
+	_, err = t.kmsSrv.ImportKeystore(context.Background(), &dto.KeystoreImportReq{Keystore: string(keystoreJSON), Passphrase: "wrong"})
+	t.requireCode(err, "BadRequestErr")
+	t.Equal(createCalls, t.fake.Calls("CreateKey"))
+}
+
+func (t *KeyImportTestSuite) Test_ImportPem() {
+	ecdsaPK, err := crypto.GenerateKey()
+	t.Require().NoError(err)
+	der, err := keyutil.MarshalPKCS8(ecdsaPK)
+	t.Require().NoError(err)
+	pemData := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	importRes, err := t.kmsSrv.ImportPem(context.Background(), &dto.PemImportReq{Pem: pemData})
+	t.Require().NoError(err)
+	t.Require().NotNil(importRes.Account)
+	t.Equal(crypto.PubkeyToAddress(ecdsaPK.PublicKey).String(), importRes.Account.Address)
+
+	_, err = t.kmsSrv.ImportPem(context.Background(), &dto.PemImportReq{Pem: "not a pem"})
+	t.requireCode(err, "BadRequestErr")
+}
+
+// dry run 은 키를 만들지 않고 유도된 주소만 확인한다
+func (t *KeyImportTestSuite) Test_DryRun() {
+	ecdsaPK, err := crypto.GenerateKey()
+	t.Require().NoError(err)
+	der, err := keyutil.MarshalPKCS8(ecdsaPK)
+	t.Require().NoError(err)
+	pemReq := &dto.PemImportReq{Pem: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), DryRun: true}
+
+	importRes, err := t.kmsSrv.ImportPem(context.Background(), pemReq)
+	t.Require().NoError(err)
+	t.Equal(crypto.PubkeyToAddress(ecdsaPK.PublicKey).String(), importRes.DerivedAddress)
+	t.Nil(importRes.Account)
+
+	pemReq.ExpectedAddress = "0x216690cD286d8a9c8D39d9714263bB6AB97046F3"
+	_, err = t.kmsSrv.ImportPem(context.Background(), pemReq)
+	t.requireCode(err, "AddressMismatchErr")
+	t.Zero(t.fake.Calls("CreateKey"))
+}
+
+func (t *KeyImportTestSuite) Test_ImportMnemonic() {
+	var importListRes dto.ImportAccountListRes
+	status := t.post("/import/account/mnemonic", dto.MnemonicImportReq{Mnemonic: testMnemonic, Count: 3}, &importListRes)
+	t.Require().Equal(fiber.StatusCreated, status)
+	t.Require().Len(importListRes.Accounts, 3)
+
+	expected := []string{
+		"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+		"0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+		"0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC",
+	}
+	for i, importRes := range importListRes.Accounts {
+		t.Equal("m/44'/60'/0'/0/"+strconv.Itoa(i), importRes.Path)
+		t.Equal(expected[i], importRes.DerivedAddress)
+		t.Require().NotNil(importRes.Account)
+		t.Equal(expected[i], importRes.Account.Address)
+	}
+}
+
+// 일부 index 의 주입이 실패해도 나머지는 계속 진행한다
+func (t *KeyImportTestSuite) Test_ImportMnemonicPartialFailure() {
+	t.fake.FailNextAfter("CreateKey", 1, &types.KMSInternalException{Message: aws.String("injected failure")})
+
+	var importListRes dto.ImportAccountListRes
+	status := t.post("/import/account/mnemonic", dto.MnemonicImportReq{Mnemonic: testMnemonic, StartIndex: 5, Count: 3}, &importListRes)
+	t.Require().Equal(fiber.StatusMultiStatus, status)
+	t.Require().Len(importListRes.Accounts, 3)
+
+	t.NotNil(importListRes.Accounts[0].Account)
+	t.Nil(importListRes.Accounts[1].Account)
+	t.Require().NotNil(importListRes.Accounts[1].Error)
+	t.Equal("m/44'/60'/0'/0/6", importListRes.Accounts[1].Path)
+	t.NotEmpty(importListRes.Accounts[1].DerivedAddress)
+	t.NotNil(importListRes.Accounts[2].Account)
+}
+
+// 니모닉이나 경로가 잘못되면 키를 하나도 만들지 않는다
+func (t *KeyImportTestSuite) Test_ImportMnemonicInvalid() {
+	for _, mnemonicReq := range []dto.MnemonicImportReq{
+		{Mnemonic: "test test test test test test test test test test test test"},
+		{Mnemonic: testMnemonic, Path: "m/not/a/path"},
+	} {
+		_, err := t.kmsSrv.ImportMnemonic(context.Background(), &mnemonicReq)
+		t.requireCode(err, "BadRequestErr")
+	}
+	t.Zero(t.fake.Calls("CreateKey"))
+}
+
+func TestKeyImportTestSuite(t *testing.T) {
+	suite.Run(t, new(KeyImportTestSuite))
+}
@@ -0,0 +1,191 @@
+package keypolicy_test
+
+// 새 키에 key policy 템플릿이 적용되는지, 바뀐 policy 를 drift 로 보고하는지 fakekms 로 확인하는 테스트
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	ctrl "kms/wallet/app/api/controller"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	signerPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Sid": "EnableRoot", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111122223333:root"}, "Action": "kms:*", "Resource": "*"},
+    {"Sid": "AllowServiceSign", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111122223333:role/wallet-signer"}, "Action": ["kms:Sign", "kms:GetPublicKey"], "Resource": "*"}
+  ]
+}`
+	// 공백, 목록 순서, 한개짜리 목록은 달라도 같은 policy
+	signerPolicyReformatted = `{"Statement":[
+    {"Sid":"AllowServiceSign","Effect":"Allow","Principal":{"AWS":["arn:aws:iam::111122223333:role/wallet-signer"]},"Action":["kms:GetPublicKey","kms:Sign"],"Resource":"*"},
+    {"Sid":"EnableRoot","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::111122223333:root"},"Action":"kms:*","Resource":"*"}],
+  "Version":"2012-10-17"}`
+	driftedPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Sid": "EnableRoot", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111122223333:root"}, "Action": "kms:*", "Resource": "*"},
+    {"Sid": "AllowServiceSign", "Effect": "Allow", "Principal": {"AWS": "*"}, "Action": ["kms:Sign", "kms:GetPublicKey"], "Resource": "*"},
+    {"Sid": "AllowAllIAM", "Effect": "Allow", "Principal": {"AWS": "*"}, "Action": "kms:*", "Resource": "*"}
+  ]
+}`
+	readOnlyPolicy = `{"Version":"2012-10-17","Statement":[{"Sid":"EnableRoot","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::111122223333:root"},"Action":"kms:*","Resource":"*"}]}`
+)
+
+type KeyPolicyTestSuite struct {
+	suite.Suite
+	fake   *fakekms.FakeKms
+	kmsSrv *srv.KmsSrv
+}
+
+func (t *KeyPolicyTestSuite) SetupSuite() {
+	logger.Init("test")
+	dto.Init()
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *KeyPolicyTestSuite) SetupTest() {
+	t.fake = fakekms.New()
+	t.kmsSrv = srv.NewKmsSrv(t.fake, srv.WithKeyPolicies(srv.KeyPolicies{
+		Default:   "signer",
+		Documents: map[string]string{"signer": signerPolicy, "read-only": readOnlyPolicy},
+	}))
+}
+
+func (t *KeyPolicyTestSuite) keyPolicy(keyID string) string {
+	policy, err := t.fake.GetKeyPolicy(context.Background(), &kms.GetKeyPolicyInput{KeyId: aws.String(keyID), PolicyName: aws.String("default")})
+	t.Require().NoError(err)
+	return aws.ToString(policy.Policy)
+}
+
+func (t *KeyPolicyTestSuite) Test_AppliedOnCreate() {
+	account, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	t.JSONEq(signerPolicy, t.keyPolicy(account.KeyID))
+	t.Equal("signer", t.fake.Tags(account.KeyID)["KeyPolicyTemplate"])
+
+	// 외부키 주입도 같은 템플릿으로 만든다
+	ecdsaPK, err := crypto.GenerateKey()
+	t.Require().NoError(err)
+	imported, err := t.kmsSrv.ImportAccount(context.Background(), &dto.PkReq{PK: common.Bytes2Hex(crypto.FromECDSA(ecdsaPK))})
+	t.Require().NoError(err)
+	t.JSONEq(signerPolicy, t.keyPolicy(imported.KeyID))
+	t.Equal("signer", t.fake.Tags(imported.KeyID)["KeyPolicyTemplate"])
+
+	// 템플릿이 없으면 kms 의 기본 policy 를 그대로 둔다
+	account, err = srv.NewKmsSrv(t.fake).CreateAccount(context.Background())
+	t.Require().NoError(err)
+	t.Contains(t.keyPolicy(account.KeyID), "Enable IAM User Permissions")
+	t.NotContains(t.fake.Tags(account.KeyID), "KeyPolicyTemplate")
+}
+
+func (t *KeyPolicyTestSuite) Test_Drift() {
+	account, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	keyIdReq := &dto.KeyIdReq{KeyID: account.KeyID}
+
+	driftRes, err := t.kmsSrv.CheckKeyPolicy(context.Background(), keyIdReq, "")
+	t.Require().NoError(err)
+	t.Equal(&dto.KeyPolicyDriftRes{KeyID: account.KeyID, Template: "signer"}, driftRes)
+
+	t.fake.PutKeyPolicyForTest(account.KeyID, signerPolicyReformatted)
+	driftRes, err = t.kmsSrv.CheckKeyPolicy(context.Background(), keyIdReq, "")
+	t.Require().NoError(err)
+	t.False(driftRes.Drifted)
+
+	t.fake.PutKeyPolicyForTest(account.KeyID, driftedPolicy)
+	driftRes, err = t.kmsSrv.CheckKeyPolicy(context.Background(), keyIdReq, "")
+	t.Require().NoError(err)
+	t.True(driftRes.Drifted)
+	t.Empty(driftRes.Missing)
+	t.Equal([]string{"AllowAllIAM"}, driftRes.Unexpected)
+	t.Equal([]string{"AllowServiceSign"}, driftRes.Changed)
+
+	// 다른 템플릿과 비교할 수도 있다
+	driftRes, err = t.kmsSrv.CheckKeyPolicy(context.Background(), keyIdReq, "read-only")
+	t.Require().NoError(err)
+	t.Equal("read-only", driftRes.Template)
+	t.ElementsMatch([]string{"AllowServiceSign", "AllowAllIAM"}, driftRes.Unexpected)
+}
+
+// 템플릿 tag 가 첫 페이지에 없어도 찾는다
+func (t *KeyPolicyTestSuite) Test_TemplateTagOnLaterPage() {
+	var tags []types.Tag
+	for i := 0; i < 60; i++ {
+		tags = append(tags, types.Tag{TagKey: aws.String("Tag" + strconv.Itoa(i)), TagValue: aws.String("value")})
+	}
+	tags = append(tags, types.Tag{TagKey: aws.String("KeyPolicyTemplate"), TagValue: aws.String("read-only")})
+	key, err := t.fake.CreateKey(context.Background(), &kms.CreateKeyInput{
+		KeySpec:  types.KeySpecEccSecgP256k1,
+		KeyUsage: types.KeyUsageTypeSignVerify,
+		Tags:     tags,
+	})
+	t.Require().NoError(err)
+	keyID := aws.ToString(key.KeyMetadata.KeyId)
+	t.fake.PutKeyPolicyForTest(keyID, readOnlyPolicy)
+
+	driftRes, err := t.kmsSrv.CheckKeyPolicy(context.Background(), &dto.KeyIdReq{KeyID: keyID}, "")
+	t.Require().NoError(err)
+	t.Equal(&dto.KeyPolicyDriftRes{KeyID: keyID, Template: "read-only"}, driftRes)
+	t.Equal(2, t.fake.Calls("ListResourceTags"))
+}
+
+func (t *KeyPolicyTestSuite) Test_UnknownTemplate() {
+	account, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	_, err = t.kmsSrv.CheckKeyPolicy(context.Background(), &dto.KeyIdReq{KeyID: account.KeyID}, "unknown")
+	t.requireErrCode("BadRequestErr", err)
+
+	// 템플릿 태그도 기본 템플릿도 없는 키
+	account, err = srv.NewKmsSrv(t.fake).CreateAccount(context.Background())
+	t.Require().NoError(err)
+	_, err = srv.NewKmsSrv(t.fake).CheckKeyPolicy(context.Background(), &dto.KeyIdReq{KeyID: account.KeyID}, "")
+	t.requireErrCode("BadRequestErr", err)
+
+	_, err = t.kmsSrv.CheckKeyPolicy(context.Background(), &dto.KeyIdReq{KeyID: "not-exist"}, "")
+	t.requireErrCode("KeyIdNotFoundErr", err)
+}
+
+func (t *KeyPolicyTestSuite) Test_Endpoint() {
+	account, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	t.fake.PutKeyPolicyForTest(account.KeyID, driftedPolicy)
+
+	app := fiber.New()
+	ctrl.NewKmsCtrl(t.kmsSrv).BootStrap(app)
+	res, err := app.Test(httptest.NewRequest("GET", "/accounts/"+account.KeyID+"/policy", nil))
+	t.Require().NoError(err)
+	t.Require().Equal(fiber.StatusOK, res.StatusCode)
+	var driftRes dto.KeyPolicyDriftRes
+	t.Require().NoError(json.NewDecoder(res.Body).Decode(&driftRes))
+	t.True(driftRes.Drifted)
+	t.Equal("signer", driftRes.Template)
+	t.Equal([]string{"AllowAllIAM"}, driftRes.Unexpected)
+}
+
+func (t *KeyPolicyTestSuite) requireErrCode(name string, err error) {
+	var cusErr *errs.CusErr
+	t.Require().True(errors.As(err, &cusErr), "%v", err)
+	t.Equal(errs.Errs[name].Code, cusErr.Code)
+}
+
+func TestKeyPolicyTestSuite(t *testing.T) {
+	suite.Run(t, new(KeyPolicyTestSuite))
+}
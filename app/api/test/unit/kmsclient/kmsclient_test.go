@@ -0,0 +1,170 @@
+package kmsclient_test
+
+// kms 호출의 제한시간, 재시도, circuit breaker 동작을 fakekms 의 에러 주입으로 확인하는 테스트
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	ctrl "kms/wallet/app/api/controller"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/app/kmsclient"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type KmsClientTestSuite struct {
+	suite.Suite
+	fake   *fakekms.FakeKms
+	client *kmsclient.ResilientClient
+	keyID  string
+}
+
+var (
+	errInternal   = &types.KMSInternalException{Message: aws.String("injected failure")}
+	errThrottling = &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+)
+
+func (t *KmsClientTestSuite) SetupSuite() {
+	logger.Init("test")
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *KmsClientTestSuite) SetupTest() {
+	t.fake = fakekms.New()
+	t.client = kmsclient.New(t.fake, kmsclient.Config{
+		Timeout:          50 * time.Millisecond,
+		MaxRetries:       2,
+		BaseDelay:        time.Millisecond,
+		MaxDelay:         5 * time.Millisecond,
+		FailureThreshold: 2,
+		OpenTimeout:      100 * time.Millisecond,
+	})
+
+	key, err := t.fake.CreateKey(context.Background(), &kms.CreateKeyInput{KeySpec: types.KeySpecEccSecgP256k1, KeyUsage: types.KeyUsageTypeSignVerify})
+	t.Require().NoError(err)
+	t.keyID = *key.KeyMetadata.KeyId
+}
+
+func (t *KmsClientTestSuite) getPublicKey() error {
+	_, err := t.client.GetPublicKey(context.Background(), &kms.GetPublicKeyInput{KeyId: aws.String(t.keyID)})
+	return err
+}
+
+func (t *KmsClientTestSuite) Test_RetryThrottling() {
+	t.fake.FailNext("GetPublicKey", errThrottling, errThrottling)
+	t.NoError(t.getPublicKey())
+	t.Equal(3, t.fake.Calls("GetPublicKey"))
+	t.Equal(kmsclient.StateClosed, t.client.Health().State)
+
+	// 재시도 횟수를 넘기면 요청 한도 초과 에러로 리턴한다
+	t.fake.FailNext("GetPublicKey", errThrottling, errThrottling, errThrottling)
+	_, err := srv.NewKmsSrv(t.client).GetAccount(context.Background(), &dto.KeyIdReq{KeyID: t.keyID})
+	var cusErr *errs.CusErr
+	t.Require().True(errors.As(err, &cusErr))
+	t.Equal(errs.Errs["KmsThrottlingErr"].Code, cusErr.Code)
+	// 요청 한도 초과는 kms 장애로 보지 않는다
+	t.Equal(kmsclient.StateClosed, t.client.Health().State)
+}
+
+func (t *KmsClientTestSuite) Test_NoRetryOnClientError() {
+	_, err := t.client.GetPublicKey(context.Background(), &kms.GetPublicKeyInput{KeyId: aws.String("not-exist")})
+	var notFoundErr *types.NotFoundException
+	t.True(errors.As(err, &notFoundErr))
+	t.Equal(1, t.fake.Calls("GetPublicKey"))
+}
+
+// 서버 에러 이후 CreateKey 를 재시도하면 키가 중복 생성될 수 있다
+func (t *KmsClientTestSuite) Test_NoRetryCreateKeyOnServerError() {
+	createCalls := t.fake.Calls("CreateKey")
+	t.fake.FailNext("CreateKey", errInternal)
+	_, err := t.client.CreateKey(context.Background(), &kms.CreateKeyInput{KeySpec: types.KeySpecEccSecgP256k1, KeyUsage: types.KeyUsageTypeSignVerify})
+	t.Error(err)
+	t.Equal(1, t.fake.Calls("CreateKey")-createCalls)
+}
+
+// 재시도는 ResilientClient 에서만 한다. 서비스에서 다시 재시도하면 호출 수가 곱해진다
+func (t *KmsClientTestSuite) Test_SingleRetryLayer() {
+	kmsSrv := srv.NewKmsSrv(t.client)
+	t.fake.FailNext("GetPublicKey", errThrottling, errThrottling, errThrottling, errThrottling)
+	t.Zero(kmsSrv.WarmUpPubKeys(context.Background(), []string{t.keyID}))
+	t.Equal(3, t.fake.Calls("GetPublicKey"))
+}
+
+func (t *KmsClientTestSuite) Test_Timeout() {
+	t.fake.SetLatency("GetPublicKey", time.Second)
+	start := time.Now()
+	err := t.getPublicKey()
+	t.ErrorIs(err, context.DeadlineExceeded)
+	t.Less(time.Since(start), 500*time.Millisecond)
+	t.Equal(3, t.fake.Calls("GetPublicKey"))
+
+	_, err = srv.NewKmsSrv(t.client).GetAccount(context.Background(), &dto.KeyIdReq{KeyID: t.keyID})
+	var cusErr *errs.CusErr
+	t.Require().True(errors.As(err, &cusErr))
+	t.Equal(errs.Errs["KmsUnavailableErr"].Code, cusErr.Code)
+}
+
+func (t *KmsClientTestSuite) Test_CircuitBreaker() {
+	app := fiber.New()
+	ctrl.NewAppCtrl(t.client).BootStrap(app)
+	healthState := func() (int, string) {
+		res, err := app.Test(httptest.NewRequest("GET", "/health", nil))
+		t.Require().NoError(err)
+		var body struct {
+			Kms kmsclient.Health `json:"kms"`
+		}
+		t.Require().NoError(json.NewDecoder(res.Body).Decode(&body))
+		return res.StatusCode, body.Kms.State
+	}
+
+	// 재시도 이후에도 실패한 호출 2번으로 circuit breaker 가 열린다
+	t.fake.FailNext("GetPublicKey", errInternal, errInternal, errInternal, errInternal, errInternal, errInternal)
+	t.Error(t.getPublicKey())
+	t.Error(t.getPublicKey())
+	status, state := healthState()
+	t.Equal(fiber.StatusServiceUnavailable, status)
+	t.Equal(kmsclient.StateOpen, state)
+
+	// 열려있는 동안은 kms 를 호출하지 않고 바로 실패한다
+	calls := t.fake.Calls("GetPublicKey")
+	t.ErrorIs(t.getPublicKey(), errs.ErrCircuitOpen)
+	t.Equal(calls, t.fake.Calls("GetPublicKey"))
+
+	// cooldown 이후 시험 호출이 성공하면 닫힌다
+	time.Sleep(150 * time.Millisecond)
+	_, state = healthState()
+	t.Equal(kmsclient.StateHalfOpen, state)
+	t.NoError(t.getPublicKey())
+	status, state = healthState()
+	t.Equal(fiber.StatusOK, status)
+	t.Equal(kmsclient.StateClosed, state)
+}
+
+// half-open 상태의 시험 호출이 실패하면 다시 열린다
+func (t *KmsClientTestSuite) Test_HalfOpenFailure() {
+	t.fake.FailNext("GetPublicKey", errInternal, errInternal, errInternal, errInternal, errInternal, errInternal, errInternal, errInternal, errInternal)
+	t.Error(t.getPublicKey())
+	t.Error(t.getPublicKey())
+	time.Sleep(150 * time.Millisecond)
+
+	t.Error(t.getPublicKey())
+	t.Equal(kmsclient.StateOpen, t.client.Health().State)
+	t.ErrorIs(t.getPublicKey(), errs.ErrCircuitOpen)
+}
+
+func Test(t *testing.T) {
+	suite.Run(t, new(KmsClientTestSuite))
+}
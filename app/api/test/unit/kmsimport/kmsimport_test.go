@@ -0,0 +1,211 @@
+package kmsimport_test
+
+// 외부키 주입이 실패하는 각 단계에서 kms key 껍데기가 삭제 예약되는지 확인하는 테스트 (fakekms 사용)
+
+import (
+	"context"
+	"errors"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/app/client"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/suite"
+)
+
+type KmsImportTestSuite struct {
+	suite.Suite
+	fake   *fakekms.FakeKms
+	kmsSrv *srv.KmsSrv
+}
+
+var errInjected = &types.KMSInternalException{Message: aws.String("injected failure")}
+
+func (t *KmsImportTestSuite) SetupSuite() {
+	logger.Init("test")
+	dto.Init()
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *KmsImportTestSuite) SetupTest() {
+	t.fake = fakekms.New()
+	t.kmsSrv = srv.NewKmsSrv(t.fake)
+}
+
+func (t *KmsImportTestSuite) pkReq() (*dto.PkReq, common.Address) {
+	ecdsaPK, err := crypto.GenerateKey()
+	t.NoError(err)
+	return &dto.PkReq{PK: common.Bytes2Hex(crypto.FromECDSA(ecdsaPK))}, crypto.PubkeyToAddress(ecdsaPK.PublicKey)
+}
+
+// 생성된 모든 키가 삭제 예약 상태인지 확인
+func (t *KmsImportTestSuite) requireShellsDeleted() {
+	t.NotEmpty(t.fake.KeyIDs())
+	for _, keyID := range t.fake.KeyIDs() {
+		t.Equal(types.KeyStatePendingDeletion, t.fake.KeyState(keyID), "keyID %v should be scheduled for deletion", keyID)
+	}
+}
+
+func (t *KmsImportTestSuite) Test_Success() {
+	pkReq, addr := t.pkReq()
+	pkReq.ExpectedAddress = addr.String()
+
+	accountRes, err := t.kmsSrv.ImportAccount(context.Background(), pkReq)
+	t.NoError(err)
+	t.Equal(addr.String(), accountRes.Address)
+	t.Equal(types.KeyStateEnabled, t.fake.KeyState(accountRes.KeyID))
+	t.Zero(t.fake.Calls("ScheduleKeyDeletion"))
+}
+
+func (t *KmsImportTestSuite) Test_ExpectedAddressMismatchBeforeCreate() {
+	pkReq, _ := t.pkReq()
+	pkReq.ExpectedAddress = "0x216690cD286d8a9c8D39d9714263bB6AB97046F3"
+
+	_, err := t.kmsSrv.ImportAccount(context.Background(), pkReq)
+	t.requireCode(err, "AddressMismatchErr")
+	t.Zero(t.fake.Calls("CreateKey"))
+}
+
+func (t *KmsImportTestSuite) Test_CreateKeyFails() {
+	t.fake.FailNext("CreateKey", errInjected)
+	pkReq, _ := t.pkReq()
+
+	_, err := t.kmsSrv.ImportAccount(context.Background(), pkReq)
+	t.Error(err)
+	t.Empty(t.fake.KeyIDs())
+	t.Zero(t.fake.Calls("ScheduleKeyDeletion"))
+}
+
+func (t *KmsImportTestSuite) Test_GetParametersForImportFails() {
+	t.fake.FailNext("GetParametersForImport", errInjected)
+	pkReq, _ := t.pkReq()
+
+	_, err := t.kmsSrv.ImportAccount(context.Background(), pkReq)
+	t.Error(err)
+	t.requireShellsDeleted()
+}
+
+func (t *KmsImportTestSuite) Test_ImportKeyMaterialFails() {
+	t.fake.FailNext("ImportKeyMaterial", errInjected)
+	pkReq, _ := t.pkReq()
+
+	_, err := t.kmsSrv.ImportAccount(context.Background(), pkReq)
+	t.Error(err)
+	t.requireShellsDeleted()
+}
+
+func (t *KmsImportTestSuite) Test_GetPublicKeyFails() {
+	t.fake.FailNext("GetPublicKey", errInjected)
+	pkReq, _ := t.pkReq()
+
+	_, err := t.kmsSrv.ImportAccount(context.Background(), pkReq)
+	t.Error(err)
+	t.requireShellsDeleted()
+}
+
+// 삭제 예약까지 실패하면 orphan 키가 에러 메세지로 보고된다
+func (t *KmsImportTestSuite) Test_CleanupFails() {
+	t.fake.FailNext("ImportKeyMaterial", errInjected)
+	t.fake.FailNext("ScheduleKeyDeletion", errInjected)
+	pkReq, _ := t.pkReq()
+
+	_, err := t.kmsSrv.ImportAccount(context.Background(), pkReq)
+	var customErr *errs.CusErr
+	t.Require().True(errors.As(err, &customErr))
+	t.Require().Len(t.fake.KeyIDs(), 1)
+	orphanKeyID := t.fake.KeyIDs()[0]
+	t.Contains(customErr.Inner.Error(), "orphaned import key '"+orphanKeyID+"'")
+	t.Equal(types.KeyStatePendingImport, t.fake.KeyState(orphanKeyID))
+}
+
+func (t *KmsImportTestSuite) Test_EncryptedImport() {
+	ecdsaPK, err := crypto.GenerateKey()
+	t.NoError(err)
+
+	importParamsRes, err := t.kmsSrv.GetImportParams(context.Background())
+	t.NoError(err)
+	encryptedReq, err := client.WrapForImport(ecdsaPK, importParamsRes)
+	t.NoError(err)
+
+	accountRes, err := t.kmsSrv.ImportEncryptedAccount(context.Background(), encryptedReq)
+	t.NoError(err)
+	t.Equal(crypto.PubkeyToAddress(ecdsaPK.PublicKey).String(), accountRes.Address)
+}
+
+func (t *KmsImportTestSuite) Test_EncryptedImportAddressMismatch() {
+	ecdsaPK, err := crypto.GenerateKey()
+	t.NoError(err)
+
+	importParamsRes, err := t.kmsSrv.GetImportParams(context.Background())
+	t.NoError(err)
+	encryptedReq, err := client.WrapForImport(ecdsaPK, importParamsRes)
+	t.NoError(err)
+	encryptedReq.ExpectedAddress = "0x216690cD286d8a9c8D39d9714263bB6AB97046F3"
+
+	_, err = t.kmsSrv.ImportEncryptedAccount(context.Background(), encryptedReq)
+	t.requireCode(err, "AddressMismatchErr")
+	t.requireShellsDeleted()
+}
+
+// 주입 대기중이 아닌 기존 키는 주입 실패시에도 삭제되면 안된다
+func (t *KmsImportTestSuite) Test_EncryptedImportRejectsExistingKey() {
+	accountRes, err := t.kmsSrv.CreateAccount(context.Background())
+	t.NoError(err)
+
+	_, err = t.kmsSrv.ImportEncryptedAccount(context.Background(), &dto.EncryptedImportReq{KeyID: accountRes.KeyID, ImportToken: "AA==", EncryptedKeyMaterial: "AA==", ExpectedAddress: accountRes.Address})
+	t.requireCode(err, "InvalidKeyErr")
+	t.Equal(types.KeyStateEnabled, t.fake.KeyState(accountRes.KeyID))
+}
+
+// 서버는 평문 키를 볼 수 없기 때문에 기대 주소 없이는 주입하지 않는다
+func (t *KmsImportTestSuite) Test_EncryptedImportRequiresAddress() {
+	ecdsaPK, err := crypto.GenerateKey()
+	t.NoError(err)
+
+	importParamsRes, err := t.kmsSrv.GetImportParams(context.Background())
+	t.NoError(err)
+	encryptedReq, err := client.WrapForImport(ecdsaPK, importParamsRes)
+	t.NoError(err)
+	encryptedReq.ExpectedAddress = ""
+
+	t.Error(dto.Validate(encryptedReq))
+	_, err = t.kmsSrv.ImportEncryptedAccount(context.Background(), encryptedReq)
+	t.requireCode(err, "BadRequestErr")
+	t.Zero(t.fake.Calls("ImportKeyMaterial"))
+	t.Equal(types.KeyStatePendingImport, t.fake.KeyState(importParamsRes.KeyID))
+}
+
+// 유도할 index 가 hardened 범위로 넘어가면 거부한다
+func (t *KmsImportTestSuite) Test_MnemonicIndexRange() {
+	mnemonicReq := &dto.MnemonicImportReq{
+		Mnemonic:   "test test test test test test test test test test test junk",
+		StartIndex: 1<<31 - 1,
+		Count:      2,
+		DryRun:     true,
+	}
+	_, err := t.kmsSrv.ImportMnemonic(context.Background(), mnemonicReq)
+	t.requireCode(err, "BadRequestErr")
+
+	mnemonicReq.Count = 1
+	importListRes, err := t.kmsSrv.ImportMnemonic(context.Background(), mnemonicReq)
+	t.Require().NoError(err)
+	t.Require().Len(importListRes.Accounts, 1)
+	t.Equal("m/44'/60'/0'/0/2147483647", importListRes.Accounts[0].Path)
+}
+
+func (t *KmsImportTestSuite) requireCode(err error, errName string) {
+	var customErr *errs.CusErr
+	t.Require().True(errors.As(err, &customErr), "expected errs.CusErr but got %v", err)
+	t.Equal(errs.Errs[errName].Code, customErr.Code)
+}
+
+func Test(t *testing.T) {
+	suite.Run(t, new(KmsImportTestSuite))
+}
@@ -0,0 +1,285 @@
+package kmsreplica_test
+
+// 다중 리전 키 생성, replica 복제, 원래 region 장애시 replica 서명을 fakekms 두개로 확인하는 테스트
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	ctrl "kms/wallet/app/api/controller"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/app/kmsclient"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+const replicaRegion = "us-west-2"
+
+var (
+	errInternal   = &types.KMSInternalException{Message: aws.String("injected failure")}
+	errThrottling = &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+)
+
+type KmsReplicaTestSuite struct {
+	suite.Suite
+	primary *fakekms.FakeKms
+	replica *fakekms.FakeKms
+	router  *kmsclient.Router
+	kmsSrv  *srv.KmsSrv
+}
+
+// 요청한 키 대신 다른 키로 서명하는 replica
+type wrongKeyReplica struct {
+	*fakekms.FakeKms
+	keyID string
+}
+
+func (r *wrongKeyReplica) Sign(ctx context.Context, params *kms.SignInput, optFns ...func(*kms.Options)) (*kms.SignOutput, error) {
+	input := *params
+	input.KeyId = aws.String(r.keyID)
+	return r.FakeKms.Sign(ctx, &input, optFns...)
+}
+
+func (t *KmsReplicaTestSuite) SetupSuite() {
+	logger.Init("test")
+	dto.Init()
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *KmsReplicaTestSuite) SetupTest() {
+	t.primary = fakekms.New()
+	t.replica = fakekms.NewIn(replicaRegion, fakekms.AccountID)
+	t.primary.AddReplicaRegion(t.replica)
+
+	router, err := kmsclient.NewRouter([]kmsclient.Backend{
+		{Name: "default", Region: fakekms.Region, Client: t.primary},
+		{Name: "dr", Region: replicaRegion, Client: t.replica},
+	}, nil)
+	t.Require().NoError(err)
+	t.router = router
+	t.kmsSrv = srv.NewKmsSrv(router, srv.WithReplicaRegions(srv.ReplicaRegion{Region: replicaRegion, Client: t.replica}))
+}
+
+func (t *KmsReplicaTestSuite) createReplicated() *dto.AccountRes {
+	account, err := t.kmsSrv.CreateAccount(context.Background(), srv.Replicate())
+	t.Require().NoError(err)
+	return account
+}
+
+// 서명에서 복구할 수 있는 address 들 (v = 0, 1)
+func (t *KmsReplicaTestSuite) signers(keyID string, digest []byte) []string {
+	R, S, err := t.kmsSrv.Sign(context.Background(), keyID, digest)
+	t.Require().NoError(err)
+	sig := append(new(big.Int).SetBytes(R).FillBytes(make([]byte, 32)), new(big.Int).SetBytes(S).FillBytes(make([]byte, 32))...)
+
+	var addresses []string
+	for v := byte(0); v < 2; v++ {
+		if pubKey, err := crypto.SigToPub(digest, append(sig, v)); err == nil {
+			addresses = append(addresses, crypto.PubkeyToAddress(*pubKey).String())
+		}
+	}
+	return addresses
+}
+
+func (t *KmsReplicaTestSuite) Test_CreateReplicated() {
+	app := fiber.New(fiber.Config{ErrorHandler: func(ctx *fiber.Ctx, err error) error {
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}})
+	ctrl.NewKmsCtrl(t.kmsSrv).BootStrap(app)
+	res, err := app.Test(httptest.NewRequest("POST", "/create/account?replicate=true", nil))
+	t.Require().NoError(err)
+	t.Require().Equal(fiber.StatusCreated, res.StatusCode)
+	var account dto.AccountRes
+	t.Require().NoError(json.NewDecoder(res.Body).Decode(&account))
+
+	t.True(strings.HasPrefix(account.KeyID, "mrk-"))
+	t.Equal([]string{replicaRegion}, account.ReplicaRegions)
+	t.Equal([]string{account.KeyID}, t.replica.KeyIDs())
+
+	// replica 는 key material 을 공유하기 때문에 address 가 같다
+	replicaAccount, err := srv.NewKmsSrv(t.replica).GetAccount(context.Background(), &dto.KeyIdReq{KeyID: account.KeyID})
+	t.Require().NoError(err)
+	t.Equal(account.Address, replicaAccount.Address)
+}
+
+func (t *KmsReplicaTestSuite) Test_CreateOptions() {
+	account, err := t.kmsSrv.CreateAccount(context.Background(), srv.MultiRegion())
+	t.Require().NoError(err)
+	t.True(strings.HasPrefix(account.KeyID, "mrk-"))
+	t.Empty(account.ReplicaRegions)
+	t.Empty(t.replica.KeyIDs())
+
+	account, err = t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	t.False(strings.HasPrefix(account.KeyID, "mrk-"))
+
+	// 복제할 region 이 없으면 키를 만들지 않는다
+	createCalls := t.primary.Calls("CreateKey")
+	_, err = srv.NewKmsSrv(t.primary).CreateAccount(context.Background(), srv.Replicate())
+	var cusErr *errs.CusErr
+	t.Require().True(errors.As(err, &cusErr))
+	t.Equal(errs.Errs["BadRequestErr"].Code, cusErr.Code)
+	t.Equal(createCalls, t.primary.Calls("CreateKey"))
+}
+
+func (t *KmsReplicaTestSuite) Test_SignFailover() {
+	account := t.createReplicated()
+	digest := crypto.Keccak256([]byte("failover"))
+
+	for _, err := range []error{errInternal, errThrottling} {
+		replicaCalls := t.replica.Calls("Sign")
+		t.primary.FailNext("Sign", err)
+		t.Contains(t.signers(account.KeyID, digest), account.Address)
+		t.Equal(replicaCalls+1, t.replica.Calls("Sign"))
+	}
+
+	// 키 상태 같은 요청 에러는 replica 로 넘기지 않는다
+	replicaCalls := t.replica.Calls("Sign")
+	t.primary.FailNext("Sign", &types.DisabledException{Message: aws.String("disabled")})
+	_, _, err := t.kmsSrv.Sign(context.Background(), account.KeyID, digest)
+	t.Error(err)
+	t.Equal(replicaCalls, t.replica.Calls("Sign"))
+
+	// replica 도 실패하면 원래 에러를 리턴한다
+	t.primary.FailNext("Sign", errInternal)
+	t.replica.FailNext("Sign", errThrottling)
+	_, _, err = t.kmsSrv.Sign(context.Background(), account.KeyID, digest)
+	var cusErr *errs.CusErr
+	t.Require().True(errors.As(err, &cusErr))
+	t.Equal(errs.Errs["KmsUnavailableErr"].Code, cusErr.Code)
+}
+
+// 복제에 실패한 키는 삭제 예약하고 keyID 를 에러에 남긴다
+func (t *KmsReplicaTestSuite) Test_ReplicateFails() {
+	t.primary.FailNext("ReplicateKey", errInternal)
+	_, err := t.kmsSrv.CreateAccount(context.Background(), srv.Replicate())
+	var cusErr *errs.CusErr
+	t.Require().True(errors.As(err, &cusErr), "%v", err)
+
+	keyIDs := t.primary.KeyIDs()
+	t.Require().Len(keyIDs, 1)
+	t.Equal(keyIDs[0], cusErr.Details["keyID"])
+	t.ErrorContains(cusErr.Inner, keyIDs[0]+"' scheduled for deletion")
+	t.Equal(types.KeyStatePendingDeletion, t.primary.KeyState(keyIDs[0]))
+	t.Empty(t.replica.KeyIDs())
+
+	// 삭제 예약도 실패하면 orphan 으로 남은 키를 알려준다
+	t.primary.FailNext("ReplicateKey", errInternal)
+	t.primary.FailNext("ScheduleKeyDeletion", errInternal)
+	_, err = t.kmsSrv.CreateAccount(context.Background(), srv.Replicate())
+	t.Require().True(errors.As(err, &cusErr), "%v", err)
+	t.ErrorContains(cusErr.Inner, "could not be scheduled for deletion")
+}
+
+// public key 가 캐시에 없어도 원래 region 에서 조회해서 replica 의 서명을 확인한다
+func (t *KmsReplicaTestSuite) Test_VerifyUncachedReplicaSignature() {
+	account := t.createReplicated()
+	digest := crypto.Keccak256([]byte("failover"))
+	t.kmsSrv = srv.NewKmsSrv(t.router, srv.WithReplicaRegions(srv.ReplicaRegion{Region: replicaRegion, Client: t.replica}))
+
+	pubKeyCalls := t.primary.Calls("GetPublicKey")
+	t.primary.FailNext("Sign", errInternal)
+	t.Contains(t.signers(account.KeyID, digest), account.Address)
+	t.Equal(pubKeyCalls+1, t.primary.Calls("GetPublicKey"))
+
+	// 어느 region 에서도 public key 를 조회할 수 없으면 확인하지 못한 서명을 리턴하지 않는다
+	t.kmsSrv = srv.NewKmsSrv(t.router, srv.WithReplicaRegions(srv.ReplicaRegion{Region: replicaRegion, Client: t.replica}))
+	t.primary.FailNext("Sign", errInternal)
+	t.primary.FailNext("GetPublicKey", errInternal)
+	t.replica.FailNext("GetPublicKey", errInternal)
+	replicaCalls := t.replica.Calls("Sign")
+	_, _, err := t.kmsSrv.Sign(context.Background(), account.KeyID, digest)
+	t.Error(err)
+	t.Equal(replicaCalls+1, t.replica.Calls("Sign"))
+}
+
+// 캐시가 비어있고 원래 region 이 응답하지 않으면 public key 를 replica 에서 조회한다
+func (t *KmsReplicaTestSuite) Test_PubKeyFromReplicaWhenPrimaryDown() {
+	account := t.createReplicated()
+	digest := crypto.Keccak256([]byte("failover"))
+
+	t.kmsSrv = srv.NewKmsSrv(t.router, srv.WithReplicaRegions(srv.ReplicaRegion{Region: replicaRegion, Client: t.replica}))
+	pubKeyCalls := t.replica.Calls("GetPublicKey")
+	t.primary.FailNext("Sign", errInternal)
+	t.primary.FailNext("GetPublicKey", errThrottling)
+	t.Contains(t.signers(account.KeyID, digest), account.Address)
+	t.Equal(pubKeyCalls+1, t.replica.Calls("GetPublicKey"))
+
+	// 트랜잭션 서명에 쓰는 address 조회도 같다
+	t.kmsSrv = srv.NewKmsSrv(t.router, srv.WithReplicaRegions(srv.ReplicaRegion{Region: replicaRegion, Client: t.replica}))
+	t.primary.FailNext("GetPublicKey", errInternal)
+	pubKey, err := t.kmsSrv.GetPubkey(context.Background(), &dto.KeyIdReq{KeyID: account.KeyID})
+	t.Require().NoError(err)
+	ecdsaPubKey, err := crypto.UnmarshalPubkey(pubKey)
+	t.Require().NoError(err)
+	t.Equal(account.Address, crypto.PubkeyToAddress(*ecdsaPubKey).String())
+
+	// 키 상태 같은 요청 에러는 replica 로 넘기지 않는다
+	t.kmsSrv = srv.NewKmsSrv(t.router, srv.WithReplicaRegions(srv.ReplicaRegion{Region: replicaRegion, Client: t.replica}))
+	pubKeyCalls = t.replica.Calls("GetPublicKey")
+	t.primary.FailNext("GetPublicKey", &types.DisabledException{Message: aws.String("disabled")})
+	_, err = t.kmsSrv.GetPubkey(context.Background(), &dto.KeyIdReq{KeyID: account.KeyID})
+	t.Error(err)
+	t.Equal(pubKeyCalls, t.replica.Calls("GetPublicKey"))
+}
+
+func (t *KmsReplicaTestSuite) Test_ReplicaSignatureMismatch() {
+	account := t.createReplicated()
+	otherKey, err := t.replica.CreateKey(context.Background(), &kms.CreateKeyInput{
+		KeySpec:  types.KeySpecEccSecgP256k1,
+		KeyUsage: types.KeyUsageTypeSignVerify,
+	})
+	t.Require().NoError(err)
+	replica := &wrongKeyReplica{FakeKms: t.replica, keyID: *otherKey.KeyMetadata.KeyId}
+	t.kmsSrv = srv.NewKmsSrv(t.router, srv.WithReplicaRegions(srv.ReplicaRegion{Region: replicaRegion, Client: replica}))
+
+	t.primary.FailNext("Sign", errInternal)
+	_, _, err = t.kmsSrv.Sign(context.Background(), account.KeyID, crypto.Keccak256([]byte("failover")))
+	var cusErr *errs.CusErr
+	t.Require().True(errors.As(err, &cusErr), "%v", err)
+	t.Equal(errs.Errs["InternalServerErr"].Code, cusErr.Code)
+}
+
+// replica 에도 원래 키와 같은 key policy 템플릿과 tag 가 적용된다
+func (t *KmsReplicaTestSuite) Test_ReplicaKeyPolicy() {
+	keyPolicies := srv.KeyPolicies{
+		Default:   "signer",
+		Documents: map[string]string{"signer": `{"Version":"2012-10-17","Statement":[{"Sid":"EnableRoot","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::111122223333:root"},"Action":"kms:*","Resource":"*"}]}`},
+	}
+	t.kmsSrv = srv.NewKmsSrv(t.router, srv.WithKeyPolicies(keyPolicies), srv.WithReplicaRegions(srv.ReplicaRegion{Region: replicaRegion, Client: t.replica}))
+	account := t.createReplicated()
+	t.Equal("signer", t.replica.Tags(account.KeyID)["KeyPolicyTemplate"])
+
+	driftRes, err := srv.NewKmsSrv(t.replica, srv.WithKeyPolicies(keyPolicies)).CheckKeyPolicy(context.Background(), &dto.KeyIdReq{KeyID: account.KeyID}, "")
+	t.Require().NoError(err)
+	t.Equal(&dto.KeyPolicyDriftRes{KeyID: account.KeyID, Template: "signer"}, driftRes)
+}
+
+// 다중 리전 키가 아니면 replica 가 없다
+func (t *KmsReplicaTestSuite) Test_NoFailoverForSingleRegionKey() {
+	account, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+
+	t.primary.FailNext("Sign", errInternal)
+	_, _, err = t.kmsSrv.Sign(context.Background(), account.KeyID, crypto.Keccak256([]byte("failover")))
+	t.Error(err)
+	t.Equal(0, t.replica.Calls("Sign"))
+}
+
+func TestKmsReplicaTestSuite(t *testing.T) {
+	suite.Run(t, new(KmsReplicaTestSuite))
+}
@@ -0,0 +1,169 @@
+package kmsrouter_test
+
+// 여러 region, 계정의 kms 중 keyID 에 맞는 곳으로 호출을 보내는지 fakekms 두개로 확인하는 테스트
+
+import (
+	"context"
+	"errors"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/app/kmsclient"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	drRegion  = "us-west-2"
+	drAccount = "444455556666"
+)
+
+type KmsRouterTestSuite struct {
+	suite.Suite
+	primary *fakekms.FakeKms
+	dr      *fakekms.FakeKms
+}
+
+func (t *KmsRouterTestSuite) SetupSuite() {
+	logger.Init("test")
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *KmsRouterTestSuite) SetupTest() {
+	t.primary = fakekms.New()
+	t.dr = fakekms.NewIn(drRegion, drAccount)
+}
+
+func (t *KmsRouterTestSuite) newKmsSrv(keyRoutes map[string]string) *srv.KmsSrv {
+	router, err := kmsclient.NewRouter([]kmsclient.Backend{
+		{Name: "default", Region: fakekms.Region, Account: fakekms.AccountID, Client: t.primary},
+		{Name: "dr", Region: drRegion, Account: drAccount, Client: t.dr},
+	}, keyRoutes)
+	t.Require().NoError(err)
+	return srv.NewKmsSrv(router)
+}
+
+func (t *KmsRouterTestSuite) createKey(fake *fakekms.FakeKms) *types.KeyMetadata {
+	key, err := fake.CreateKey(context.Background(), &kms.CreateKeyInput{KeySpec: types.KeySpecEccSecgP256k1, KeyUsage: types.KeyUsageTypeSignVerify})
+	t.Require().NoError(err)
+	return key.KeyMetadata
+}
+
+func (t *KmsRouterTestSuite) Test_RouteByArn() {
+	key := t.createKey(t.dr)
+	kmsSrv := t.newKmsSrv(nil)
+
+	account, err := kmsSrv.GetAccount(context.Background(), &dto.KeyIdReq{KeyID: *key.Arn})
+	t.Require().NoError(err)
+	t.NotEmpty(account.Address)
+	_, _, err = kmsSrv.Sign(context.Background(), *key.Arn, make([]byte, 32))
+	t.Require().NoError(err)
+
+	// ARN 의 region, 계정으로 바로 찾기 때문에 기본 backend 는 호출하지 않는다
+	t.Equal(0, t.primary.Calls("DescribeKey"))
+	t.Equal(0, t.primary.Calls("GetPublicKey"))
+	t.Equal(1, t.dr.Calls("Sign"))
+
+	// 설정된 backend 가 없는 region 의 키
+	_, err = kmsSrv.GetAccount(context.Background(), &dto.KeyIdReq{KeyID: "arn:aws:kms:eu-west-1:" + drAccount + ":key/" + *key.KeyId})
+	t.requireErrCode("KeyIdNotFoundErr", err)
+}
+
+func (t *KmsRouterTestSuite) Test_RouteByMapping() {
+	key := t.createKey(t.dr)
+	kmsSrv := t.newKmsSrv(map[string]string{*key.KeyId: "dr"})
+
+	_, _, err := kmsSrv.Sign(context.Background(), *key.KeyId, make([]byte, 32))
+	t.Require().NoError(err)
+	t.Equal(0, t.primary.Calls("DescribeKey"))
+	t.Equal(0, t.primary.Calls("GetPublicKey"))
+	t.Equal(0, t.dr.Calls("DescribeKey"))
+	t.Equal(1, t.dr.Calls("Sign"))
+}
+
+func (t *KmsRouterTestSuite) Test_RouteByProbe() {
+	key := t.createKey(t.dr)
+	kmsSrv := t.newKmsSrv(nil)
+
+	for i := 0; i < 2; i++ {
+		_, _, err := kmsSrv.Sign(context.Background(), *key.KeyId, make([]byte, 32))
+		t.Require().NoError(err)
+	}
+	// 찾은 backend 는 기억해서 다시 찾지 않는다
+	t.Equal(1, t.primary.Calls("DescribeKey"))
+	t.Equal(1, t.dr.Calls("DescribeKey"))
+	t.Equal(2, t.dr.Calls("Sign"))
+
+	_, err := kmsSrv.GetAccount(context.Background(), &dto.KeyIdReq{KeyID: "not-exist"})
+	t.requireErrCode("KeyIdNotFoundErr", err)
+
+	// 키가 없는 것이 아닌 에러는 그대로 리턴한다
+	t.dr.FailNext("DescribeKey", &types.KMSInternalException{Message: aws.String("injected failure")})
+	_, err = kmsSrv.GetAccount(context.Background(), &dto.KeyIdReq{KeyID: "not-exist"})
+	var cusErr *errs.CusErr
+	t.Require().True(errors.As(err, &cusErr))
+	t.NotEqual(errs.Errs["KeyIdNotFoundErr"].Code, cusErr.Code)
+}
+
+// 새 키는 기본 backend 에 만든다
+func (t *KmsRouterTestSuite) Test_CreateOnDefault() {
+	kmsSrv := t.newKmsSrv(nil)
+	account, err := kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	t.Equal([]string{account.KeyID}, t.primary.KeyIDs())
+	t.Empty(t.dr.KeyIDs())
+
+	_, _, err = kmsSrv.Sign(context.Background(), account.KeyID, make([]byte, 32))
+	t.Require().NoError(err)
+	t.Equal(0, t.dr.Calls("DescribeKey"))
+}
+
+func (t *KmsRouterTestSuite) Test_AccountListAggregates() {
+	var expected []string
+	for i := 0; i < 3; i++ {
+		expected = append(expected, *t.createKey(t.primary).KeyId)
+	}
+	for i := 0; i < 2; i++ {
+		expected = append(expected, *t.createKey(t.dr).KeyId)
+	}
+	kmsSrv := t.newKmsSrv(nil)
+
+	// backend 의 경계를 넘는 페이지도 limit 만큼 채운다
+	var listed []string
+	var pages int
+	req := &dto.AccountListReq{Limit: aws.Int32(2)}
+	for {
+		res, err := kmsSrv.GetAccountList(context.Background(), req)
+		t.Require().NoError(err)
+		pages++
+		for _, account := range res.Accounts {
+			t.NotEmpty(account.Address)
+			listed = append(listed, account.KeyID)
+		}
+		if res.Marker == "" {
+			break
+		}
+		req.Marker = aws.String(res.Marker)
+	}
+	t.ElementsMatch(expected, listed)
+	t.Equal(3, pages)
+
+	_, err := kmsSrv.GetAccountList(context.Background(), &dto.AccountListReq{Marker: aws.String("invalid")})
+	t.requireErrCode("InvalidMarkerErr", err)
+}
+
+func (t *KmsRouterTestSuite) requireErrCode(name string, err error) {
+	var cusErr *errs.CusErr
+	t.Require().True(errors.As(err, &cusErr), "%v", err)
+	t.Equal(errs.Errs[name].Code, cusErr.Code)
+}
+
+func TestKmsRouterTestSuite(t *testing.T) {
+	suite.Run(t, new(KmsRouterTestSuite))
+}
@@ -0,0 +1,172 @@
+package metrics_test
+
+// /metrics 에 요청, kms 호출, 캐시, circuit breaker, keyID 별 서명 지표가 노출되는지 확인하는 테스트
+
+import (
+	"context"
+	"io"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/app/kmsclient"
+	"kms/wallet/app/metrics"
+	"kms/wallet/app/server"
+	"kms/wallet/common/config"
+	"kms/wallet/common/logger"
+	"math/big"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricsTestSuite struct {
+	suite.Suite
+	app    *fiber.App
+	fake   *fakekms.FakeKms
+	client *kmsclient.ResilientClient
+	kmsSrv *srv.KmsSrv
+}
+
+func (t *MetricsTestSuite) SetupSuite() {
+	logger.Init("test")
+
+	t.fake = fakekms.New()
+	clientConfig := kmsclient.DefaultConfig()
+	clientConfig.MaxRetries = 0
+	t.client = kmsclient.New(t.fake, clientConfig)
+	t.kmsSrv = srv.NewKmsSrv(t.client)
+	metrics.SetPubKeyCache(t.kmsSrv.PubKeyCacheStats)
+	metrics.SetBreaker(func() string { return t.client.Health().State })
+
+	t.app = t.newApp()
+}
+
+func (t *MetricsTestSuite) newApp() *fiber.App {
+	config.Env = config.Default()
+	config.Env.Environment = "test"
+	app := server.New().App
+	app.Get("/api/accounts/:keyID", func(c *fiber.Ctx) error {
+		accountRes, err := t.kmsSrv.GetAccount(c.UserContext(), &dto.KeyIdReq{KeyID: c.Params("keyID")})
+		if err != nil {
+			return err
+		}
+		return c.JSON(accountRes)
+	})
+	return app
+}
+
+func (t *MetricsTestSuite) scrape() string {
+	res, err := t.app.Test(httptest.NewRequest(fiber.MethodGet, "/metrics", nil), -1)
+	t.Require().NoError(err)
+	body, err := io.ReadAll(res.Body)
+	t.Require().NoError(err)
+	return string(body)
+}
+
+func (t *MetricsTestSuite) get(path string) int {
+	res, err := t.app.Test(httptest.NewRequest(fiber.MethodGet, path, nil), -1)
+	t.Require().NoError(err)
+	return res.StatusCode
+}
+
+func (t *MetricsTestSuite) Test_Requests() {
+	accountRes, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+
+	t.Equal(fiber.StatusOK, t.get("/api/accounts/"+accountRes.KeyID))
+	t.Equal(fiber.StatusNotFound, t.get("/api/accounts/not-exist"))
+	t.Equal(fiber.StatusNotFound, t.get("/api/unknown/"+accountRes.KeyID))
+
+	body := t.scrape()
+	t.Contains(body, `wallet_http_requests_total{method="GET",route="/api/accounts/:keyID",status="200"} 1`)
+	t.Contains(body, `wallet_http_requests_total{method="GET",route="/api/accounts/:keyID",status="404"} 1`)
+	t.Contains(body, `wallet_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	t.Contains(body, `wallet_http_request_duration_seconds_count{method="GET",route="/api/accounts/:keyID",status="200"} 1`)
+	t.Contains(body, `wallet_kms_call_duration_seconds_count{operation="GetPublicKey"}`)
+	// 조회는 키 상태부터 확인한다
+	t.Contains(body, `wallet_kms_call_errors_total{code="KEY_NOT_FOUND",operation="DescribeKey"} 1`)
+	t.Contains(body, "wallet_pubkey_cache_hits_total")
+	t.Contains(body, "wallet_pubkey_cache_misses_total")
+
+	// 라벨에 keyID 가 들어가면 안된다
+	t.NotContains(body, accountRes.KeyID)
+}
+
+func (t *MetricsTestSuite) Test_Breaker() {
+	// 다른 테스트에 영향이 없도록 별도의 client 를 사용한다
+	clientConfig := kmsclient.DefaultConfig()
+	clientConfig.MaxRetries = 0
+	clientConfig.FailureThreshold = 1
+	clientConfig.OpenTimeout = time.Hour
+	client := kmsclient.New(t.fake, clientConfig)
+	metrics.SetBreaker(func() string { return client.Health().State })
+	defer metrics.SetBreaker(func() string { return t.client.Health().State })
+	t.Regexp(regexp.MustCompile(`wallet_kms_circuit_state\{state="closed"\} 1`), t.scrape())
+
+	t.fake.FailNext("ListKeys", &types.KMSInternalException{Message: aws.String("injected failure")})
+	_, err := srv.NewKmsSrv(client).GetAccountList(context.Background(), &dto.AccountListReq{})
+	t.Error(err)
+
+	body := t.scrape()
+	t.Contains(body, `wallet_kms_circuit_state{state="open"} 1`)
+	t.Contains(body, `wallet_kms_call_errors_total{code="KMS_UNAVAILABLE",operation="ListKeys"} 1`)
+}
+
+func (t *MetricsTestSuite) Test_PerKey() {
+	kmsSrv := srv.NewKmsSrv(t.fake)
+	txnSrv := srv.NewTxnSrv(big.NewInt(1), kmsSrv)
+	accountRes, err := kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	txnReq := &dto.TxnReq{KeyID: accountRes.KeyID, SerializedTxn: serializedTxn(t)}
+
+	_, err = txnSrv.SignSerializedTxn(context.Background(), txnReq)
+	t.Require().NoError(err)
+	t.NotContains(t.scrape(), accountRes.KeyID)
+
+	metrics.EnablePerKey(true)
+	defer metrics.EnablePerKey(false)
+	_, err = txnSrv.SignSerializedTxn(context.Background(), txnReq)
+	t.Require().NoError(err)
+	t.Contains(t.scrape(), `wallet_sign_by_key_total{key_id="`+accountRes.KeyID+`"} 1`)
+}
+
+func (t *MetricsTestSuite) Test_LimiterRejections() {
+	app := fiber.New()
+	app.Use(limiter.New(limiter.Config{Max: 1, Expiration: time.Minute, LimitReached: metrics.LimitReached}))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	for i, status := range []int{fiber.StatusOK, fiber.StatusTooManyRequests} {
+		res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil), -1)
+		t.Require().NoError(err)
+		t.Equal(status, res.StatusCode, "request %v", i)
+	}
+	t.Contains(t.scrape(), "wallet_http_limiter_rejections_total 1")
+}
+
+func serializedTxn(t *MetricsTestSuite) string {
+	txn := ethTypes.NewTx(&ethTypes.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     0,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(1),
+		Gas:       21000,
+		To:        &common.Address{},
+		Value:     big.NewInt(0),
+	})
+	data, err := txn.MarshalBinary()
+	t.Require().NoError(err)
+	return "0x" + common.Bytes2Hex(data)
+}
+
+func TestMetricsTestSuite(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
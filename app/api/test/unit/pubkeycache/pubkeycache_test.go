@@ -0,0 +1,180 @@
+package pubkeycache_test
+
+// public key 캐시의 LRU 제한, TTL, 디스크 스냅샷, KmsSrv 의 캐시 무효화, 동시 조회 병합을 확인하는 테스트 (fakekms 사용)
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/app/cache"
+	"kms/wallet/common/logger"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/suite"
+)
+
+type PubKeyCacheTestSuite struct {
+	suite.Suite
+	snapshotPath string
+}
+
+func (t *PubKeyCacheTestSuite) SetupSuite() {
+	logger.Init("test")
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *PubKeyCacheTestSuite) SetupTest() {
+	t.snapshotPath = filepath.Join(t.T().TempDir(), "pubkeys.json")
+}
+
+func (t *PubKeyCacheTestSuite) newPubKey() *ecdsa.PublicKey {
+	ecdsaPK, err := crypto.GenerateKey()
+	t.Require().NoError(err)
+	return &ecdsaPK.PublicKey
+}
+
+func (t *PubKeyCacheTestSuite) Test_LRUEviction() {
+	pubKeyCache, err := cache.NewPubKeyCache(cache.PubKeyCacheConfig{Size: 2})
+	t.Require().NoError(err)
+
+	pubKeyCache.Add("a", t.newPubKey())
+	pubKeyCache.Add("b", t.newPubKey())
+	t.NotNil(pubKeyCache.Get("a")) // b 가 가장 오래 사용되지 않은 항목이 된다
+	pubKeyCache.Add("c", t.newPubKey())
+
+	t.NotNil(pubKeyCache.Get("a"))
+	t.Nil(pubKeyCache.Get("b"))
+	t.NotNil(pubKeyCache.Get("c"))
+	t.Equal(cache.PubKeyCacheStats{Hits: 3, Misses: 1, Evictions: 1, Size: 2}, pubKeyCache.Stats())
+}
+
+func (t *PubKeyCacheTestSuite) Test_TTL() {
+	pubKeyCache, err := cache.NewPubKeyCache(cache.PubKeyCacheConfig{TTL: 50 * time.Millisecond})
+	t.Require().NoError(err)
+
+	pubKeyCache.Add("a", t.newPubKey())
+	t.NotNil(pubKeyCache.Get("a"))
+	time.Sleep(100 * time.Millisecond)
+	t.Nil(pubKeyCache.Get("a"))
+	t.Zero(pubKeyCache.Stats().Size)
+}
+
+func (t *PubKeyCacheTestSuite) Test_Snapshot() {
+	pubKeyCache, err := cache.NewPubKeyCache(cache.PubKeyCacheConfig{SnapshotPath: t.snapshotPath})
+	t.Require().NoError(err)
+	pubKeyA, pubKeyB := t.newPubKey(), t.newPubKey()
+	pubKeyCache.Add("a", pubKeyA)
+	pubKeyCache.Add("b", pubKeyB)
+	pubKeyCache.Remove("b")
+
+	reloaded, err := cache.NewPubKeyCache(cache.PubKeyCacheConfig{SnapshotPath: t.snapshotPath})
+	t.Require().NoError(err)
+	t.True(pubKeyA.Equal(reloaded.Get("a")))
+	t.Nil(reloaded.Get("b"))
+
+	// 스냅샷에서 읽어올 때도 크기 제한을 지킨다
+	bounded, err := cache.NewPubKeyCache(cache.PubKeyCacheConfig{SnapshotPath: t.snapshotPath, Size: 1})
+	t.Require().NoError(err)
+	t.Equal(1, bounded.Stats().Size)
+}
+
+// 재시작 이후에도 스냅샷 덕분에 GetPublicKey 를 다시 호출하지 않고, 삭제/비활성화하면 캐시에서 제거된다
+func (t *PubKeyCacheTestSuite) Test_KmsSrvEviction() {
+	fake := fakekms.New()
+	newKmsSrv := func() *srv.KmsSrv {
+		pubKeyCache, err := cache.NewPubKeyCache(cache.PubKeyCacheConfig{SnapshotPath: t.snapshotPath})
+		t.Require().NoError(err)
+		return srv.NewKmsSrv(fake, srv.WithPubKeyCache(pubKeyCache))
+	}
+
+	kmsSrv := newKmsSrv()
+	deleted, err := kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	disabled, err := kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	t.Equal(2, fake.Calls("GetPublicKey"))
+
+	kmsSrv = newKmsSrv()
+	_, err = kmsSrv.GetAccount(context.Background(), &dto.KeyIdReq{KeyID: deleted.KeyID})
+	t.NoError(err)
+	t.Equal(2, fake.Calls("GetPublicKey"))
+
+	_, err = kmsSrv.DeleteAccount(context.Background(), &dto.KeyIdReq{KeyID: deleted.KeyID})
+	t.NoError(err)
+	disabledRes, err := kmsSrv.DisableAccount(context.Background(), &dto.KeyIdReq{KeyID: disabled.KeyID}, &dto.FreezeReq{Reason: "test", Actor: "tester"})
+	t.NoError(err)
+	t.Equal(disabled.Address, disabledRes.Address)
+	t.True(disabledRes.Frozen)
+
+	reloaded := newKmsSrv()
+	t.Zero(reloaded.PubKeyCacheStats().Size)
+	_, err = reloaded.GetAccount(context.Background(), &dto.KeyIdReq{KeyID: deleted.KeyID})
+	t.Error(err)
+}
+
+// 같은 keyID 에 대한 동시 조회는 GetPublicKey 한번으로 합쳐진다
+func (t *PubKeyCacheTestSuite) Test_ConcurrentFetchCoalesced() {
+	fake := fakekms.New()
+	kmsSrv := srv.NewKmsSrv(fake)
+	accountRes, err := kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+
+	// 새로 만든 서비스는 캐시가 비어있다
+	kmsSrv = srv.NewKmsSrv(fake)
+	fake.SetLatency("GetPublicKey", 100*time.Millisecond)
+	before := fake.Calls("GetPublicKey")
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := kmsSrv.GetAccount(context.Background(), &dto.KeyIdReq{KeyID: accountRes.KeyID})
+			if err == nil && res.Address != accountRes.Address {
+				err = fmt.Errorf("unexpected address %v", res.Address)
+			}
+			errCh <- err
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		t.NoError(err)
+	}
+	t.Equal(1, fake.Calls("GetPublicKey")-before)
+}
+
+func (t *PubKeyCacheTestSuite) Test_WarmUp() {
+	fake := fakekms.New()
+	kmsSrv := srv.NewKmsSrv(fake)
+	var keyIDs []string
+	for i := 0; i < 3; i++ {
+		accountRes, err := kmsSrv.CreateAccount(context.Background())
+		t.Require().NoError(err)
+		keyIDs = append(keyIDs, accountRes.KeyID)
+	}
+
+	kmsSrv = srv.NewKmsSrv(fake)
+	t.Equal(3, kmsSrv.WarmUpPubKeys(context.Background(), append(keyIDs, "not-exist")))
+	t.Equal(3, kmsSrv.PubKeyCacheStats().Size)
+
+	before := fake.Calls("GetPublicKey")
+	for _, keyID := range keyIDs {
+		_, err := kmsSrv.GetAccount(context.Background(), &dto.KeyIdReq{KeyID: keyID})
+		t.NoError(err)
+	}
+	t.Equal(before, fake.Calls("GetPublicKey"))
+}
+
+func Test(t *testing.T) {
+	suite.Run(t, new(PubKeyCacheTestSuite))
+}
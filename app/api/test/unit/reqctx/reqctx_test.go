@@ -0,0 +1,112 @@
+package reqctx_test
+
+// 요청 context 의 취소, 제한시간이 kms 호출까지 전달되어 408, 499 로 응답하는지 확인하는 테스트
+
+import (
+	"context"
+	"errors"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/app/kmsclient"
+	"kms/wallet/app/server"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReqCtxTestSuite struct {
+	suite.Suite
+	fake   *fakekms.FakeKms
+	kmsSrv *srv.KmsSrv
+	keyID  string
+}
+
+func (t *ReqCtxTestSuite) SetupSuite() {
+	logger.Init("test")
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *ReqCtxTestSuite) SetupTest() {
+	t.fake = fakekms.New()
+	config := kmsclient.DefaultConfig()
+	config.Timeout = 100 * time.Millisecond
+	config.BaseDelay = time.Millisecond
+	t.kmsSrv = srv.NewKmsSrv(kmsclient.New(t.fake, config))
+
+	// 캐시를 거치지 않도록 fakekms 에 직접 키를 만든다
+	key, err := t.fake.CreateKey(context.Background(), &kms.CreateKeyInput{KeySpec: types.KeySpecEccSecgP256k1, KeyUsage: types.KeyUsageTypeSignVerify})
+	t.Require().NoError(err)
+	t.keyID = *key.KeyMetadata.KeyId
+}
+
+func (t *ReqCtxTestSuite) errCode(err error) int {
+	var cusErr *errs.CusErr
+	t.Require().True(errors.As(err, &cusErr), "%v", err)
+	return cusErr.Code
+}
+
+func (t *ReqCtxTestSuite) Test_DeadlineExceeded() {
+	t.fake.SetLatency("GetPublicKey", 50*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := t.kmsSrv.GetAccount(ctx, &dto.KeyIdReq{KeyID: t.keyID})
+	t.Equal(errs.Errs["RequestTimeoutErr"].Code, t.errCode(err))
+}
+
+func (t *ReqCtxTestSuite) Test_Canceled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := t.kmsSrv.GetAccount(ctx, &dto.KeyIdReq{KeyID: t.keyID})
+	t.Equal(errs.Errs["RequestCanceledErr"].Code, t.errCode(err))
+	_, _, err = t.kmsSrv.Sign(ctx, t.keyID, make([]byte, 32))
+	t.Equal(errs.Errs["RequestCanceledErr"].Code, t.errCode(err))
+}
+
+func (t *ReqCtxTestSuite) Test_KmsTimeout() {
+	// 요청 제한시간이 아닌 kms 호출 제한시간을 넘긴 경우는 kms 장애로 응답한다
+	t.fake.SetLatency("GetPublicKey", 200*time.Millisecond)
+
+	_, err := t.kmsSrv.GetAccount(context.Background(), &dto.KeyIdReq{KeyID: t.keyID})
+	t.Equal(errs.Errs["KmsUnavailableErr"].Code, t.errCode(err))
+}
+
+func (t *ReqCtxTestSuite) Test_DeadlineMiddleware() {
+	t.fake.SetLatency("GetPublicKey", 50*time.Millisecond)
+
+	app := fiber.New(fiber.Config{ErrorHandler: server.ErrHandler})
+	app.Use(server.Deadline(server.DeadlineConfig{
+		Timeout: time.Second,
+		Routes:  map[string]time.Duration{"/api/slow": 10 * time.Millisecond},
+	}))
+	handler := func(c *fiber.Ctx) error {
+		accountRes, err := t.kmsSrv.GetAccount(c.UserContext(), &dto.KeyIdReq{KeyID: t.keyID})
+		if err != nil {
+			return err
+		}
+		return c.JSON(accountRes)
+	}
+	app.Get("/api/account", handler)
+	app.Get("/api/slow/account", handler)
+
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/api/slow/account", nil), -1)
+	t.Require().NoError(err)
+	t.Equal(errs.KindRequestTimeout.Status, res.StatusCode)
+
+	res, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/api/account", nil), -1)
+	t.Require().NoError(err)
+	t.Equal(fiber.StatusOK, res.StatusCode)
+}
+
+func TestReqCtxTestSuite(t *testing.T) {
+	suite.Run(t, new(ReqCtxTestSuite))
+}
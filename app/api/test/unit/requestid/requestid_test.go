@@ -0,0 +1,155 @@
+package requestid_test
+
+// X-Request-ID 가 응답 헤더, 에러 응답, 로그, kms user-agent 에 전달되는지 확인하는 테스트
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"kms/wallet/app/api/model/dto"
+	"kms/wallet/app/kmsclient"
+	"kms/wallet/app/server"
+	"kms/wallet/common/config"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type RequestIDTestSuite struct {
+	suite.Suite
+	app       *fiber.App
+	logs      *syncBuffer
+	kmsServer *httptest.Server
+	userAgent chan string
+}
+
+type syncBuffer struct {
+	buf   bytes.Buffer
+	mutex sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func (t *RequestIDTestSuite) SetupSuite() {
+	logger.Init("test")
+	t.logs = &syncBuffer{}
+	logger.SetOutput(t.logs)
+
+	// kms 대신 user-agent 만 기록하는 서버
+	t.userAgent = make(chan string, 1)
+	t.kmsServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.userAgent <- r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte("{}"))
+	}))
+	kmsClient := kms.New(kms.Options{
+		Region:           "ap-northeast-2",
+		BaseEndpoint:     aws.String(t.kmsServer.URL),
+		Credentials:      credentials.NewStaticCredentialsProvider("test", "test", ""),
+		RetryMaxAttempts: 1,
+		APIOptions:       []func(*middleware.Stack) error{kmsclient.WithRequestID},
+	})
+
+	config.Env = config.Default()
+	config.Env.Environment = "test"
+	t.app = server.New().App
+	t.app.Get("/api/internal", func(c *fiber.Ctx) error {
+		return errs.InternalServerErr(fmt.Errorf("injected failure"))
+	})
+	t.app.Get("/api/panic", func(c *fiber.Ctx) error {
+		panic("injected panic")
+	})
+	t.app.Get("/api/kms", func(c *fiber.Ctx) error {
+		_, err := kmsClient.ListKeys(c.UserContext(), &kms.ListKeysInput{})
+		if err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+}
+
+func (t *RequestIDTestSuite) TearDownSuite() {
+	t.kmsServer.Close()
+	logger.Init("test")
+}
+
+func (t *RequestIDTestSuite) get(path, requestID string) (*http.Response, dto.ErrRes) {
+	req := httptest.NewRequest(fiber.MethodGet, path, nil)
+	if requestID != "" {
+		req.Header.Set(server.RequestIDHeader, requestID)
+	}
+	res, err := t.app.Test(req, -1)
+	t.Require().NoError(err)
+
+	var errRes dto.ErrRes
+	if res.StatusCode >= 400 {
+		t.Require().NoError(json.NewDecoder(res.Body).Decode(&errRes))
+	}
+	return res, errRes
+}
+
+func (t *RequestIDTestSuite) Test_ErrorResponse() {
+	res, errRes := t.get("/api/internal", "req-error-1")
+	t.Equal(fiber.StatusInternalServerError, res.StatusCode)
+	t.Equal("req-error-1", res.Header.Get(server.RequestIDHeader))
+	t.Equal("req-error-1", errRes.RequestID)
+	t.Contains(t.logs.String(), `"requestID":"req-error-1"`)
+}
+
+func (t *RequestIDTestSuite) Test_Generated() {
+	// 없거나 형식에 맞지 않으면 새로 만든다
+	for _, requestID := range []string{"", "bad id\r\n", strings.Repeat("a", 200)} {
+		res, errRes := t.get("/api/notfound", requestID)
+		generated := res.Header.Get(server.RequestIDHeader)
+		_, err := uuid.Parse(generated)
+		t.NoError(err, "%q", requestID)
+		t.Equal(generated, errRes.RequestID)
+	}
+}
+
+func (t *RequestIDTestSuite) Test_Panic() {
+	res, errRes := t.get("/api/panic", "req-panic-1")
+	t.Equal(fiber.StatusInternalServerError, res.StatusCode)
+	t.Equal("req-panic-1", errRes.RequestID)
+
+	// recover 에서 남긴 panic 로그에도 포함된다
+	var found bool
+	for _, line := range strings.Split(t.logs.String(), "\n") {
+		if strings.Contains(line, `"level":"panic"`) && strings.Contains(line, `"requestID":"req-panic-1"`) {
+			found = true
+		}
+	}
+	t.True(found, t.logs.String())
+}
+
+func (t *RequestIDTestSuite) Test_KmsUserAgent() {
+	res, _ := t.get("/api/kms", "req-kms-1")
+	t.Equal(fiber.StatusOK, res.StatusCode)
+	t.Contains(<-t.userAgent, "md/request-id#req-kms-1")
+}
+
+func TestRequestIDTestSuite(t *testing.T) {
+	suite.Run(t, new(RequestIDTestSuite))
+}
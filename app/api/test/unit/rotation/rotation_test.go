@@ -0,0 +1,216 @@
+package rotation_test
+
+// fakekms 와 SimulatedBackend(testnet) 위에서 키 로테이션이 자산을 새 주소로 옮기고 기존 키를 retired 처리하는지 확인하는 테스트
+
+import (
+	"context"
+	"errors"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/erc20"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/app/api/test/common/testnet"
+	"kms/wallet/app/store"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"kms/wallet/common/utils/ethutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/suite"
+)
+
+type RotationTestSuite struct {
+	suite.Suite
+	fake        *fakekms.FakeKms
+	testNet     *testnet.TestNet
+	erc20       *erc20.ERC20
+	kmsSrv      *srv.KmsSrv
+	rotationSrv *srv.RotationSrv
+	storePath   string
+	freezePath  string
+}
+
+func (t *RotationTestSuite) SetupSuite() {
+	logger.Init("test")
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *RotationTestSuite) SetupTest() {
+	var err error
+	t.fake = fakekms.New()
+	t.testNet = testnet.NewTestNet()
+	t.erc20, err = erc20.NewERC20WithDeploy(t.testNet.Accounts[0].PK, t.testNet.Client)
+	t.Require().NoError(err)
+
+	t.storePath = filepath.Join(t.T().TempDir(), "rotation.json")
+	t.freezePath = filepath.Join(t.T().TempDir(), "freeze.json")
+	t.kmsSrv = t.newKmsSrv(t.freezePath)
+	t.rotationSrv = t.newRotationSrv()
+}
+
+// 동결 기록 파일(freezePath)을 쓰는 새 KmsSrv. 비어있으면 동결 기록을 메모리에만 둔다
+func (t *RotationTestSuite) newKmsSrv(freezePath string) *srv.KmsSrv {
+	freezeStore, err := store.NewFreezeStore(freezePath)
+	t.Require().NoError(err)
+	return srv.NewKmsSrv(t.fake, srv.WithFreezeStore(freezeStore))
+}
+
+func (t *RotationTestSuite) requireRetired(keyID string) {
+	_, _, err := t.kmsSrv.Sign(context.Background(), keyID, make([]byte, 32))
+	var cusErr *errs.CusErr
+	t.Require().True(errors.As(err, &cusErr), "%v", err)
+	t.Equal(errs.Errs["FrozenKeyErr"].Code, cusErr.Code)
+}
+
+// 같은 저장소 파일로 새 서비스를 만든다 (재시작 시뮬레이션)
+func (t *RotationTestSuite) newRotationSrv() *srv.RotationSrv {
+	rotationStore, err := store.NewRotationStore(t.storePath)
+	t.Require().NoError(err)
+	txnSrv := srv.NewTxnSrv(t.testNet.ChainInfo.ChainID, t.kmsSrv)
+	return srv.NewRotationSrv(t.kmsSrv, txnSrv, t.testNet.Client, []common.Address{t.erc20.CA}, rotationStore)
+}
+
+func (t *RotationTestSuite) fundedAccount() *dto.AccountRes {
+	accountRes, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	t.Require().NoError(t.testNet.Faucet(common.HexToAddress(accountRes.Address), "10"))
+	t.Require().NoError(t.erc20.Faucet(common.HexToAddress(accountRes.Address), "20"))
+	return accountRes
+}
+
+func (t *RotationTestSuite) requireSwept(rotationRes *dto.RotationRes) {
+	t.testNet.Client.Commit()
+	oldAddr, newAddr := common.HexToAddress(rotationRes.OldAddress), common.HexToAddress(rotationRes.NewAddress)
+
+	tokenAmount, _ := ethutil.ParseUnit("20", 18)
+	newTokenBal, err := t.erc20.BalanceOf(newAddr, nil)
+	t.NoError(err)
+	t.Equal(0, tokenAmount.Cmp(newTokenBal), "new address should hold %v tokens but %v", tokenAmount, newTokenBal)
+	oldTokenBal, err := t.erc20.BalanceOf(oldAddr, nil)
+	t.NoError(err)
+	t.Zero(oldTokenBal.Sign())
+
+	newBal, err := t.testNet.Client.BalanceAt(context.Background(), newAddr, nil)
+	t.NoError(err)
+	t.Positive(newBal.Sign())
+	oldBal, err := t.testNet.Client.BalanceAt(context.Background(), oldAddr, nil)
+	t.NoError(err)
+	maxDust := new(big.Int).Mul(t.testNet.ChainInfo.GasPrice, big.NewInt(21000))
+	t.LessOrEqual(oldBal.Cmp(maxDust), 0, "old address should be emptied but holds %v", oldBal)
+}
+
+func (t *RotationTestSuite) Test_Rotate() {
+	oldAccount := t.fundedAccount()
+	t.NoError(t.fake.TagResourceForTest(oldAccount.KeyID, "customer", "cohort-7"))
+
+	rotationRes, err := t.rotationSrv.Rotate(context.Background(), &dto.KeyIdReq{KeyID: oldAccount.KeyID}, &dto.RotateReq{Actor: "tester"})
+	t.Require().NoError(err)
+	t.Equal(store.RotationStepRetired, rotationRes.Step)
+	t.Len(rotationRes.Sweeps, 2)
+	t.requireSwept(rotationRes)
+
+	// tag 복사 및 기존 키 retired 처리
+	t.Equal("cohort-7", t.fake.Tags(rotationRes.NewKeyID)["customer"])
+	t.Equal(oldAccount.KeyID, t.fake.Tags(rotationRes.NewKeyID)["RotatedFrom"])
+	t.Equal("true", t.fake.Tags(oldAccount.KeyID)["Retired"])
+
+	accountRes, err := t.kmsSrv.GetAccount(context.Background(), &dto.KeyIdReq{KeyID: oldAccount.KeyID})
+	t.NoError(err)
+	t.True(accountRes.Frozen)
+	_, _, err = t.kmsSrv.Sign(context.Background(), oldAccount.KeyID, make([]byte, 32))
+	t.Error(err)
+}
+
+// native 스윕 서명이 실패한 뒤 재시작된 서비스에서 다시 호출하면 토큰을 중복으로 옮기지 않고 이어서 진행한다
+func (t *RotationTestSuite) Test_ResumeAfterFailure() {
+	oldAccount := t.fundedAccount()
+	keyIdReq := &dto.KeyIdReq{KeyID: oldAccount.KeyID}
+
+	t.fake.FailNextAfter("Sign", 1, &types.KMSInternalException{Message: aws.String("injected failure")})
+	_, err := t.rotationSrv.Rotate(context.Background(), keyIdReq, &dto.RotateReq{Actor: "tester"})
+	t.Require().Error(err)
+
+	rotationRes, err := t.rotationSrv.GetRotation(keyIdReq)
+	t.NoError(err)
+	t.Equal(store.RotationStepCreated, rotationRes.Step)
+	t.NotEmpty(rotationRes.LastError)
+	t.Len(rotationRes.Sweeps, 1)
+	newKeyID := rotationRes.NewKeyID
+
+	rotationRes, err = t.newRotationSrv().Rotate(context.Background(), keyIdReq, &dto.RotateReq{Actor: "tester"})
+	t.Require().NoError(err)
+	t.Equal(store.RotationStepRetired, rotationRes.Step)
+	t.Equal(newKeyID, rotationRes.NewKeyID)
+	t.Len(rotationRes.Sweeps, 2)
+	t.Empty(rotationRes.LastError)
+	t.requireSwept(rotationRes)
+}
+
+// 재시작한 서비스에서도 retired 된 기존 키로는 서명할 수 없다
+func (t *RotationTestSuite) Test_RetiredAfterRestart() {
+	oldAccount := t.fundedAccount()
+	keyIdReq := &dto.KeyIdReq{KeyID: oldAccount.KeyID}
+	rotationRes, err := t.rotationSrv.Rotate(context.Background(), keyIdReq, &dto.RotateReq{Actor: "tester"})
+	t.Require().NoError(err)
+	t.Equal(store.RotationStepRetired, rotationRes.Step)
+	// kms 키도 비활성화한다
+	t.Equal(types.KeyStateDisabled, t.fake.KeyState(oldAccount.KeyID))
+
+	// 동결 기록 파일이 남아있는 경우
+	t.kmsSrv = t.newKmsSrv(t.freezePath)
+	t.rotationSrv = t.newRotationSrv()
+	t.requireRetired(oldAccount.KeyID)
+	accountRes, err := t.kmsSrv.GetAccount(context.Background(), keyIdReq)
+	t.Require().NoError(err)
+	t.True(accountRes.Frozen)
+	t.Require().NotNil(accountRes.FreezeInfo)
+	t.Equal("rotated to "+rotationRes.NewKeyID, accountRes.FreezeInfo.Reason)
+
+	// 동결 기록이 사라졌더라도 시작할 때 로테이션 기록으로 다시 차단한다
+	t.kmsSrv = t.newKmsSrv("")
+	t.rotationSrv = t.newRotationSrv()
+	t.Require().NoError(t.rotationSrv.ReapplyRetirements(context.Background()))
+	t.requireRetired(oldAccount.KeyID)
+
+	// 끝난 로테이션을 다시 호출해도 다시 차단한다
+	t.kmsSrv = t.newKmsSrv("")
+	t.rotationSrv = t.newRotationSrv()
+	rotationRes, err = t.rotationSrv.Rotate(context.Background(), keyIdReq, &dto.RotateReq{Actor: "tester"})
+	t.Require().NoError(err)
+	t.Equal(store.RotationStepRetired, rotationRes.Step)
+	t.requireRetired(oldAccount.KeyID)
+}
+
+// retired 된 키는 ARN 으로 가리켜도 서명할 수 없다
+func (t *RotationTestSuite) Test_RetiredSignByARN() {
+	oldAccount := t.fundedAccount()
+	keyIdReq := &dto.KeyIdReq{KeyID: oldAccount.KeyID}
+	_, err := t.rotationSrv.Rotate(context.Background(), keyIdReq, &dto.RotateReq{Actor: "tester"})
+	t.Require().NoError(err)
+	keyInfo, err := t.fake.DescribeKey(context.Background(), &kms.DescribeKeyInput{KeyId: aws.String(oldAccount.KeyID)})
+	t.Require().NoError(err)
+	arn := aws.ToString(keyInfo.KeyMetadata.Arn)
+
+	// kms 에서 키를 다시 활성화하더라도 동결 기록이 서명을 막는다
+	_, err = t.fake.EnableKey(context.Background(), &kms.EnableKeyInput{KeyId: aws.String(oldAccount.KeyID)})
+	t.Require().NoError(err)
+	t.requireRetired(arn)
+
+	// 비활성화된 키도 address 는 조회할 수 있다
+	_, err = t.fake.DisableKey(context.Background(), &kms.DisableKeyInput{KeyId: aws.String(oldAccount.KeyID)})
+	t.Require().NoError(err)
+	accountRes, err := t.newKmsSrv(t.freezePath).GetAccount(context.Background(), &dto.KeyIdReq{KeyID: arn})
+	t.Require().NoError(err)
+	t.True(accountRes.Frozen)
+	t.Equal(oldAccount.Address, accountRes.Address)
+}
+
+func Test(t *testing.T) {
+	suite.Run(t, new(RotationTestSuite))
+}
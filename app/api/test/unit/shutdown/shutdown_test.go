@@ -0,0 +1,174 @@
+package shutdown_test
+
+// SIGTERM 을 받았을 때 처리중인 서명 요청이 끝난 뒤 종료 작업이 실행되는지 확인하는 테스트
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	ctrl "kms/wallet/app/api/controller"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/app/server"
+	"kms/wallet/app/store"
+	"kms/wallet/common/config"
+	"kms/wallet/common/logger"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/suite"
+)
+
+type ShutdownTestSuite struct {
+	suite.Suite
+	fake   *fakekms.FakeKms
+	kmsSrv *srv.KmsSrv
+	txnSrv *srv.TxnSrv
+	keyID  string
+}
+
+func (t *ShutdownTestSuite) SetupSuite() {
+	logger.Init("test")
+	dto.Init()
+}
+
+func (t *ShutdownTestSuite) SetupTest() {
+	t.fake = fakekms.New()
+	t.kmsSrv = srv.NewKmsSrv(t.fake)
+	t.txnSrv = srv.NewTxnSrv(big.NewInt(1), t.kmsSrv)
+	accountRes, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	t.keyID = accountRes.KeyID
+}
+
+// 서버를 실행하고 Serve 의 결과를 리턴하는 채널과 주소를 리턴
+func (t *ShutdownTestSuite) serve(shutdownTimeout time.Duration, hooks *[]string) (<-chan error, string) {
+	config.Env = config.Default()
+	config.Env.Environment = "test"
+	config.Env.Server.ShutdownTimeout = shutdownTimeout
+	s := server.New()
+	ctrl.NewTxnCtrl(t.txnSrv).BootStrap(s.App.Group("/api"))
+
+	jobStore := store.NewMemoryJobStore()
+	jobSrv := srv.NewJobSrv(t.txnSrv, jobStore, srv.JobSrvConfig{})
+	t.Require().NoError(jobSrv.Start())
+	var mutex sync.Mutex
+	record := func(name string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		*hooks = append(*hooks, name)
+	}
+	s.OnShutdown("jobs", func(ctx context.Context) error {
+		record("jobs")
+		return jobSrv.Shutdown(ctx)
+	})
+	s.OnShutdown("job store", func(context.Context) error {
+		record("job store")
+		return jobStore.Close()
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	t.Require().NoError(err)
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ln) }()
+	t.Eventually(func() bool {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	return served, "http://" + ln.Addr().String()
+}
+
+func (t *ShutdownTestSuite) signRequest(addr string) <-chan *http.Response {
+	body, _ := json.Marshal(dto.TxnReq{KeyID: t.keyID, SerializedTxn: serializedTxn(t)})
+	responses := make(chan *http.Response, 1)
+	go func() {
+		res, err := http.Post(addr+"/api/sign/txn", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.T().Log(err)
+			res = nil
+		}
+		responses <- res
+	}()
+
+	// 서명 요청이 kms 에 도착할 때까지 기다린다
+	t.Eventually(func() bool { return t.fake.Calls("Sign") > 0 }, time.Second, 5*time.Millisecond)
+	return responses
+}
+
+func (t *ShutdownTestSuite) Test_DrainInFlightSign() {
+	var hooks []string
+	t.fake.SetLatency("Sign", 300*time.Millisecond)
+	served, addr := t.serve(5*time.Second, &hooks)
+
+	responses := t.signRequest(addr)
+	t.Require().NoError(syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+	res := <-responses
+	t.Require().NotNil(res, "in-flight request must complete")
+	t.Equal(http.StatusCreated, res.StatusCode)
+	var signedTxnRes dto.SingedTxnRes
+	t.Require().NoError(json.NewDecoder(res.Body).Decode(&signedTxnRes))
+	t.NotEmpty(signedTxnRes.SignedTxn)
+
+	select {
+	case err := <-served:
+		t.NoError(err)
+	case <-time.After(3 * time.Second):
+		t.Fail("server did not shut down")
+	}
+	t.Equal([]string{"jobs", "job store"}, hooks)
+
+	// 종료 이후에는 새 요청을 받지 않는다
+	_, err := http.Get(addr + "/api/sign/txn")
+	t.Error(err)
+}
+
+func (t *ShutdownTestSuite) Test_Timeout() {
+	var hooks []string
+	t.fake.SetLatency("Sign", time.Second)
+	served, addr := t.serve(100*time.Millisecond, &hooks)
+
+	t.signRequest(addr)
+	start := time.Now()
+	t.Require().NoError(syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+	select {
+	case err := <-served:
+		t.True(errors.Is(err, server.ErrShutdownTimeout), err)
+		t.Less(time.Since(start), 900*time.Millisecond)
+	case <-time.After(3 * time.Second):
+		t.Fail("server did not shut down")
+	}
+	// 제한시간이 지나도 종료 작업은 실행된다
+	t.Equal([]string{"jobs", "job store"}, hooks)
+}
+
+func serializedTxn(t *ShutdownTestSuite) string {
+	txn := ethTypes.NewTx(&ethTypes.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(1),
+		Gas:       21000,
+		To:        &common.Address{},
+		Value:     big.NewInt(0),
+	})
+	data, err := txn.MarshalBinary()
+	t.Require().NoError(err)
+	return "0x" + common.Bytes2Hex(data)
+}
+
+func TestShutdownTestSuite(t *testing.T) {
+	suite.Run(t, new(ShutdownTestSuite))
+}
@@ -0,0 +1,208 @@
+package tracing_test
+
+// http 요청, 서비스, kms 호출이 하나의 trace 로 이어지는지 in-memory exporter 로 확인하는 테스트
+
+import (
+	"context"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/app/server"
+	"kms/wallet/app/tracing"
+	"kms/wallet/common/config"
+	"kms/wallet/common/logger"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	traceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentSpanID = "00f067aa0ba902b7"
+)
+
+type TracingTestSuite struct {
+	suite.Suite
+	exporter *tracetest.InMemoryExporter
+	app      *fiber.App
+	kmsSrv   *srv.KmsSrv
+	keyID    string
+}
+
+func (t *TracingTestSuite) SetupSuite() {
+	logger.Init("test")
+	_, err := tracing.Init(context.Background(), tracing.Config{})
+	t.Require().NoError(err)
+	t.exporter = tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(t.exporter)))
+
+	t.kmsSrv = srv.NewKmsSrv(fakekms.New())
+	txnSrv := srv.NewTxnSrv(big.NewInt(1), t.kmsSrv)
+	accountRes, err := t.kmsSrv.CreateAccount(context.Background())
+	t.Require().NoError(err)
+	t.keyID = accountRes.KeyID
+
+	config.Env = config.Default()
+	config.Env.Environment = "test"
+	t.app = server.New().App
+	t.app.Post("/api/txn/:keyID", func(c *fiber.Ctx) error {
+		signedTxnRes, err := txnSrv.SignSerializedTxn(c.UserContext(), &dto.TxnReq{KeyID: c.Params("keyID"), SerializedTxn: string(c.Body())})
+		if err != nil {
+			return err
+		}
+		return c.JSON(signedTxnRes)
+	})
+}
+
+func (t *TracingTestSuite) SetupTest() {
+	t.exporter.Reset()
+}
+
+func (t *TracingTestSuite) spans() map[string]tracetest.SpanStub {
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range t.exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func attr(span tracetest.SpanStub, key attribute.Key) string {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func (t *TracingTestSuite) post(path, body string) *http.Response {
+	req := httptest.NewRequest(fiber.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentSpanID+"-01")
+	res, err := t.app.Test(req, -1)
+	t.Require().NoError(err)
+	return res
+}
+
+func (t *TracingTestSuite) Test_SignTxn() {
+	res := t.post("/api/txn/"+t.keyID, serializedTxn(t))
+	t.Equal(fiber.StatusOK, res.StatusCode)
+
+	spans := t.spans()
+	serverSpan, ok := spans["POST /api/txn/:keyID"]
+	t.Require().True(ok, "server span")
+	t.Equal(trace.SpanKindServer, serverSpan.SpanKind)
+	t.Equal(traceID, serverSpan.SpanContext.TraceID().String())
+	t.Equal(parentSpanID, serverSpan.Parent.SpanID().String())
+	t.Equal("/api/txn/:keyID", attr(serverSpan, "http.route"))
+	t.Equal("200", attr(serverSpan, "http.response.status_code"))
+
+	txnSpan, ok := spans["TxnSrv.SignSerializedTxn"]
+	t.Require().True(ok, "txn span")
+	t.Equal(serverSpan.SpanContext.SpanID(), txnSpan.Parent.SpanID())
+	t.Equal("dynamic_fee", attr(txnSpan, "wallet.tx.type"))
+	t.Equal("1", attr(txnSpan, "wallet.chain_id"))
+	keyHash := attr(txnSpan, "wallet.key_id_hash")
+	t.Len(keyHash, 16)
+
+	signSpan, ok := spans["KmsSrv.Sign"]
+	t.Require().True(ok, "sign span")
+	t.Equal(traceID, signSpan.SpanContext.TraceID().String())
+	t.Equal(keyHash, attr(signSpan, "wallet.key_id_hash"))
+	t.Contains(spans, "TxnSrv.signTxn")
+	t.Contains(spans, "KmsSrv.GetPubkey")
+
+	// keyID 는 해시로만 남는다
+	for _, span := range spans {
+		for _, kv := range span.Attributes {
+			t.NotContains(kv.Value.Emit(), t.keyID, "%v %v", span.Name, kv.Key)
+		}
+	}
+}
+
+func (t *TracingTestSuite) Test_Error() {
+	res := t.post("/api/txn/"+t.keyID, "0xzz")
+	t.Equal(fiber.StatusUnprocessableEntity, res.StatusCode)
+
+	spans := t.spans()
+	t.Equal("422", attr(spans["POST /api/txn/:keyID"], "http.response.status_code"))
+	txnSpan := spans["TxnSrv.SignSerializedTxn"]
+	t.Equal(codes.Error, txnSpan.Status.Code)
+	t.Len(txnSpan.Events, 1) // RecordError
+}
+
+func (t *TracingTestSuite) Test_KmsClient() {
+	kmsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if strings.HasSuffix(r.Header.Get("X-Amz-Target"), ".DescribeKey") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"NotFoundException","message":"not found"}`))
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer kmsServer.Close()
+	kmsClient := kms.New(kms.Options{
+		Region:           "ap-northeast-2",
+		BaseEndpoint:     aws.String(kmsServer.URL),
+		Credentials:      credentials.NewStaticCredentialsProvider("test", "test", ""),
+		RetryMaxAttempts: 1,
+		APIOptions:       []func(*middleware.Stack) error{tracing.WithKMS},
+	})
+
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	_, err := kmsClient.ListKeys(ctx, &kms.ListKeysInput{})
+	t.Require().NoError(err)
+	_, err = kmsClient.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(t.keyID)})
+	t.Error(err)
+	parent.End()
+
+	spans := t.spans()
+	listSpan, ok := spans["KMS.ListKeys"]
+	t.Require().True(ok, "ListKeys span")
+	t.Equal(trace.SpanKindClient, listSpan.SpanKind)
+	t.Equal(parent.SpanContext().SpanID(), listSpan.Parent.SpanID())
+	t.Equal("aws-api", attr(listSpan, "rpc.system"))
+	t.Equal("KMS", attr(listSpan, "rpc.service"))
+	t.Equal("ListKeys", attr(listSpan, "rpc.method"))
+	t.Equal("ap-northeast-2", attr(listSpan, "aws.region"))
+	t.Equal(codes.Unset, listSpan.Status.Code)
+
+	describeSpan, ok := spans["KMS.DescribeKey"]
+	t.Require().True(ok, "DescribeKey span")
+	t.Equal(codes.Error, describeSpan.Status.Code)
+}
+
+func serializedTxn(t *TracingTestSuite) string {
+	txn := ethTypes.NewTx(&ethTypes.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(1),
+		Gas:       21000,
+		To:        &common.Address{},
+		Value:     big.NewInt(0),
+	})
+	data, err := txn.MarshalBinary()
+	t.Require().NoError(err)
+	return "0x" + common.Bytes2Hex(data)
+}
+
+func TestTracingTestSuite(t *testing.T) {
+	suite.Run(t, new(TracingTestSuite))
+}
@@ -12,9 +12,7 @@ import (
 	"kms/wallet/app/api/test/common/erc20"
 	"kms/wallet/app/api/test/common/http"
 	"kms/wallet/app/api/test/common/testnet"
-
-	awscfg "github.com/aws/aws-sdk-go-v2/config"
-
+	"kms/wallet/app/kmsclient"
 	"kms/wallet/app/server"
 	"kms/wallet/common/config"
 	"kms/wallet/common/logger"
@@ -25,7 +23,6 @@ import (
 	"golang.org/x/crypto/sha3"
 	"golang.org/x/exp/slices"
 
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/ethereum/go-ethereum"
@@ -60,32 +57,31 @@ func (t *TxnTestSuite) BeforeTest(suiteName, testName string) {
 func (t *TxnTestSuite) SetupSuite() {
 	flag.Parse()
 
-	config.Init("../../../../../env/.env." + *curEnv)
-	config.Env.Log = *log
+	cfg, _, err := config.Load([]string{"--env", *curEnv, "--env-file", "../../../../../env/.env." + *curEnv})
+	t.Require().NoError(err)
+	cfg.Logging.Requests = *log
+	config.Env = cfg
 	dto.Init()
 	logger.Init(*curEnv)
 
-	creds := credentials.NewStaticCredentialsProvider(config.Env.AWS_ACCESS_KEY, config.Env.AWS_SECRET_KEY, "")
-	awsCfg, err := awscfg.LoadDefaultConfig(
-		context.Background(),
-		awscfg.WithCredentialsProvider(creds),
-		awscfg.WithRegion(config.Env.AWS_REGION),
-	)
+	awsCfg, err := kmsclient.LoadAwsConfig(context.Background(), kmsclient.AwsConfig{
+		Region:        cfg.Kms.Region,
+		AccessKey:     cfg.Kms.AccessKey,
+		SecretKey:     cfg.Kms.SecretKey,
+		AssumeRoleARN: cfg.Kms.AssumeRoleARN,
+		ExternalID:    cfg.Kms.ExternalID,
+		SessionName:   cfg.Kms.SessionName,
+		StsEndpoint:   cfg.Kms.StsEndpoint,
+	})
 	t.NoError(err)
 
-	var kmsClient *kms.Client
-	if config.Env.ENV == "local" {
-		kmsClient = kms.NewFromConfig(awsCfg, func(o *kms.Options) {
-			o.BaseEndpoint = aws.String("http://localhost:8080")
-		})
-	} else {
-		kmsClient = kms.NewFromConfig(awsCfg)
-	}
+	kmsClient := kms.NewFromConfig(awsCfg, func(o *kms.Options) {
+		if cfg.Kms.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Kms.Endpoint)
+		}
+	})
 
-	chainID, ok := new(big.Int).SetString(config.Env.CHAIN_ID, 10)
-	if !ok {
-		t.Fail("invalid chain id")
-	}
+	chainID := new(big.Int).SetUint64(cfg.Chains.ChainID)
 
 	server := server.New()
 	kmsSrv := srv.NewKmsSrv(kmsClient)
@@ -0,0 +1,127 @@
+package txnbatch_test
+
+// 여러 트렌젝션을 한번에 서명하는 api 의 항목별 결과와 allOrNothing 모드를 확인하는 테스트 (fakekms 사용)
+
+import (
+	"context"
+	"kms/wallet/app/api/model/dto"
+	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/api/test/common/fakekms"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/suite"
+)
+
+type TxnBatchTestSuite struct {
+	suite.Suite
+	fake     *fakekms.FakeKms
+	txnSrv   *srv.TxnSrv
+	chainID  *big.Int
+	accounts []*dto.AccountRes
+}
+
+func (t *TxnBatchTestSuite) SetupSuite() {
+	logger.Init("test")
+}
+
+// 각 테스트 실행전에 실행됨
+func (t *TxnBatchTestSuite) SetupTest() {
+	t.fake = fakekms.New()
+	t.chainID = big.NewInt(1337)
+
+	kmsSrv := srv.NewKmsSrv(t.fake)
+	t.accounts = nil
+	for i := 0; i < 2; i++ {
+		accountRes, err := kmsSrv.CreateAccount(context.Background())
+		t.Require().NoError(err)
+		t.accounts = append(t.accounts, accountRes)
+	}
+	// public key 캐시가 비어있는 상태에서 시작한다
+	t.txnSrv = srv.NewTxnSrv(t.chainID, srv.NewKmsSrv(t.fake))
+}
+
+func (t *TxnBatchTestSuite) serializedTxn(nonce uint64) string {
+	to := common.HexToAddress("0x39e243a7f209932df41e1fc0a1ada51b3a04b46d")
+	rawTxn, err := types.NewTx(&types.LegacyTx{Nonce: nonce, GasPrice: big.NewInt(1e9), Gas: 21000, To: &to, Value: big.NewInt(1)}).MarshalBinary()
+	t.Require().NoError(err)
+	return "0x" + common.Bytes2Hex(rawTxn)
+}
+
+// 서명된 트렌젝션의 발신자가 keyID 의 주소인지 확인
+func (t *TxnBatchTestSuite) requireSignedBy(signedTxn string, address string) {
+	var txn types.Transaction
+	t.Require().NoError(txn.UnmarshalBinary(common.FromHex(signedTxn)))
+	sender, err := types.Sender(types.NewCancunSigner(t.chainID), &txn)
+	t.Require().NoError(err)
+	t.Equal(address, sender.String())
+}
+
+func (t *TxnBatchTestSuite) Test_PartialFailure() {
+	pubKeyCalls := t.fake.Calls("GetPublicKey")
+	batchRes := t.txnSrv.SignSerializedTxnBatch(context.Background(), &dto.TxnBatchReq{Txns: []dto.TxnReq{
+		{KeyID: t.accounts[0].KeyID, SerializedTxn: t.serializedTxn(0)},
+		{KeyID: t.accounts[1].KeyID, SerializedTxn: "0xzz"},
+		{KeyID: t.accounts[0].KeyID, SerializedTxn: t.serializedTxn(1)},
+		{KeyID: t.accounts[1].KeyID, SerializedTxn: "0x01"},
+		{KeyID: "f50a9229-e7c7-45ba-b06c-8036b894424e", SerializedTxn: t.serializedTxn(0)},
+		{KeyID: t.accounts[1].KeyID, SerializedTxn: t.serializedTxn(0)},
+	}})
+
+	t.False(batchRes.Aborted)
+	t.Equal(3, batchRes.Succeeded)
+	t.Equal(3, batchRes.Failed)
+	t.Require().Len(batchRes.Results, 6)
+	for i, result := range batchRes.Results {
+		t.Equal(i, result.Index)
+	}
+
+	t.requireSignedBy(batchRes.Results[0].SignedTxn, t.accounts[0].Address)
+	t.requireSignedBy(batchRes.Results[2].SignedTxn, t.accounts[0].Address)
+	t.requireSignedBy(batchRes.Results[5].SignedTxn, t.accounts[1].Address)
+	t.Equal(errs.KindBadRequest.Code, batchRes.Results[1].Error.Code)
+	t.Equal(errs.KindTxnInvalid.Code, batchRes.Results[3].Error.Code)
+	t.Equal(errs.KindKeyNotFound.Code, batchRes.Results[4].Error.Code)
+
+	// keyID 별로 public key 는 한번씩만 조회한다
+	t.Equal(3, t.fake.Calls("GetPublicKey")-pubKeyCalls)
+}
+
+func (t *TxnBatchTestSuite) Test_AllOrNothing() {
+	signCalls := t.fake.Calls("Sign")
+	batchRes := t.txnSrv.SignSerializedTxnBatch(context.Background(), &dto.TxnBatchReq{
+		AllOrNothing: true,
+		Txns: []dto.TxnReq{
+			{KeyID: t.accounts[0].KeyID, SerializedTxn: t.serializedTxn(0)},
+			{KeyID: t.accounts[1].KeyID, SerializedTxn: "0x01"},
+		},
+	})
+
+	t.True(batchRes.Aborted)
+	t.Zero(batchRes.Succeeded)
+	t.Equal(1, batchRes.Failed)
+	t.Empty(batchRes.Results[0].SignedTxn)
+	t.Nil(batchRes.Results[0].Error)
+	t.Equal(errs.KindTxnInvalid.Code, batchRes.Results[1].Error.Code)
+	// 검증에 실패하면 kms 서명을 요청하지 않는다
+	t.Equal(signCalls, t.fake.Calls("Sign"))
+
+	batchRes = t.txnSrv.SignSerializedTxnBatch(context.Background(), &dto.TxnBatchReq{
+		AllOrNothing: true,
+		Txns: []dto.TxnReq{
+			{KeyID: t.accounts[0].KeyID, SerializedTxn: t.serializedTxn(0)},
+			{KeyID: t.accounts[1].KeyID, SerializedTxn: t.serializedTxn(0)},
+		},
+	})
+	t.False(batchRes.Aborted)
+	t.Equal(2, batchRes.Succeeded)
+	t.requireSignedBy(batchRes.Results[1].SignedTxn, t.accounts[1].Address)
+}
+
+func Test(t *testing.T) {
+	suite.Run(t, new(TxnBatchTestSuite))
+}
@@ -1,29 +1,231 @@
 package cache
 
 import (
+	"container/list"
 	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"kms/wallet/common/logger"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
+const defaultPubKeyCacheSize = 10000
+
+type PubKeyCacheConfig struct {
+	Size         int           // 최대 항목 수. 0 이면 defaultPubKeyCacheSize
+	TTL          time.Duration // 0 이면 만료되지 않는다
+	SnapshotPath string        // 비어있으면 디스크에 저장하지 않는다
+}
+
+type PubKeyCacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+type pubKeyEntry struct {
+	keyID   string
+	pubKey  *ecdsa.PublicKey
+	addedAt time.Time
+}
+
+type pubKeySnapshotEntry struct {
+	KeyID   string    `json:"keyID"`
+	PubKey  string    `json:"pubKey"` // uncompressed public key (hex)
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// 크기가 제한된 LRU public key 캐시. 설정하면 변경될 때마다 디스크에 스냅샷을 남기고 시작할 때 다시 읽어온다
 type PubKeyCache struct {
-	pubKeys map[string]*ecdsa.PublicKey
-	mutex   sync.RWMutex
+	config  PubKeyCacheConfig
+	entries map[string]*list.Element
+	lru     *list.List // front 가 가장 최근에 사용된 항목
+	mutex   sync.Mutex
+
+	writeMutex sync.Mutex
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
 }
 
-func NewPubKeyCache() *PubKeyCache {
-	return &PubKeyCache{
-		pubKeys: make(map[string]*ecdsa.PublicKey),
+func NewPubKeyCache(config PubKeyCacheConfig) (*PubKeyCache, error) {
+	if config.Size <= 0 {
+		config.Size = defaultPubKeyCacheSize
+	}
+	c := &PubKeyCache{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+
+	if config.SnapshotPath != "" {
+		if err := c.load(); err != nil {
+			return nil, err
+		}
 	}
+	return c, nil
 }
 
 func (c *PubKeyCache) Add(keyID string, pubKey *ecdsa.PublicKey) {
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	c.pubKeys[keyID] = pubKey
+	c.add(&pubKeyEntry{keyID: keyID, pubKey: pubKey, addedAt: time.Now()})
+	c.mutex.Unlock()
+
+	c.persist()
 }
 
+// 캐시에 없거나 만료되었으면 nil 을 리턴
 func (c *PubKeyCache) Get(keyID string) *ecdsa.PublicKey {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-	return c.pubKeys[keyID]
+	c.mutex.Lock()
+	elem, ok := c.entries[keyID]
+	if !ok {
+		c.mutex.Unlock()
+		c.misses.Add(1)
+		return nil
+	}
+
+	entry := elem.Value.(*pubKeyEntry)
+	if c.expired(entry) {
+		c.remove(elem)
+		c.mutex.Unlock()
+		c.misses.Add(1)
+		c.persist()
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	c.mutex.Unlock()
+
+	c.hits.Add(1)
+	return entry.pubKey
+}
+
+func (c *PubKeyCache) Remove(keyID string) {
+	c.mutex.Lock()
+	elem, ok := c.entries[keyID]
+	if ok {
+		c.remove(elem)
+	}
+	c.mutex.Unlock()
+
+	if ok {
+		c.persist()
+	}
+}
+
+func (c *PubKeyCache) Stats() PubKeyCacheStats {
+	c.mutex.Lock()
+	size := c.lru.Len()
+	c.mutex.Unlock()
+
+	return PubKeyCacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Size:      size,
+	}
+}
+
+// mutex 를 잡은 상태에서 호출해야 한다
+func (c *PubKeyCache) add(entry *pubKeyEntry) {
+	if elem, ok := c.entries[entry.keyID]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[entry.keyID] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.config.Size {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// mutex 를 잡은 상태에서 호출해야 한다
+func (c *PubKeyCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*pubKeyEntry).keyID)
+}
+
+func (c *PubKeyCache) expired(entry *pubKeyEntry) bool {
+	return c.config.TTL > 0 && time.Since(entry.addedAt) > c.config.TTL
+}
+
+func (c *PubKeyCache) load() error {
+	data, err := os.ReadFile(c.config.SnapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snapshot []pubKeySnapshotEntry
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// 스냅샷은 최근에 사용된 순서로 저장되어 있으므로 뒤에서부터 넣어 순서를 유지한다
+	for i := len(snapshot) - 1; i >= 0; i-- {
+		pubKeyBytes, err := hex.DecodeString(snapshot[i].PubKey)
+		if err != nil {
+			return err
+		}
+		pubKey, err := crypto.UnmarshalPubkey(pubKeyBytes)
+		if err != nil {
+			return err
+		}
+		entry := &pubKeyEntry{keyID: snapshot[i].KeyID, pubKey: pubKey, addedAt: snapshot[i].AddedAt}
+		if !c.expired(entry) {
+			c.add(entry)
+		}
+	}
+	return nil
+}
+
+// 스냅샷 저장에 실패해도 캐시 동작에는 영향이 없으므로 로그만 남긴다
+func (c *PubKeyCache) persist() {
+	if c.config.SnapshotPath == "" {
+		return
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	c.mutex.Lock()
+	snapshot := make([]pubKeySnapshotEntry, 0, c.lru.Len())
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*pubKeyEntry)
+		snapshot = append(snapshot, pubKeySnapshotEntry{
+			KeyID:   entry.keyID,
+			PubKey:  hex.EncodeToString(crypto.FromECDSAPub(entry.pubKey)),
+			AddedAt: entry.addedAt,
+		})
+	}
+	c.mutex.Unlock()
+
+	if err := writeSnapshot(c.config.SnapshotPath, snapshot); err != nil {
+		logger.Warn().E(err).D("path", c.config.SnapshotPath).W("failed to write pubkey cache snapshot")
+	}
+}
+
+func writeSnapshot(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
 }
@@ -0,0 +1,99 @@
+package client
+
+// 평문 private key 를 서버로 보내지 않고 kms 에 주입하기 위한 클라이언트 헬퍼
+// 1. GET /api/import/params 로 wrapping public key 와 import token 을 받고
+// 2. 클라이언트에서 RSA-OAEP 로 key material 을 암호화한 뒤
+// 3. POST /api/import/account/encrypted 로 암호문만 전송한다
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"kms/wallet/app/api/model/dto"
+	"kms/wallet/common/utils/keyutil"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type Importer struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// baseURL 예시: http://localhost:7777
+func NewImporter(baseURL string, httpClient *http.Client) *Importer {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Importer{strings.TrimSuffix(baseURL, "/"), httpClient}
+}
+
+func (i *Importer) Import(ecdsaPK *ecdsa.PrivateKey) (*dto.AccountRes, error) {
+	var importParamsRes dto.ImportParamsRes
+	if err := i.do(http.MethodGet, "/api/import/params", nil, http.StatusOK, &importParamsRes); err != nil {
+		return nil, err
+	}
+
+	encryptedReq, err := WrapForImport(ecdsaPK, &importParamsRes)
+	if err != nil {
+		return nil, err
+	}
+
+	var accountRes dto.AccountRes
+	if err := i.do(http.MethodPost, "/api/import/account/encrypted", encryptedReq, http.StatusCreated, &accountRes); err != nil {
+		return nil, err
+	}
+	return &accountRes, nil
+}
+
+// GET /api/import/params 의 응답으로 암호화된 주입 요청을 만든다
+func WrapForImport(ecdsaPK *ecdsa.PrivateKey, importParamsRes *dto.ImportParamsRes) (*dto.EncryptedImportReq, error) {
+	wrappingPubKey, err := base64.StdEncoding.DecodeString(importParamsRes.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedMaterial, err := keyutil.WrapKeyMaterial(ecdsaPK, wrappingPubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.EncryptedImportReq{
+		KeyID:                importParamsRes.KeyID,
+		ImportToken:          importParamsRes.ImportToken,
+		EncryptedKeyMaterial: base64.StdEncoding.EncodeToString(encryptedMaterial),
+		ExpectedAddress:      crypto.PubkeyToAddress(ecdsaPK.PublicKey).String(),
+	}, nil
+}
+
+func (i *Importer) do(method, path string, reqBody any, expectedStatus int, resBody any) error {
+	var body bytes.Buffer
+	if reqBody != nil {
+		if err := json.NewEncoder(&body).Encode(reqBody); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, i.baseURL+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := i.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != expectedStatus {
+		var errRes dto.ErrRes
+		json.NewDecoder(res.Body).Decode(&errRes)
+		return fmt.Errorf("%v %v failed with status %v %v: %v", method, path, res.StatusCode, errRes.Code, strings.Join(errRes.Message, ", "))
+	}
+	return json.NewDecoder(res.Body).Decode(resBody)
+}
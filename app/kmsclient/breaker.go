@@ -0,0 +1,96 @@
+package kmsclient
+
+import (
+	"kms/wallet/common/errs"
+	"sync"
+	"time"
+)
+
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+// 연속으로 실패한 횟수가 threshold 에 도달하면 cooldown 동안 호출을 바로 실패시킨다.
+// cooldown 이후에는 한번의 호출만 통과시켜(half-open) 성공하면 다시 닫는다
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+	lastErr  string
+	mutex    sync.Mutex
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, state: StateClosed}
+}
+
+func (b *breaker) allow() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return errs.ErrCircuitOpen
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			return errs.ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (b *breaker) success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.state = StateClosed
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *breaker) failure(err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failures++
+	b.lastErr = err.Error()
+	b.probing = false
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// 결과와 상관없이 half-open 상태의 시험 호출만 끝낸다
+func (b *breaker) release() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) health() Health {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	health := Health{State: b.state, ConsecutiveFailures: b.failures, LastError: b.lastErr}
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.cooldown {
+		// 다음 호출에서 half-open 으로 전환된다
+		health.State = StateHalfOpen
+	}
+	if b.state != StateClosed {
+		health.OpenedAt = b.openedAt.Format(time.RFC3339)
+	}
+	return health
+}
@@ -0,0 +1,69 @@
+package kmsclient
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awscfg "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+type AwsConfig struct {
+	Region string
+	// 둘 다 비어있으면 기본 credential chain (환경변수, 공유 설정 파일/SSO, IRSA, ECS/EC2 인스턴스 프로파일)을 사용한다
+	AccessKey string
+	SecretKey string
+	// 설정하면 위 자격증명으로 role 을 assume 해서 kms 를 호출한다. 임시 자격증명은 만료되기 전에 갱신된다
+	AssumeRoleARN string
+	ExternalID    string
+	SessionName   string
+	StsEndpoint   string // 비어있으면 region 의 sts
+}
+
+type CallerIdentity struct {
+	Account string
+	ARN     string
+}
+
+func LoadAwsConfig(ctx context.Context, config AwsConfig) (aws.Config, error) {
+	opts := []func(*awscfg.LoadOptions) error{awscfg.WithRegion(config.Region)}
+	if config.AccessKey != "" {
+		opts = append(opts, awscfg.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(config.AccessKey, config.SecretKey, ""),
+		))
+	}
+	awsCfg, err := awscfg.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	if config.AssumeRoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(newStsClient(awsCfg, config.StsEndpoint), config.AssumeRoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = config.SessionName
+			if config.ExternalID != "" {
+				o.ExternalID = aws.String(config.ExternalID)
+			}
+		})
+		awsCfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+	return awsCfg, nil
+}
+
+// 실제로 kms 를 호출하게 될 주체(assume 한 role 포함)를 확인한다
+func GetCallerIdentity(ctx context.Context, awsCfg aws.Config, stsEndpoint string) (CallerIdentity, error) {
+	identity, err := newStsClient(awsCfg, stsEndpoint).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return CallerIdentity{}, err
+	}
+	return CallerIdentity{Account: aws.ToString(identity.Account), ARN: aws.ToString(identity.Arn)}, nil
+}
+
+func newStsClient(awsCfg aws.Config, endpoint string) *sts.Client {
+	return sts.NewFromConfig(awsCfg, func(o *sts.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+}
@@ -0,0 +1,26 @@
+package kmsclient
+
+import (
+	"context"
+	"kms/wallet/common/logger"
+
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// 요청 context 의 request id 를 kms 호출의 user-agent 에 덧붙인다 (md/request-id#<id>).
+// cloudtrail 의 userAgent 로 kms 호출과 서버 로그를 연결할 수 있다.
+// kms.Options.APIOptions 에 추가해서 사용한다
+func WithRequestID(stack *middleware.Stack) error {
+	return stack.Build.Add(middleware.BuildMiddlewareFunc("RequestIDUserAgent", func(
+		ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler,
+	) (middleware.BuildOutput, middleware.Metadata, error) {
+		if requestID := logger.RequestID(ctx); requestID != "" {
+			if req, ok := in.Request.(*smithyhttp.Request); ok {
+				userAgent := req.Header.Get("User-Agent")
+				req.Header.Set("User-Agent", userAgent+" md/request-id#"+requestID)
+			}
+		}
+		return next.HandleBuild(ctx, in)
+	}), middleware.After)
+}
@@ -0,0 +1,237 @@
+package kmsclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kms/wallet/app/metrics"
+	"kms/wallet/common/errs"
+	mathrand "math/rand"
+	"time"
+
+	awsHttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+var _ Client = (*ResilientClient)(nil)
+
+type Config struct {
+	Timeout          time.Duration            // 호출 한번의 제한시간
+	OpTimeouts       map[string]time.Duration // api 별 제한시간 (ex. "Sign")
+	MaxRetries       int
+	BaseDelay        time.Duration // 재시도 간격은 시도할 때마다 두배로 늘어난다 (jitter 포함)
+	MaxDelay         time.Duration
+	FailureThreshold int           // circuit breaker 를 여는 연속 실패 횟수
+	OpenTimeout      time.Duration // circuit breaker 가 열려있는 시간
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Timeout:          5 * time.Second,
+		MaxRetries:       3,
+		BaseDelay:        100 * time.Millisecond,
+		MaxDelay:         2 * time.Second,
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+	}
+}
+
+type Health struct {
+	State               string `json:"state" example:"closed"` // closed, open, half-open
+	ConsecutiveFailures int    `json:"consecutiveFailures" example:"0"`
+	OpenedAt            string `json:"openedAt,omitempty"`
+	LastError           string `json:"lastError,omitempty"`
+}
+
+// 제한시간, 재시도, circuit breaker 를 적용한 Client
+type ResilientClient struct {
+	client  Client
+	config  Config
+	breaker *breaker
+}
+
+func New(client Client, config Config) *ResilientClient {
+	return &ResilientClient{client, config, newBreaker(config.FailureThreshold, config.OpenTimeout)}
+}
+
+func (c *ResilientClient) Health() Health {
+	return c.breaker.health()
+}
+
+func (c *ResilientClient) timeout(op string) time.Duration {
+	if timeout, ok := c.config.OpTimeouts[op]; ok {
+		return timeout
+	}
+	return c.config.Timeout
+}
+
+func (c *ResilientClient) backoff(attempt int) time.Duration {
+	delay := c.config.BaseDelay << attempt
+	if delay <= 0 || delay > c.config.MaxDelay {
+		delay = c.config.MaxDelay
+	}
+	return delay/2 + time.Duration(mathrand.Int63n(int64(delay/2)+1))
+}
+
+func call[T any](c *ResilientClient, ctx context.Context, op string, fn func(ctx context.Context) (*T, error)) (*T, error) {
+	// 이미 끝난 요청은 kms 를 호출하지 않는다
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	var (
+		out *T
+		err error
+	)
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, c.timeout(op))
+		start := time.Now()
+		out, err = fn(attemptCtx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			err = fmt.Errorf("%w: %w", errs.ErrKmsTimeout, err)
+		}
+		metrics.ObserveKmsCall(op, time.Since(start), err)
+
+		if err == nil || attempt >= c.config.MaxRetries || !retryable(op, err) || ctx.Err() != nil {
+			break
+		}
+
+		timer := time.NewTimer(c.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	switch {
+	case ctx.Err() != nil: // 호출한 쪽에서 취소한 경우는 kms 상태와 무관하다
+		c.breaker.release()
+	case unhealthy(err):
+		c.breaker.failure(err)
+	default:
+		c.breaker.success()
+	}
+	return out, err
+}
+
+// 요청 한도 초과는 항상 재시도한다. CreateKey, ReplicateKey 는 서버 에러 이후에 재시도하면 키가 중복 생성되거나
+// 이미 만들어진 replica 때문에 실패할 수 있어 재시도하지 않는다
+func retryable(op string, err error) bool {
+	if errs.IsThrottling(err) {
+		return true
+	}
+	return op != "CreateKey" && op != "ReplicateKey" && unhealthy(err)
+}
+
+// kms 쪽 장애로 판단하는 에러 (5xx, 타임아웃)
+func unhealthy(err error) bool {
+	if err == nil {
+		return false
+	}
+	var (
+		respErr     *awsHttp.ResponseError
+		internalErr *types.KMSInternalException
+		timeoutErr  *types.DependencyTimeoutException
+	)
+	switch {
+	case errors.As(err, &internalErr), errors.As(err, &timeoutErr):
+		return true
+	case errors.As(err, &respErr):
+		return respErr.HTTPStatusCode() >= 500
+	default:
+		return errors.Is(err, context.DeadlineExceeded)
+	}
+}
+
+func (c *ResilientClient) CreateKey(ctx context.Context, params *kms.CreateKeyInput, optFns ...func(*kms.Options)) (*kms.CreateKeyOutput, error) {
+	return call(c, ctx, "CreateKey", func(ctx context.Context) (*kms.CreateKeyOutput, error) {
+		return c.client.CreateKey(ctx, params, optFns...)
+	})
+}
+
+func (c *ResilientClient) DescribeKey(ctx context.Context, params *kms.DescribeKeyInput, optFns ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	return call(c, ctx, "DescribeKey", func(ctx context.Context) (*kms.DescribeKeyOutput, error) {
+		return c.client.DescribeKey(ctx, params, optFns...)
+	})
+}
+
+func (c *ResilientClient) DisableKey(ctx context.Context, params *kms.DisableKeyInput, optFns ...func(*kms.Options)) (*kms.DisableKeyOutput, error) {
+	return call(c, ctx, "DisableKey", func(ctx context.Context) (*kms.DisableKeyOutput, error) {
+		return c.client.DisableKey(ctx, params, optFns...)
+	})
+}
+
+func (c *ResilientClient) EnableKey(ctx context.Context, params *kms.EnableKeyInput, optFns ...func(*kms.Options)) (*kms.EnableKeyOutput, error) {
+	return call(c, ctx, "EnableKey", func(ctx context.Context) (*kms.EnableKeyOutput, error) {
+		return c.client.EnableKey(ctx, params, optFns...)
+	})
+}
+
+func (c *ResilientClient) GetKeyPolicy(ctx context.Context, params *kms.GetKeyPolicyInput, optFns ...func(*kms.Options)) (*kms.GetKeyPolicyOutput, error) {
+	return call(c, ctx, "GetKeyPolicy", func(ctx context.Context) (*kms.GetKeyPolicyOutput, error) {
+		return c.client.GetKeyPolicy(ctx, params, optFns...)
+	})
+}
+
+func (c *ResilientClient) GetParametersForImport(ctx context.Context, params *kms.GetParametersForImportInput, optFns ...func(*kms.Options)) (*kms.GetParametersForImportOutput, error) {
+	return call(c, ctx, "GetParametersForImport", func(ctx context.Context) (*kms.GetParametersForImportOutput, error) {
+		return c.client.GetParametersForImport(ctx, params, optFns...)
+	})
+}
+
+func (c *ResilientClient) GetPublicKey(ctx context.Context, params *kms.GetPublicKeyInput, optFns ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	return call(c, ctx, "GetPublicKey", func(ctx context.Context) (*kms.GetPublicKeyOutput, error) {
+		return c.client.GetPublicKey(ctx, params, optFns...)
+	})
+}
+
+func (c *ResilientClient) ImportKeyMaterial(ctx context.Context, params *kms.ImportKeyMaterialInput, optFns ...func(*kms.Options)) (*kms.ImportKeyMaterialOutput, error) {
+	return call(c, ctx, "ImportKeyMaterial", func(ctx context.Context) (*kms.ImportKeyMaterialOutput, error) {
+		return c.client.ImportKeyMaterial(ctx, params, optFns...)
+	})
+}
+
+func (c *ResilientClient) ListKeys(ctx context.Context, params *kms.ListKeysInput, optFns ...func(*kms.Options)) (*kms.ListKeysOutput, error) {
+	return call(c, ctx, "ListKeys", func(ctx context.Context) (*kms.ListKeysOutput, error) {
+		return c.client.ListKeys(ctx, params, optFns...)
+	})
+}
+
+func (c *ResilientClient) ListResourceTags(ctx context.Context, params *kms.ListResourceTagsInput, optFns ...func(*kms.Options)) (*kms.ListResourceTagsOutput, error) {
+	return call(c, ctx, "ListResourceTags", func(ctx context.Context) (*kms.ListResourceTagsOutput, error) {
+		return c.client.ListResourceTags(ctx, params, optFns...)
+	})
+}
+
+func (c *ResilientClient) ReplicateKey(ctx context.Context, params *kms.ReplicateKeyInput, optFns ...func(*kms.Options)) (*kms.ReplicateKeyOutput, error) {
+	return call(c, ctx, "ReplicateKey", func(ctx context.Context) (*kms.ReplicateKeyOutput, error) {
+		return c.client.ReplicateKey(ctx, params, optFns...)
+	})
+}
+
+func (c *ResilientClient) ScheduleKeyDeletion(ctx context.Context, params *kms.ScheduleKeyDeletionInput, optFns ...func(*kms.Options)) (*kms.ScheduleKeyDeletionOutput, error) {
+	return call(c, ctx, "ScheduleKeyDeletion", func(ctx context.Context) (*kms.ScheduleKeyDeletionOutput, error) {
+		return c.client.ScheduleKeyDeletion(ctx, params, optFns...)
+	})
+}
+
+func (c *ResilientClient) Sign(ctx context.Context, params *kms.SignInput, optFns ...func(*kms.Options)) (*kms.SignOutput, error) {
+	return call(c, ctx, "Sign", func(ctx context.Context) (*kms.SignOutput, error) {
+		return c.client.Sign(ctx, params, optFns...)
+	})
+}
+
+func (c *ResilientClient) TagResource(ctx context.Context, params *kms.TagResourceInput, optFns ...func(*kms.Options)) (*kms.TagResourceOutput, error) {
+	return call(c, ctx, "TagResource", func(ctx context.Context) (*kms.TagResourceOutput, error) {
+		return c.client.TagResource(ctx, params, optFns...)
+	})
+}
@@ -0,0 +1,278 @@
+package kmsclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+var _ Client = (*Router)(nil)
+
+// 이름이 붙은 kms (region, 계정별 클라이언트)
+type Backend struct {
+	Name    string
+	Region  string
+	Account string // 비어있으면 ARN 의 계정은 비교하지 않는다
+	Client  Client
+}
+
+// keyID 에 맞는 backend 로 호출을 보내는 Client.
+//
+// keyID 가 ARN 이면 region, 계정이 같은 backend 를, 아니면 keyRoutes 에 지정된 backend 를 사용한다.
+// 둘 다 아니면 backend 순서대로 DescribeKey 를 호출해서 키가 있는 backend 를 찾고 결과를 기억한다.
+// 새 키는 첫번째(기본) backend 에 만들고, ListKeys 는 모든 backend 의 목록을 순서대로 이어서 리턴한다
+type Router struct {
+	backends  []*Backend
+	byName    map[string]*Backend
+	keyRoutes map[string]string
+	resolved  sync.Map // keyID -> *Backend
+}
+
+// 목록 조회를 이어서 할 backend 와 그 backend 의 marker
+type routerMarker struct {
+	Backend string `json:"b"`
+	Marker  string `json:"m,omitempty"`
+}
+
+func NewRouter(backends []Backend, keyRoutes map[string]string) (*Router, error) {
+	if len(backends) == 0 {
+		return nil, errors.New("no kms backend")
+	}
+	r := &Router{byName: make(map[string]*Backend), keyRoutes: keyRoutes}
+	for i := range backends {
+		backend := &backends[i]
+		if _, ok := r.byName[backend.Name]; ok {
+			return nil, fmt.Errorf("duplicate kms backend '%v'", backend.Name)
+		}
+		r.backends = append(r.backends, backend)
+		r.byName[backend.Name] = backend
+	}
+	for keyID, name := range keyRoutes {
+		if _, ok := r.byName[name]; !ok {
+			return nil, fmt.Errorf("key '%v' is routed to unknown kms backend '%v'", keyID, name)
+		}
+	}
+	return r, nil
+}
+
+func (r *Router) resolve(ctx context.Context, keyID *string) (*Backend, error) {
+	if len(r.backends) == 1 {
+		return r.backends[0], nil
+	}
+	id := aws.ToString(keyID)
+
+	// arn:aws:kms:<region>:<account>:key/<id>
+	if parts := strings.SplitN(id, ":", 6); len(parts) == 6 && parts[0] == "arn" && parts[2] == "kms" {
+		for _, backend := range r.backends {
+			if backend.Region == parts[3] && (backend.Account == "" || backend.Account == parts[4]) {
+				return backend, nil
+			}
+		}
+		return nil, &types.NotFoundException{Message: aws.String(fmt.Sprintf("no kms backend for '%v'", id))}
+	}
+	if name, ok := r.keyRoutes[id]; ok {
+		return r.byName[name], nil
+	}
+	if backend, ok := r.resolved.Load(id); ok {
+		return backend.(*Backend), nil
+	}
+
+	// 키가 없는 backend 는 건너뛰고, 다른 에러는 모든 backend 에서 찾지 못했을 때 리턴한다
+	var lastErr error
+	for _, backend := range r.backends {
+		_, err := backend.Client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: keyID})
+		if err == nil {
+			r.resolved.Store(id, backend)
+			return backend, nil
+		}
+		var notFound *types.NotFoundException
+		if lastErr == nil || !errors.As(err, &notFound) {
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
+
+func (r *Router) encodeMarker(marker routerMarker) *string {
+	data, _ := json.Marshal(marker)
+	return aws.String(base64.RawURLEncoding.EncodeToString(data))
+}
+
+func (r *Router) decodeMarker(marker string) (int, *string, error) {
+	var decoded routerMarker
+	data, err := base64.RawURLEncoding.DecodeString(marker)
+	if err == nil {
+		err = json.Unmarshal(data, &decoded)
+	}
+	if err == nil {
+		for i, backend := range r.backends {
+			if backend.Name == decoded.Backend {
+				if decoded.Marker == "" {
+					return i, nil, nil
+				}
+				return i, aws.String(decoded.Marker), nil
+			}
+		}
+	}
+	return 0, nil, &types.InvalidMarkerException{Message: aws.String("invalid marker")}
+}
+
+func (r *Router) CreateKey(ctx context.Context, params *kms.CreateKeyInput, optFns ...func(*kms.Options)) (*kms.CreateKeyOutput, error) {
+	backend := r.backends[0]
+	output, err := backend.Client.CreateKey(ctx, params, optFns...)
+	if err == nil && output.KeyMetadata != nil {
+		r.resolved.Store(aws.ToString(output.KeyMetadata.KeyId), backend)
+	}
+	return output, err
+}
+
+func (r *Router) DescribeKey(ctx context.Context, params *kms.DescribeKeyInput, optFns ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	backend, err := r.resolve(ctx, params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Client.DescribeKey(ctx, params, optFns...)
+}
+
+func (r *Router) DisableKey(ctx context.Context, params *kms.DisableKeyInput, optFns ...func(*kms.Options)) (*kms.DisableKeyOutput, error) {
+	backend, err := r.resolve(ctx, params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Client.DisableKey(ctx, params, optFns...)
+}
+
+func (r *Router) EnableKey(ctx context.Context, params *kms.EnableKeyInput, optFns ...func(*kms.Options)) (*kms.EnableKeyOutput, error) {
+	backend, err := r.resolve(ctx, params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Client.EnableKey(ctx, params, optFns...)
+}
+
+func (r *Router) GetKeyPolicy(ctx context.Context, params *kms.GetKeyPolicyInput, optFns ...func(*kms.Options)) (*kms.GetKeyPolicyOutput, error) {
+	backend, err := r.resolve(ctx, params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Client.GetKeyPolicy(ctx, params, optFns...)
+}
+
+func (r *Router) GetParametersForImport(ctx context.Context, params *kms.GetParametersForImportInput, optFns ...func(*kms.Options)) (*kms.GetParametersForImportOutput, error) {
+	backend, err := r.resolve(ctx, params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Client.GetParametersForImport(ctx, params, optFns...)
+}
+
+func (r *Router) GetPublicKey(ctx context.Context, params *kms.GetPublicKeyInput, optFns ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	backend, err := r.resolve(ctx, params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Client.GetPublicKey(ctx, params, optFns...)
+}
+
+func (r *Router) ImportKeyMaterial(ctx context.Context, params *kms.ImportKeyMaterialInput, optFns ...func(*kms.Options)) (*kms.ImportKeyMaterialOutput, error) {
+	backend, err := r.resolve(ctx, params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Client.ImportKeyMaterial(ctx, params, optFns...)
+}
+
+// backend 가 여러개면 marker 는 이어서 조회할 backend 와 그 backend 의 marker 를 담은 값이다.
+// 한 backend 의 목록이 Limit 보다 적게 끝나면 남은 개수만큼 다음 backend 에서 채운다
+func (r *Router) ListKeys(ctx context.Context, params *kms.ListKeysInput, optFns ...func(*kms.Options)) (*kms.ListKeysOutput, error) {
+	if len(r.backends) == 1 {
+		return r.backends[0].Client.ListKeys(ctx, params, optFns...)
+	}
+
+	start, marker := 0, (*string)(nil)
+	if params.Marker != nil {
+		var err error
+		if start, marker, err = r.decodeMarker(*params.Marker); err != nil {
+			return nil, err
+		}
+	}
+	limit := int32(100)
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	output := &kms.ListKeysOutput{}
+	for i := start; i < len(r.backends); i++ {
+		backend := r.backends[i]
+		remaining := limit - int32(len(output.Keys))
+		page, err := backend.Client.ListKeys(ctx, &kms.ListKeysInput{Limit: aws.Int32(remaining), Marker: marker}, optFns...)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range page.Keys {
+			r.resolved.Store(aws.ToString(key.KeyId), backend)
+		}
+		output.Keys = append(output.Keys, page.Keys...)
+		marker = nil
+
+		if page.Truncated {
+			output.Truncated = true
+			output.NextMarker = r.encodeMarker(routerMarker{Backend: backend.Name, Marker: aws.ToString(page.NextMarker)})
+			break
+		}
+		if int32(len(output.Keys)) >= limit && i+1 < len(r.backends) {
+			output.Truncated = true
+			output.NextMarker = r.encodeMarker(routerMarker{Backend: r.backends[i+1].Name})
+			break
+		}
+	}
+	return output, nil
+}
+
+func (r *Router) ListResourceTags(ctx context.Context, params *kms.ListResourceTagsInput, optFns ...func(*kms.Options)) (*kms.ListResourceTagsOutput, error) {
+	backend, err := r.resolve(ctx, params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Client.ListResourceTags(ctx, params, optFns...)
+}
+
+func (r *Router) ReplicateKey(ctx context.Context, params *kms.ReplicateKeyInput, optFns ...func(*kms.Options)) (*kms.ReplicateKeyOutput, error) {
+	backend, err := r.resolve(ctx, params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Client.ReplicateKey(ctx, params, optFns...)
+}
+
+func (r *Router) ScheduleKeyDeletion(ctx context.Context, params *kms.ScheduleKeyDeletionInput, optFns ...func(*kms.Options)) (*kms.ScheduleKeyDeletionOutput, error) {
+	backend, err := r.resolve(ctx, params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Client.ScheduleKeyDeletion(ctx, params, optFns...)
+}
+
+func (r *Router) Sign(ctx context.Context, params *kms.SignInput, optFns ...func(*kms.Options)) (*kms.SignOutput, error) {
+	backend, err := r.resolve(ctx, params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Client.Sign(ctx, params, optFns...)
+}
+
+func (r *Router) TagResource(ctx context.Context, params *kms.TagResourceInput, optFns ...func(*kms.Options)) (*kms.TagResourceOutput, error) {
+	backend, err := r.resolve(ctx, params.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Client.TagResource(ctx, params, optFns...)
+}
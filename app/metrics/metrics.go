@@ -0,0 +1,231 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"kms/wallet/app/cache"
+	"kms/wallet/common/errs"
+	"kms/wallet/common/logger"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// 라벨 값은 route 템플릿, kms api 이름, 에러 코드처럼 개수가 제한된 값만 사용한다 (keyID 금지).
+// keyID 별 서명 횟수는 EnablePerKey 로 켠 경우에만 기록한다
+var (
+	Registry = prometheus.NewRegistry()
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wallet_http_requests_total",
+		Help: "HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wallet_http_request_duration_seconds",
+		Help:    "HTTP request latency by route, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+	limiterRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "wallet_http_limiter_rejections_total",
+		Help: "Requests rejected by the rate limiter.",
+	})
+
+	kmsDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wallet_kms_call_duration_seconds",
+		Help:    "Latency of a single AWS KMS call attempt by operation.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"operation"})
+	kmsErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wallet_kms_call_errors_total",
+		Help: "Failed AWS KMS call attempts by operation and error code.",
+	}, []string{"operation", "code"})
+
+	signRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "wallet_sign_invalid_v_retries_total",
+		Help: "Signatures requested again because no valid recovery id (v) could be derived.",
+	})
+	signsByKey = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wallet_sign_by_key_total",
+		Help: "Signed transactions per key id. Only recorded when per-key metrics are enabled.",
+	}, []string{"key_id"})
+
+	perKey atomic.Bool
+
+	// main 에서 연결하는 외부 상태
+	pubKeyStats  func() cache.PubKeyCacheStats
+	breakerState func() string
+	mutex        sync.RWMutex
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, limiterRejections,
+		kmsDuration, kmsErrors,
+		signRetries, signsByKey,
+
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "wallet_pubkey_cache_hits_total",
+			Help: "Public key cache hits.",
+		}, cacheStat(func(stats cache.PubKeyCacheStats) float64 { return float64(stats.Hits) })),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "wallet_pubkey_cache_misses_total",
+			Help: "Public key cache misses.",
+		}, cacheStat(func(stats cache.PubKeyCacheStats) float64 { return float64(stats.Misses) })),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "wallet_pubkey_cache_evictions_total",
+			Help: "Public key cache evictions.",
+		}, cacheStat(func(stats cache.PubKeyCacheStats) float64 { return float64(stats.Evictions) })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "wallet_pubkey_cache_size",
+			Help: "Public keys currently cached.",
+		}, cacheStat(func(stats cache.PubKeyCacheStats) float64 { return float64(stats.Size) })),
+
+		// kmsclient 의 상태값 (closed, half-open, open)
+		breakerGauge("closed"), breakerGauge("half-open"), breakerGauge("open"),
+	)
+}
+
+// keyID 별 서명 횟수 기록 (METRICS_PER_KEY). 키 개수만큼 시계열이 늘어나므로 키가 적을 때만 사용한다
+func EnablePerKey(enabled bool) {
+	perKey.Store(enabled)
+}
+
+// /metrics 핸들러
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
+
+// route, method, status 별 요청 수와 처리 시간을 기록한다.
+// 에러는 여기서 ErrorHandler 로 응답을 만들어야 실제 status 를 알 수 있다
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		if err != nil {
+			if handlerErr := c.App().Config().ErrorHandler(c, err); handlerErr != nil {
+				c.Status(fiber.StatusInternalServerError)
+			}
+		}
+		// 일치하는 route 가 없으면 c.Route() 에는 마지막으로 지나온 미들웨어가 남는다
+		route := c.Route().Path
+		if unmatched, _ := c.Locals(unmatchedKey{}).(bool); unmatched {
+			route = "unmatched"
+		}
+
+		status := strconv.Itoa(c.Response().StatusCode())
+		httpRequests.WithLabelValues(route, c.Method(), status).Inc()
+		httpDuration.WithLabelValues(route, c.Method(), status).Observe(time.Since(start).Seconds())
+		return nil
+	}
+}
+
+type unmatchedKey struct{}
+
+// 일치하는 route 가 없는 요청으로 표시한다. 요청 로그 미들웨어가 에러 응답을 먼저 만들 수 있기 때문에
+// 에러 대신 ErrorHandler 에서 표시한 값으로 판단한다
+func MarkUnmatched(c *fiber.Ctx) {
+	c.Locals(unmatchedKey{}, true)
+}
+
+// pull 방식이라 종료 이후의 값은 수집되지 않기 때문에, 마지막 scrape 이후의 변화를 잃지 않도록
+// 종료할 때 wallet_ 카운터의 합계를 로그로 남긴다
+func Flush(ctx context.Context) error {
+	families, err := Registry.Gather()
+	if err != nil {
+		return err
+	}
+	logItem := logger.Info().Ctx(ctx)
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), "wallet_") || family.GetType() != dto.MetricType_COUNTER {
+			continue
+		}
+		var total float64
+		for _, metric := range family.GetMetric() {
+			total += metric.GetCounter().GetValue()
+		}
+		logItem = logItem.D(family.GetName(), total)
+	}
+	logItem.W("final metrics")
+	return nil
+}
+
+// limiter.Config.LimitReached
+func LimitReached(c *fiber.Ctx) error {
+	limiterRejections.Inc()
+	return c.SendStatus(fiber.StatusTooManyRequests)
+}
+
+// kms 호출 한번의 결과를 기록한다. 에러는 errs 의 에러 코드로 분류한다
+func ObserveKmsCall(op string, duration time.Duration, err error) {
+	kmsDuration.WithLabelValues(op).Observe(duration.Seconds())
+	if err != nil {
+		code := errs.KindKmsError.Code
+		var customErr *errs.CusErr
+		if errors.As(errs.RouteAwsErr(err), &customErr) {
+			code = customErr.Kind.Code
+		}
+		kmsErrors.WithLabelValues(op, code).Inc()
+	}
+}
+
+func IncSignRetry() {
+	signRetries.Inc()
+}
+
+func IncSignByKey(keyID string) {
+	if perKey.Load() {
+		signsByKey.WithLabelValues(keyID).Inc()
+	}
+}
+
+// public key 캐시 통계. 캐시가 가진 카운터를 그대로 노출한다
+func SetPubKeyCache(stats func() cache.PubKeyCacheStats) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	pubKeyStats = stats
+}
+
+// kms circuit breaker 상태 (closed, half-open, open)
+func SetBreaker(state func() string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	breakerState = state
+}
+
+func cacheStat(value func(cache.PubKeyCacheStats) float64) func() float64 {
+	return func() float64 {
+		mutex.RLock()
+		defer mutex.RUnlock()
+		if pubKeyStats == nil {
+			return 0
+		}
+		return value(pubKeyStats())
+	}
+}
+
+func breakerGauge(state string) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "wallet_kms_circuit_state",
+		Help:        "KMS circuit breaker state. 1 for the current state.",
+		ConstLabels: prometheus.Labels{"state": state},
+	}, func() float64 {
+		mutex.RLock()
+		defer mutex.RUnlock()
+		if breakerState != nil && breakerState() == state {
+			return 1
+		}
+		return 0
+	})
+}
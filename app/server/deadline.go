@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DeadlineConfig struct {
+	Timeout time.Duration            // 기본 요청 제한시간. 0 이면 제한하지 않는다
+	Routes  map[string]time.Duration // path prefix 별 제한시간. 가장 길게 일치하는 prefix 가 적용된다
+}
+
+// 요청 context 에 제한시간을 걸어 서비스의 kms 호출까지 전달한다.
+// fasthttp 는 클라이언트 연결 종료를 알려주지 않기 때문에 제한시간이 호출을 멈추는 유일한 수단이다
+func Deadline(config DeadlineConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		timeout := config.timeout(c.Path())
+		if timeout <= 0 {
+			return c.Next()
+		}
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+func (config DeadlineConfig) timeout(path string) time.Duration {
+	var (
+		matched string
+		timeout = config.Timeout
+	)
+	for prefix, routeTimeout := range config.Routes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(matched) {
+			matched, timeout = prefix, routeTimeout
+		}
+	}
+	return timeout
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"errors"
 	"kms/wallet/app/api/model/dto"
+	"kms/wallet/app/metrics"
 	"kms/wallet/common/errs"
 	"kms/wallet/common/logger"
 	"kms/wallet/common/utils/timeutil"
@@ -14,38 +15,53 @@ func ErrHandler(c *fiber.Ctx, err error) error {
 	var (
 		fiberErr  *fiber.Error
 		customErr *errs.CusErr
+		kind      = errs.KindUnhandled
 		code      = errs.Errs["UnhandledServerErr"].Code
 		msg       = []string{errs.Errs["UnhandledServerErr"].Type}
+		details   map[string]any
 	)
+	if !errs.LegacyStatus {
+		code = kind.Status
+	}
 
 	if errors.As(err, &customErr) {
-		code = customErr.Code
+		kind = customErr.Kind
+		code = customErr.Status()
 		msg[0] = customErr.Type
-		switch code / 100 {
+		details = customErr.Details
+		switch kind.Status / 100 {
 		case 4:
 			msg = append(msg, customErr.Inner.Error())
-		case 5, 6:
-			logger.Error().E(customErr.Inner).D("trace", customErr.Trace).D("func", customErr.Func).W(customErr.Type)
+		case 5:
+			logger.Error().Ctx(c.UserContext()).E(customErr.Inner).D("trace", customErr.Trace).D("func", customErr.Func).W(customErr.Type)
 		}
 	} else if errors.As(err, &fiberErr) {
 		code = fiberErr.Code
+		kind.Status = fiberErr.Code
 		switch {
 		case code == fiber.StatusNotFound:
+			metrics.MarkUnmatched(c)
+			kind = errs.KindRouteNotFound
 			msg[0] = fiber.ErrNotFound.Message
 		case code == fiber.StatusBadRequest:
+			kind = errs.KindBadRequest
 			msg[0] = fiberErr.Message
 		default:
-			logger.Error().E(err).W("unhandled fiber error")
+			logger.Error().Ctx(c.UserContext()).E(err).W("unhandled fiber error")
 		}
 	} else {
-		logger.Error().E(err).W(msg[0])
+		logger.Error().Ctx(c.UserContext()).E(err).W(msg[0])
 	}
 
 	return c.Status(code).JSON(&dto.ErrRes{
 		Status:    code,
+		Code:      kind.Code,
+		Retryable: kind.Retryable,
 		Timestamp: timeutil.FormatNow(),
 		Method:    c.Method(),
 		Path:      c.Path(),
 		Message:   msg,
+		Details:   details,
+		RequestID: logger.RequestID(c.UserContext()),
 	})
 }
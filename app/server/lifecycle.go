@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kms/wallet/common/logger"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+var ErrShutdownTimeout = errors.New("shutdown timed out before in-flight work finished")
+
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// 종료할 때 실행할 작업을 등록한다. http 요청이 모두 끝난 뒤 등록한 순서대로 실행되고,
+// 모든 작업은 server.shutdown_timeout 안에서 남은 시간을 나눠 쓴다
+func (s *Server) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.hooks = append(s.hooks, shutdownHook{name, fn})
+}
+
+// addr 에서 요청을 받고, SIGINT, SIGTERM 을 받으면 Shutdown 한 뒤 리턴한다
+func (s *Server) Run(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+func (s *Server) Serve(ln net.Listener) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listenErr := make(chan error, 1)
+	go func() { listenErr <- s.App.Listener(ln) }()
+
+	select {
+	case err := <-listenErr:
+		return err
+	case <-ctx.Done():
+		logger.Info().D("timeout", s.shutdownTimeout.String()).W("shutting down")
+	}
+	return s.Shutdown()
+}
+
+// 새 요청을 받지 않고, 처리중인 요청(kms 서명 포함)이 끝나기를 기다린 뒤 등록된 종료 작업을 실행한다.
+// 제한시간 안에 끝나지 않으면 ErrShutdownTimeout 을 리턴한다
+func (s *Server) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	var shutdownErrs []error
+	if err := s.App.ShutdownWithContext(ctx); err != nil {
+		shutdownErrs = append(shutdownErrs, fmt.Errorf("http: %w", err))
+	}
+	for _, hook := range s.hooks {
+		if err := hook.fn(ctx); err != nil {
+			logger.Error().E(err).D("hook", hook.name).W("shutdown hook failed")
+			shutdownErrs = append(shutdownErrs, fmt.Errorf("%v: %w", hook.name, err))
+		}
+	}
+
+	err := errors.Join(shutdownErrs...)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: %w", ErrShutdownTimeout, err)
+	}
+	if err != nil {
+		return err
+	}
+	logger.Info().W("shutdown complete")
+	return nil
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"kms/wallet/common/config"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// 요청 로그와 panic 로그에 남기지 않을 값들.
+// 필드 이름은 json body(깊이 상관없이), query 파라미터, 헤더에 모두 적용된다
+var defaultRedactFields = []string{
+	"pk", "privateKey", "passphrase", "mnemonic", "keystore", "pem", "encryptedKeyMaterial", "importToken",
+	"signature", "signedTxn", "webhookSecret",
+	"Authorization", "Cookie", "X-Signature",
+}
+
+// 외부키 주입 요청은 형식과 상관없이 키 정보를 담고 있기 때문에 body 를 남기지 않는다.
+// 응답도 검증 에러 등에 요청 값이 섞일 수 있어서 남기지 않는다
+var defaultRedactRoutes = map[string]RedactRule{
+	"/api/import/account": {Drop: []string{"reqBody", "resBody"}},
+}
+
+type RedactRule struct {
+	Drop []string // 로그에서 제거할 항목 (reqBody, resBody, queryParams, reqHeader)
+	Mask []string // 해당 route 에서만 추가로 가릴 필드
+}
+
+type RedactConfig struct {
+	Fields []string              // 모든 route 에서 값을 가릴 필드 (대소문자 무시)
+	Routes map[string]RedactRule // path prefix 별 규칙. 일치하는 모든 prefix 의 규칙이 합쳐진다
+	MaxLen int                   // 문자열 값의 최대 길이. 0 이면 자르지 않는다
+}
+
+// 로그 항목(reqBody, resBody ...)에서 path 에 해당하는 규칙에 따라 값을 지우거나 가린다
+func (config RedactConfig) Redact(path string, entries map[string]any) {
+	masked := make(map[string]bool)
+	for _, field := range config.Fields {
+		masked[strings.ToLower(field)] = true
+	}
+	for prefix, rule := range config.Routes {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		for _, entry := range rule.Drop {
+			delete(entries, entry)
+		}
+		for _, field := range rule.Mask {
+			masked[strings.ToLower(field)] = true
+		}
+	}
+
+	for k, v := range entries {
+		entries[k] = config.redact(v, masked)
+	}
+}
+
+func (config RedactConfig) redact(value any, masked map[string]bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		redactedMap := make(map[string]any, len(v))
+		for k, inner := range v {
+			if masked[strings.ToLower(k)] {
+				redactedMap[k] = redacted
+			} else {
+				redactedMap[k] = config.redact(inner, masked)
+			}
+		}
+		return redactedMap
+	case map[string][]string: // 헤더
+		redactedMap := make(map[string]any, len(v))
+		for k, inner := range v {
+			if masked[strings.ToLower(k)] {
+				redactedMap[k] = redacted
+			} else {
+				redactedMap[k] = config.redact(strings.Join(inner, ","), masked)
+			}
+		}
+		return redactedMap
+	case []any:
+		redactedList := make([]any, len(v))
+		for i, inner := range v {
+			redactedList[i] = config.redact(inner, masked)
+		}
+		return redactedList
+	case string:
+		if config.MaxLen > 0 && len(v) > config.MaxLen {
+			return fmt.Sprintf("%v...(%v bytes)", v[:config.MaxLen], len(v))
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// 기본 규칙에 설정값을 더한다. 기본 규칙은 끌 수 없다
+func NewRedactConfig(logging config.LoggingConfig) RedactConfig {
+	redactConfig := RedactConfig{
+		Fields: append(append([]string{}, defaultRedactFields...), logging.RedactFields...),
+		Routes: make(map[string]RedactRule),
+		MaxLen: logging.MaxValueLen,
+	}
+	for prefix, rule := range defaultRedactRoutes {
+		redactConfig.Routes[prefix] = rule
+	}
+	for prefix, route := range logging.RedactRoutes {
+		rule := redactConfig.Routes[prefix]
+		rule.Drop = append(append([]string{}, rule.Drop...), route.Drop...)
+		rule.Mask = append(append([]string{}, rule.Mask...), route.Mask...)
+		redactConfig.Routes[prefix] = rule
+	}
+	return redactConfig
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"kms/wallet/common/logger"
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	requestIDLocal  = "requestID"
+)
+
+// 로그와 kms user-agent 에 그대로 들어가기 때문에 안전한 문자만 허용한다
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// 요청 헤더의 X-Request-ID 를 사용하고, 없거나 형식이 맞지 않으면 새로 만든다.
+// 응답 헤더, 에러 응답, 요청 중에 남기는 로그, kms 호출에 같은 id 가 포함된다
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Set(RequestIDHeader, requestID)
+		c.Locals(requestIDLocal, requestID)
+		c.SetUserContext(logger.WithRequestID(c.UserContext(), requestID))
+		return c.Next()
+	}
+}
@@ -3,10 +3,14 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	"kms/wallet/app/metrics"
+	"kms/wallet/app/tracing"
 	"kms/wallet/common/config"
+	"kms/wallet/common/errs"
 	"kms/wallet/common/logger"
 	"kms/wallet/common/utils/timeutil"
 	_ "kms/wallet/docs"
+	"log"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -18,17 +22,34 @@ import (
 )
 
 type Server struct {
-	App *fiber.App
+	App             *fiber.App
+	shutdownTimeout time.Duration
+	hooks           []shutdownHook
 }
 
 func New() *Server {
 	app := fiber.New(fiber.Config{
 		ErrorHandler: ErrHandler,
 	})
+	serverConfig := config.Env.Server
+	deadlineConfig := DeadlineConfig{Timeout: serverConfig.RequestTimeout, Routes: serverConfig.RequestTimeouts}
+	redactConfig := NewRedactConfig(config.Env.Logging)
+	switch serverConfig.ErrorCodes {
+	case "", "standard":
+	case "legacy":
+		errs.LegacyStatus = true
+	default:
+		log.Fatalf("Invalid server.error_codes %v", serverConfig.ErrorCodes)
+	}
+
+	app.Use(RequestID())          // 이후의 모든 미들웨어, 핸들러, 에러 응답에서 request id 를 사용할 수 있도록 가장 먼저 등록
+	app.Use(tracing.Middleware()) // metrics 에서 에러 응답을 만든 뒤의 status 를 기록한다
+	app.Use(metrics.Middleware())
 	app.Use(cors.New())
 	app.Use(limiter.New(limiter.Config{
-		Expiration: 60 * time.Second,
-		Max:        1000,
+		Expiration:   60 * time.Second,
+		Max:          serverConfig.RateLimit,
+		LimitReached: metrics.LimitReached,
 	}))
 	// handle panic
 	app.Use(recover.New(recover.Config{
@@ -38,42 +59,41 @@ func New() *Server {
 				json.Unmarshal(data, &ret)
 				return
 			}
+			bodyParser := func(data []byte) any {
+				var parsed any
+				if err := json.Unmarshal(data, &parsed); err != nil {
+					return string(data)
+				}
+				return parsed
+			}
 			data := map[string]any{
 				"ip":          c.IP(),
 				"path":        c.Path(),
 				"method":      c.Method(),
 				"reqHeader":   c.GetReqHeaders(),
 				"queryParams": parser(c.Context().URI().QueryString()),
-				"reqBody":     string(c.Request().Body()),
+				"reqBody":     bodyParser(c.Request().Body()),
 			}
+			redactConfig.Redact(c.Path(), data)
 			// zlogger.Panic().Err(fmt.Errorf("%v", e)).Interface("data", data).Send()
-			logger.Panic().E(fmt.Errorf("%v", e)).D("data", data).W()
+			logger.Panic().Ctx(c.UserContext()).E(fmt.Errorf("%v", e)).D("data", data).W()
 			// fmt.Printf("[Panic] \r\nip: %s \r\nstatus: %v \r\npath: %s \r\nmethod: %s \r\nreqHeader: %s \r\nqueryParams: %s \r\nreqBody: %s \r\nresBody: %s \r\ntime: %s \r\nerrLog: %s\r\n\r\n", c.IP(), c.Response().StatusCode(), c.Path(), c.Method(), strings.ReplaceAll(strings.ReplaceAll(string(c.Request().Header.RawHeaders()), "\r\n", "&"), ": ", "="), c.Request().URI().QueryString(), c.Request().Body(), c.Response().Body(), timeutil.FormatNow(), fmt.Sprintf("%v\n%s\n", e, debug.Stack()))
 
 		},
 	}))
 	// swagger
 	app.Get("/swagger/*", swagger.HandlerDefault) // logger 세팅 전에 설정
+	app.Get("/metrics", metrics.Handler())
 
 	// logger
-	if config.Env.Log {
+	if config.Env.Logging.Requests {
 		app.Use(fiberlogger.New(fiberlogger.Config{ // Only all routes that are registered after this one will be logged
 			Format:     formatter(),
 			TimeFormat: timeutil.DateFormat,
-			Output:     &writer{},
+			Output:     &writer{redactConfig},
 		}))
 	}
+	app.Use(Deadline(deadlineConfig))
 
-	return &Server{app}
-}
-
-// func (s *server) Run(port string) <-chan error {
-// 	ch := make(chan error)
-// 	go func() { ch <- s.App.Listen(port) }()
-// 	fmt.Printf("Listening at %v\n", port)
-// 	return ch
-// }
-
-func (s *Server) Run(port string) error {
-	return s.App.Listen(port)
+	return &Server{App: app, shutdownTimeout: serverConfig.ShutdownTimeout}
 }
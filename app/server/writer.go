@@ -11,27 +11,35 @@ import (
 )
 
 var (
-	logFields = []string{"ip", "status", "path", "method", "queryParams", "body", "resBody", "latency"}
+	logFields = []string{"ip", "status", "path", "method", "queryParams", "body", "resBody", "latency", "requestID"}
+	logTags   = map[string]string{"requestID": "locals:" + requestIDLocal} // 필드 이름과 다른 fiber logger 태그
 	sep       = "\r\n"
 )
 
 func formatter() string {
 	formatted := make([]string, len(logFields))
 	for i, field := range logFields {
-		formatted[i] = fmt.Sprintf("%s:${"+field+"}", field)
+		tag, ok := logTags[field]
+		if !ok {
+			tag = field
+		}
+		formatted[i] = fmt.Sprintf("%s:${%s}", field, tag)
 	}
 	return strings.Join(formatted, sep)
 }
 
 type writer struct {
+	redact RedactConfig
 }
 
-func (writer) Write(elements []byte) (int, error) {
+func (w writer) Write(elements []byte) (int, error) {
 	elMap := make(map[string]interface{})
-	for _, each := range bytes.Split(elements, []byte(sep)) {
+	for _, each := range splitFields(elements) {
 		k, val := destructNprocess(each, []byte(":"))
 		elMap[k] = val
 	}
+	path, _ := elMap["path"].(string)
+	w.redact.Redact(path, elMap)
 
 	logItem := logger.Info()
 	for _, k := range maps.Keys(elMap) {
@@ -42,6 +50,34 @@ func (writer) Write(elements []byte) (int, error) {
 	return len(elements), nil
 }
 
+// body 에도 구분자가 들어있을 수 있기 때문에 logFields 로 시작하는 줄에서만 나눈다
+func splitFields(elements []byte) [][]byte {
+	var (
+		fields  [][]byte
+		current [][]byte
+	)
+	for _, each := range bytes.Split(elements, []byte(sep)) {
+		if len(current) > 0 && isLogField(each) {
+			fields = append(fields, bytes.Join(current, []byte(sep)))
+			current = nil
+		}
+		current = append(current, each)
+	}
+	if len(current) > 0 {
+		fields = append(fields, bytes.Join(current, []byte(sep)))
+	}
+	return fields
+}
+
+func isLogField(line []byte) bool {
+	for _, field := range logFields {
+		if bytes.HasPrefix(line, []byte(field+":")) {
+			return true
+		}
+	}
+	return false
+}
+
 func destructNprocess(slice []byte, sep []byte) (string, interface{}) {
 	splited := bytes.SplitN(slice, sep, 2)
 	var (
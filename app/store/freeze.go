@@ -0,0 +1,74 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+type Freeze struct {
+	KeyID    string    `json:"keyID"`
+	Address  string    `json:"address,omitempty"` // 동결할 때의 주소. 비활성화된 키는 public key 를 조회할 수 없다
+	Reason   string    `json:"reason"`
+	Actor    string    `json:"actor"`
+	Retired  bool      `json:"retired,omitempty"` // 로테이션이 끝난 키
+	FrozenAt time.Time `json:"frozenAt"`
+}
+
+// 서명을 차단할 keyID 저장소 (kill switch). path 가 주어지면 변경될 때마다 json 파일로 기록해서 재시작 이후에도 차단을 유지한다
+type FreezeStore struct {
+	path    string
+	freezes map[string]Freeze
+	mutex   sync.RWMutex
+}
+
+func NewFreezeStore(path string) (*FreezeStore, error) {
+	s := &FreezeStore{
+		path:    path,
+		freezes: make(map[string]Freeze),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.freezes); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FreezeStore) Get(keyID string) (Freeze, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	freeze, ok := s.freezes[keyID]
+	return freeze, ok
+}
+
+// 파일 기록에 실패해도 이 프로세스에서는 차단된다
+func (s *FreezeStore) Save(freeze Freeze) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.freezes[freeze.KeyID] = freeze
+	if s.path == "" {
+		return nil
+	}
+	return writeJSON(s.path, s.freezes)
+}
+
+func (s *FreezeStore) Remove(keyID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.freezes, keyID)
+	if s.path == "" {
+		return nil
+	}
+	return writeJSON(s.path, s.freezes)
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	JobTypeSign = "sign"
+
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+type Job struct {
+	ID           string          `json:"id"`
+	Type         string          `json:"type"`
+	Status       string          `json:"status"`
+	Request      json.RawMessage `json:"request"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	Attempts     int             `json:"attempts"`
+	LastError    string          `json:"lastError,omitempty"`
+	RequestID    string          `json:"requestID,omitempty"` // 작업을 등록한 요청의 X-Request-ID
+	CallbackURL  string          `json:"callbackURL,omitempty"`
+	CallbackDone bool            `json:"callbackDone"`
+	CallbackErr  string          `json:"callbackErr,omitempty"`
+	NextRunAt    time.Time       `json:"nextRunAt"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	UpdatedAt    time.Time       `json:"updatedAt"`
+}
+
+func (j *Job) Finished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed
+}
+
+// 비동기 작업 저장소 (in-memory, sqlite)
+type JobStore interface {
+	Save(job Job) error
+	Get(id string) (Job, bool, error)
+	// 재시작 이후 다시 실행해야 하는 작업 (queued, running)
+	ListUnfinished() ([]Job, error)
+	Close() error
+}
+
+type MemoryJobStore struct {
+	jobs  map[string]Job
+	mutex sync.RWMutex
+}
+
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{jobs: make(map[string]Job)}
+}
+
+func (s *MemoryJobStore) Save(job Job) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.jobs[job.ID] = job
+	return nil
+}
+
+func (s *MemoryJobStore) Get(id string) (Job, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	job, ok := s.jobs[id]
+	return job, ok, nil
+}
+
+func (s *MemoryJobStore) ListUnfinished() ([]Job, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var jobs []Job
+	for _, job := range s.jobs {
+		if !job.Finished() {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	return jobs, nil
+}
+
+func (s *MemoryJobStore) Close() error {
+	return nil
+}
+
+// 작업 전체를 json 으로 저장하고 조회에 필요한 컬럼만 따로 둔다
+type SQLiteJobStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteJobStore(path string) (*SQLiteJobStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// sqlite 는 동시에 하나의 writer 만 허용한다
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS jobs (
+		id         TEXT PRIMARY KEY,
+		status     TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		data       TEXT NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteJobStore{db}, nil
+}
+
+func (s *SQLiteJobStore) Save(job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO jobs (id, status, created_at, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET status = excluded.status, data = excluded.data`,
+		job.ID, job.Status, job.CreatedAt.UnixNano(), string(data),
+	)
+	return err
+}
+
+func (s *SQLiteJobStore) Get(id string) (Job, bool, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM jobs WHERE id = ?`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Job{}, false, nil
+	} else if err != nil {
+		return Job{}, false, err
+	}
+
+	var job Job
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return Job{}, false, err
+	}
+	return job, true, nil
+}
+
+func (s *SQLiteJobStore) ListUnfinished() ([]Job, error) {
+	rows, err := s.db.Query(`SELECT data FROM jobs WHERE status IN (?, ?) ORDER BY created_at`, JobStatusQueued, JobStatusRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var job Job
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (s *SQLiteJobStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+)
+
+const (
+	RotationStepCreated = "created"
+	RotationStepSwept   = "swept"
+	RotationStepRetired = "retired"
+)
+
+type Sweep struct {
+	Asset     string `json:"asset"` // "native" 혹은 erc20 컨트랙트 주소
+	Amount    string `json:"amount"`
+	SignedTxn string `json:"signedTxn"`
+	TxHash    string `json:"txHash"`
+	Sent      bool   `json:"sent"`
+}
+
+type Rotation struct {
+	OldKeyID   string  `json:"oldKeyID"`
+	OldAddress string  `json:"oldAddress"`
+	NewKeyID   string  `json:"newKeyID"`
+	NewAddress string  `json:"newAddress"`
+	Step       string  `json:"step"`
+	Sweeps     []Sweep `json:"sweeps"`
+	Actor      string  `json:"actor"`
+	StartedAt  string  `json:"startedAt"`
+	UpdatedAt  string  `json:"updatedAt"`
+	LastError  string  `json:"lastError,omitempty"`
+}
+
+// 키 로테이션 진행상황 저장소. path 가 주어지면 변경될 때마다 json 파일로 기록해서 재시작 이후에도 이어서 진행할 수 있다
+type RotationStore struct {
+	path      string
+	rotations map[string]Rotation
+	mutex     sync.RWMutex
+}
+
+func NewRotationStore(path string) (*RotationStore, error) {
+	s := &RotationStore{
+		path:      path,
+		rotations: make(map[string]Rotation),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.rotations); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *RotationStore) Get(oldKeyID string) (Rotation, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	rotation, ok := s.rotations[oldKeyID]
+	rotation.Sweeps = append([]Sweep(nil), rotation.Sweeps...)
+	return rotation, ok
+}
+
+func (s *RotationStore) Save(rotation Rotation) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	rotation.Sweeps = append([]Sweep(nil), rotation.Sweeps...)
+	s.rotations[rotation.OldKeyID] = rotation
+	if s.path == "" {
+		return nil
+	}
+	return writeJSON(s.path, s.rotations)
+}
+
+// 특정 단계의 로테이션 목록
+func (s *RotationStore) ListByStep(step string) []Rotation {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var rotations []Rotation
+	for _, rotation := range s.rotations {
+		if rotation.Step == step {
+			rotation.Sweeps = append([]Sweep(nil), rotation.Sweeps...)
+			rotations = append(rotations, rotation)
+		}
+	}
+	return rotations
+}
+
+// 임시 파일에 쓴 뒤 rename 해서 중간에 죽더라도 파일이 깨지지 않도록 한다
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,36 @@
+package tracing
+
+import (
+	"context"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// aws sdk 호출마다 client span 을 만든다 (ex. KMS.Sign).
+// kmsclient 의 재시도는 sdk 바깥에서 일어나기 때문에 시도마다 span 이 하나씩 생긴다.
+// kms.Options.APIOptions 에 추가해서 사용한다
+func WithKMS(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("TracingSpan", func(
+		ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+	) (middleware.InitializeOutput, middleware.Metadata, error) {
+		service, operation := awsmiddleware.GetServiceID(ctx), awsmiddleware.GetOperationName(ctx)
+		ctx, span := otelTracer().Start(ctx, service+"."+operation, trace.WithSpanKind(trace.SpanKindClient))
+		span.SetAttributes(
+			semconv.RPCSystemKey.String("aws-api"),
+			semconv.RPCService(service),
+			semconv.RPCMethod(operation),
+			attribute.String("aws.region", awsmiddleware.GetRegion(ctx)),
+		)
+
+		out, metadata, err := next.HandleInitialize(ctx, in)
+		if requestID, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok {
+			span.SetAttributes(attribute.String("aws.request_id", requestID))
+		}
+		End(span, err)
+		return out, metadata, err
+	}), middleware.After)
+}
@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName  = "kms/wallet"
+	serviceName = "kms-wallet"
+)
+
+type Config struct {
+	Exporter string    // "" 혹은 none(사용 안함), stdout, otlp
+	Endpoint string    // otlp http 수집기 주소 (ex. localhost:4318). 비어있으면 OTEL_EXPORTER_OTLP_* 환경변수를 따른다
+	Writer   io.Writer // stdout exporter 의 출력 (기본값 stdout)
+}
+
+// 전역 TracerProvider 와 전파 방식(W3C traceparent, baggage)을 설정한다.
+// 리턴된 함수로 종료할 때 남은 span 을 내보낸다
+func Init(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch config.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		opts := []stdouttrace.Option{}
+		if config.Writer != nil {
+			opts = append(opts, stdouttrace.WithWriter(config.Writer))
+		}
+		exporter, err = stdouttrace.New(opts...)
+	case "otlp":
+		opts := []otlptracehttp.Option{}
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(config.Endpoint), otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("invalid tracing exporter %v", config.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// 전역 TracerProvider 는 테스트에서 바뀔 수 있기 때문에 매번 가져온다
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otelTracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+func otelTracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// span 을 끝낸다. 에러가 있으면 span 에 기록한다
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// keyID 는 그대로 남기지 않고 해시값의 앞 16자리만 남긴다
+func KeyID(keyID string) attribute.KeyValue {
+	hash := sha256.Sum256([]byte(keyID))
+	return attribute.String("wallet.key_id_hash", hex.EncodeToString(hash[:8]))
+}
+
+func ChainID(chainID *big.Int) attribute.KeyValue {
+	return attribute.String("wallet.chain_id", chainID.String())
+}
+
+// go-ethereum 의 트렌젝션 타입 (types.LegacyTxType ...)
+func TxType(txType uint8) attribute.KeyValue {
+	name, ok := txTypes[txType]
+	if !ok {
+		name = fmt.Sprintf("unknown(%v)", txType)
+	}
+	return attribute.String("wallet.tx.type", name)
+}
+
+var txTypes = map[uint8]string{
+	0: "legacy",
+	1: "access_list",
+	2: "dynamic_fee",
+	3: "blob",
+}
@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"kms/wallet/common/logger"
+	"kms/wallet/common/utils/timeutil"
+	"os"
+	"sync"
+)
+
+var (
+	_audit = &auditLog{}
+)
+
+type Event struct {
+	Time      string `json:"time"`
+	Action    string `json:"action"`
+	KeyID     string `json:"keyID"`
+	Actor     string `json:"actor"`
+	Reason    string `json:"reason"`
+	RequestID string `json:"requestID,omitempty"`
+}
+
+type auditLog struct {
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+	mutex  sync.Mutex
+}
+
+// path 가 비어있으면 audit 이벤트는 logger 로만 남긴다
+func Init(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	_audit.mutex.Lock()
+	defer _audit.mutex.Unlock()
+	_audit.path = path
+	_audit.file = file
+	_audit.writer = bufio.NewWriter(file)
+	return nil
+}
+
+// audit 파일에 계속 기록할 수 있는지 확인한다 (readiness). 파일이 설정되지 않았으면 확인하지 않는다
+func Check() error {
+	_audit.mutex.Lock()
+	defer _audit.mutex.Unlock()
+	if _audit.writer == nil {
+		return nil
+	}
+	if err := _audit.writer.Flush(); err != nil {
+		return err
+	}
+	// 열어둔 파일이 지워졌거나 권한, 파일시스템이 바뀐 경우는 새로 열어봐야 알 수 있다
+	file, err := os.OpenFile(_audit.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func Record(ctx context.Context, action, keyID, actor, reason string) error {
+	event := Event{
+		Time:      timeutil.FormatNow(),
+		Action:    action,
+		KeyID:     keyID,
+		Actor:     actor,
+		Reason:    reason,
+		RequestID: logger.RequestID(ctx),
+	}
+	logger.Info().Ctx(ctx).D("audit", event).W("audit event")
+
+	_audit.mutex.Lock()
+	defer _audit.mutex.Unlock()
+	if _audit.writer == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := _audit.writer.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	// 보안 이벤트는 유실되면 안되기 때문에 바로 디스크에 기록한다
+	return _audit.writer.Flush()
+}
+
+func Flush() error {
+	_audit.mutex.Lock()
+	defer _audit.mutex.Unlock()
+	if _audit.writer == nil {
+		return nil
+	}
+	if err := _audit.writer.Flush(); err != nil {
+		return err
+	}
+	return _audit.file.Sync()
+}
+
+func Close() error {
+	if err := Flush(); err != nil {
+		return err
+	}
+
+	_audit.mutex.Lock()
+	defer _audit.mutex.Unlock()
+	if _audit.file == nil {
+		return nil
+	}
+	err := _audit.file.Close()
+	_audit.file, _audit.writer = nil, nil
+	return err
+}
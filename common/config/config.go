@@ -1,49 +1,161 @@
 package config
 
 import (
-	"log"
-	"os"
-
-	"github.com/joho/godotenv"
+	"time"
 )
 
-type EnvStruct struct {
-	ENV            string
-	PORT           string
-	CHAIN_ID       string
-	AWS_ACCESS_KEY string
-	AWS_SECRET_KEY string
-	AWS_REGION     string
-	Log            bool
+// 서버 설정. 기본값 < 설정 파일(yaml) < 환경변수 < flag 순서로 덮어쓴다 (Load 참고)
+//
+// 각 항목의 yaml 경로는 flag 이름으로도 쓰인다 (ex. server.port -> --server.port).
+// env 태그는 이전 .env 파일과 같은 이름을 사용하고, secret 태그가 붙은 항목은 --print-config 에서 가려진다
+type Config struct {
+	Environment string          `yaml:"env" env:"ENV"` // local, dev, stg, prd
+	Server      ServerConfig    `yaml:"server"`
+	Kms         KmsConfig       `yaml:"kms"`
+	Chains      ChainsConfig    `yaml:"chains"`
+	Policy      PolicyConfig    `yaml:"policy"`
+	Logging     LoggingConfig   `yaml:"logging"`
+	Health      HealthConfig    `yaml:"health"`
+	Jobs        JobsConfig      `yaml:"jobs"`
+	Telemetry   TelemetryConfig `yaml:"telemetry"`
 }
 
-var Env *EnvStruct
+type ServerConfig struct {
+	Port            int                      `yaml:"port" env:"PORT"`
+	RequestTimeout  time.Duration            `yaml:"request_timeout" env:"REQUEST_TIMEOUT"`   // 0 이면 제한하지 않는다
+	RequestTimeouts map[string]time.Duration `yaml:"request_timeouts" env:"REQUEST_TIMEOUTS"` // path prefix 별 제한시간 (ex. "/api/sign/txns=2m,/api/accounts/batch=1m")
+	ShutdownTimeout time.Duration            `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	RateLimit       int                      `yaml:"rate_limit" env:"RATE_LIMIT"`   // ip 별 분당 요청 수
+	ErrorCodes      string                   `yaml:"error_codes" env:"ERROR_CODES"` // standard, legacy
+}
 
-func Init(envPath string) {
-	if Env == nil {
-		Env = new(EnvStruct)
-	}
+// 기본 kms backend 설정. 다른 region, 계정의 키도 사용하면 Backends 에 추가한다
+type KmsConfig struct {
+	Region           string                `yaml:"region" env:"AWS_REGION"`
+	Account          string                `yaml:"account" env:"AWS_ACCOUNT_ID"` // 비어있으면 key ARN 의 region 만 비교해서 backend 를 찾는다
+	AccessKey        string                `yaml:"access_key" env:"AWS_ACCESS_KEY" secret:"true"`
+	SecretKey        string                `yaml:"secret_key" env:"AWS_SECRET_KEY" secret:"true"` // access_key, secret_key 가 없으면 기본 credential chain 을 사용한다
+	AssumeRoleARN    string                `yaml:"assume_role_arn" env:"AWS_ASSUME_ROLE_ARN"`
+	ExternalID       string                `yaml:"external_id" env:"AWS_EXTERNAL_ID" secret:"true"`
+	SessionName      string                `yaml:"session_name" env:"AWS_ROLE_SESSION_NAME"`
+	Endpoint         string                `yaml:"endpoint" env:"KMS_ENDPOINT"`     // 비어있으면 region 의 aws kms. local 환경의 기본값은 http://localhost:8080
+	StsEndpoint      string                `yaml:"sts_endpoint" env:"STS_ENDPOINT"` // 비어있으면 region 의 sts
+	Timeout          time.Duration         `yaml:"timeout" env:"KMS_TIMEOUT"`
+	MaxRetries       int                   `yaml:"max_retries" env:"KMS_MAX_RETRIES"`
+	BreakerThreshold int                   `yaml:"breaker_threshold" env:"KMS_BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration         `yaml:"breaker_cooldown" env:"KMS_BREAKER_COOLDOWN"`
+	PubKeyCache      PubKeyCacheConfig     `yaml:"pubkey_cache"`
+	Backends         map[string]KmsBackend `yaml:"backends" env:"KMS_BACKENDS"`               // 이름별 추가 backend (ex. "dr=region:us-west-2|account:444455556666")
+	KeyBackends      map[string]string     `yaml:"key_backends" env:"KMS_KEY_BACKENDS"`       // keyID 별 backend 이름. 기본 backend 는 "default"
+	ReplicaRegions   []string              `yaml:"replica_regions" env:"KMS_REPLICA_REGIONS"` // 다중 리전 키를 복제하고 서명을 넘길 region. 같은 region 의 backend 가 있어야 한다
+	KeyPolicy        KeyPolicyConfig       `yaml:"key_policy"`
+}
 
-	if err := godotenv.Load(envPath); err != nil {
-		log.Fatal(err)
-	}
-	Env.ENV = getEnv("ENV", true)
-	Env.PORT = getEnv("PORT", true)
-	Env.CHAIN_ID = getEnv("CHAIN_ID", true)
-	Env.AWS_ACCESS_KEY = getEnv("AWS_ACCESS_KEY", true)
-	Env.AWS_SECRET_KEY = getEnv("AWS_SECRET_KEY", true)
-	Env.AWS_REGION = getEnv("AWS_REGION", true)
-	Env.Log = true
-
-	// envLog, _ := json.MarshalIndent(Env, "", "\t")
-	// fmt.Println(string(envLog))
-}
-
-func getEnv(key string, required bool) string {
-	val, success := os.LookupEnv(key)
-	if required && !success {
-		log.Fatalf("Required value of %s dosen't exist", key)
-	}
+// 새 키(생성, 주입)에 적용할 key policy.
+// 템플릿은 json 이고 ${ACCOUNT_ID}, ${SERVICE_ROLE_ARN}, ${ADMIN_ROLE_ARN} 를 채워서 사용한다 (Render 참고)
+type KeyPolicyConfig struct {
+	Template       string            `yaml:"template" env:"KMS_KEY_POLICY"` // 새 키에 적용할 템플릿 이름. 비어있으면 kms 의 기본 key policy
+	ServiceRoleARN string            `yaml:"service_role_arn" env:"KMS_SERVICE_ROLE_ARN"`
+	AdminRoleARN   string            `yaml:"admin_role_arn" env:"KMS_ADMIN_ROLE_ARN"`
+	Templates      map[string]string `yaml:"templates"` // 이름별 템플릿. json 에 콤마가 있어서 설정 파일로만 지정한다
+}
+
+// 추가 kms backend. 자격증명, external_id, session_name 은 기본 backend 와 같은 것을 사용한다.
+// 환경변수와 flag 에서는 "<항목>:<값>" 을 '|' 로 구분한다
+type KmsBackend struct {
+	Region        string `yaml:"region"`
+	Account       string `yaml:"account,omitempty"`
+	AssumeRoleARN string `yaml:"assume_role_arn,omitempty"` // 비어있으면 기본 backend 의 role
+	Endpoint      string `yaml:"endpoint,omitempty"`
+}
+
+type PubKeyCacheConfig struct {
+	Size   int           `yaml:"size" env:"PUBKEY_CACHE_SIZE"`
+	TTL    time.Duration `yaml:"ttl" env:"PUBKEY_CACHE_TTL"` // 0 이면 만료되지 않는다
+	Path   string        `yaml:"path" env:"PUBKEY_CACHE_PATH"`
+	WarmUp []string      `yaml:"warmup" env:"PUBKEY_WARMUP"` // 시작할 때 미리 캐싱할 keyID 목록
+}
+
+type ChainsConfig struct {
+	ChainID     uint64   `yaml:"chain_id" env:"CHAIN_ID"`
+	RPCURL      string   `yaml:"rpc_url" env:"RPC_URL" secret:"true"` // 비어있으면 로테이션 api 는 비활성화된다
+	SweepTokens []string `yaml:"sweep_tokens" env:"SWEEP_TOKENS"`     // 로테이션할 때 옮길 erc20 토큰 주소
+}
+
+type PolicyConfig struct {
+	AuditLogPath      string `yaml:"audit_log_path" env:"AUDIT_LOG_PATH"`
+	RotationStatePath string `yaml:"rotation_state_path" env:"ROTATION_STATE_PATH"`
+	FreezeStatePath   string `yaml:"freeze_state_path" env:"FREEZE_STATE_PATH"` // 비어있으면 동결 기록이 재시작할 때 사라진다
+}
+
+type LoggingConfig struct {
+	Requests     bool                   `yaml:"requests" env:"LOG_REQUESTS"` // 요청 로그를 남길지 여부
+	RedactFields []string               `yaml:"redact_fields" env:"LOG_REDACT_FIELDS"`
+	RedactRoutes map[string]RedactRoute `yaml:"redact_routes" env:"LOG_REDACT_ROUTES"` // (ex. "/api/sign=-resBody|serializedTxn")
+	MaxValueLen  int                    `yaml:"max_value_len" env:"LOG_MAX_VALUE_LEN"` // 0 이면 자르지 않는다
+}
+
+// path prefix 별 로그 규칙. 환경변수와 flag 에서는 '|' 로 구분하고, '-' 로 시작하면 Drop, 아니면 Mask 로 쓴다
+type RedactRoute struct {
+	Drop []string `yaml:"drop,omitempty"` // 로그에서 제거할 항목 (reqBody, resBody, queryParams, reqHeader)
+	Mask []string `yaml:"mask,omitempty"` // 해당 route 에서만 추가로 가릴 필드
+}
+
+type HealthConfig struct {
+	CanaryKeyID string        `yaml:"canary_key_id" env:"HEALTH_CANARY_KEY_ID"`
+	RPCURLs     []string      `yaml:"rpc_urls" env:"HEALTH_RPC_URLS" secret:"true"` // 비어있으면 chains.rpc_url
+	Timeout     time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT"`
+}
 
-	return val
+type JobsConfig struct {
+	Store         string `yaml:"store" env:"JOB_STORE"` // memory, sqlite
+	DBPath        string `yaml:"db_path" env:"JOB_DB_PATH"`
+	Workers       int    `yaml:"workers" env:"JOB_WORKERS"`
+	WebhookSecret string `yaml:"webhook_secret" env:"JOB_WEBHOOK_SECRET" secret:"true"`
+}
+
+type TelemetryConfig struct {
+	MetricsPerKey   bool   `yaml:"metrics_per_key" env:"METRICS_PER_KEY"`
+	TracingExporter string `yaml:"tracing_exporter" env:"TRACING_EXPORTER"` // none, stdout, otlp
+	TracingEndpoint string `yaml:"tracing_endpoint" env:"TRACING_ENDPOINT"`
+}
+
+var Env *Config
+
+// kms 설정의 최상위 항목으로 만드는 backend 이름
+const DefaultBackend = "default"
+
+func Default() *Config {
+	return &Config{
+		Environment: "local",
+		Server: ServerConfig{
+			Port:            7777,
+			RequestTimeout:  30 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+			RateLimit:       1000,
+			ErrorCodes:      "standard",
+		},
+		Kms: KmsConfig{
+			SessionName:      "kms-wallet",
+			Timeout:          5 * time.Second,
+			MaxRetries:       3,
+			BreakerThreshold: 5,
+			BreakerCooldown:  30 * time.Second,
+			PubKeyCache:      PubKeyCacheConfig{Size: 10000},
+		},
+		Logging: LoggingConfig{
+			Requests:    true,
+			MaxValueLen: 512,
+		},
+		Health: HealthConfig{
+			Timeout: 3 * time.Second,
+		},
+		Jobs: JobsConfig{
+			Store:   "memory",
+			Workers: 4,
+		},
+		Telemetry: TelemetryConfig{
+			TracingExporter: "none",
+		},
+	}
 }
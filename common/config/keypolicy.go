@@ -0,0 +1,42 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+var placeholderPattern = regexp.MustCompile(`\$\{([A-Z_]+)\}`)
+
+// name 템플릿의 placeholder 를 채운 key policy 를 리턴한다.
+// 템플릿이 사용하는 값이 비어있거나 알 수 없는 placeholder 가 있으면 에러를 리턴한다
+func (policy KeyPolicyConfig) Render(name string, accountID string) (string, error) {
+	template, ok := policy.Templates[name]
+	if !ok {
+		return "", fmt.Errorf("unknown key policy template '%v'", name)
+	}
+	values := map[string]string{
+		"ACCOUNT_ID":       accountID,
+		"SERVICE_ROLE_ARN": policy.ServiceRoleARN,
+		"ADMIN_ROLE_ARN":   policy.AdminRoleARN,
+	}
+
+	var err error
+	rendered := placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		key := placeholderPattern.FindStringSubmatch(placeholder)[1]
+		value, ok := values[key]
+		if !ok {
+			err = fmt.Errorf("key policy template '%v': unknown placeholder %v", name, placeholder)
+		} else if value == "" && err == nil {
+			err = fmt.Errorf("key policy template '%v': %v is not set", name, placeholder)
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	if !json.Valid([]byte(rendered)) {
+		return "", fmt.Errorf("key policy template '%v': invalid json", name)
+	}
+	return rendered, nil
+}
@@ -0,0 +1,352 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// 설정 항목 하나. path 는 yaml 경로이자 flag 이름이다 (ex. server.port)
+type field struct {
+	path   string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// flag 로 받은 값은 환경변수까지 적용한 뒤에 덮어쓰기 위해 문자열로 보관한다
+type flagValue struct {
+	isBool bool
+	value  string
+}
+
+func (f *flagValue) String() string     { return f.value }
+func (f *flagValue) Set(s string) error { f.value = s; return nil }
+func (f *flagValue) IsBoolFlag() bool   { return f.isBool }
+
+// args(os.Args[1:]) 의 flag 와 환경변수, 설정 파일을 읽어서 설정을 만든다.
+//
+//	--env          환경 (local, dev, stg, prd). 없으면 ENV 환경변수, 설정 파일의 env, 그것도 없으면 local
+//	--env-file     환경변수 파일. 없으면 env/.env.<env> 가 있을 때만 읽는다 (이미 있는 환경변수는 덮어쓰지 않는다)
+//	--config       yaml 설정 파일. 없으면 CONFIG_FILE 환경변수
+//	--print-config 설정을 출력하고 종료한다 (printConfig 로 리턴)
+//	--<yaml 경로>  각 설정 항목 (ex. --server.port=8080, --kms.region=ap-northeast-2)
+//
+// 검증에 실패하면 모든 에러를 합쳐서 설정과 같이 리턴한다
+func Load(args []string) (cfg *Config, printConfig bool, err error) {
+	fs := flag.NewFlagSet("wallet", flag.ContinueOnError)
+	var (
+		envFile    = fs.String("env-file", "", "env file (default env/.env.<env> if it exists)")
+		configFile = fs.String("config", "", "yaml config file (env CONFIG_FILE)")
+		printFlag  = fs.Bool("print-config", false, "print the resolved config with secrets redacted and exit")
+	)
+	flagValues := make(map[string]*flagValue)
+	for _, f := range fields(Default()) {
+		value := &flagValue{isBool: f.value.Kind() == reflect.Bool}
+		usage := f.path
+		if f.env != "" {
+			usage = fmt.Sprintf("%v (env %v)", f.path, f.env)
+		}
+		fs.Var(value, f.path, usage)
+		flagValues[f.path] = value
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, false, err
+	}
+	if fs.NArg() > 0 {
+		return nil, false, fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	var setFlags []string
+	fs.Visit(func(f *flag.Flag) {
+		if _, ok := flagValues[f.Name]; ok {
+			setFlags = append(setFlags, f.Name)
+		}
+	})
+
+	// env 파일을 고르기 전에 환경을 정한다. flag, ENV 가 없으면 설정 파일의 env
+	env := flagValues["env"].value
+	if env == "" {
+		env = os.Getenv("ENV")
+	}
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	if env == "" && *configFile != "" {
+		fileEnv, err := readFileEnv(*configFile)
+		if err != nil {
+			return nil, false, err
+		}
+		env = fileEnv
+	}
+	if env == "" {
+		env = "local"
+	}
+	if *envFile != "" {
+		if err := godotenv.Load(*envFile); err != nil {
+			return nil, false, err
+		}
+	} else if path := "env/.env." + env; fileExists(path) {
+		if err := godotenv.Load(path); err != nil {
+			return nil, false, err
+		}
+	}
+
+	cfg = Default()
+	cfg.Environment = env
+
+	// env 파일에서 지정한 설정 파일
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, false, err
+		}
+	}
+
+	var loadErrs []error
+	byPath := make(map[string]field)
+	for _, f := range fields(cfg) {
+		byPath[f.path] = f
+		if f.env == "" {
+			continue
+		}
+		// 빈 값은 설정하지 않은 것으로 본다
+		if value := os.Getenv(f.env); value != "" {
+			if err := setValue(f.value, value); err != nil {
+				loadErrs = append(loadErrs, fmt.Errorf("%v (env %v): %w", f.path, f.env, err))
+			}
+		}
+	}
+	for _, path := range setFlags {
+		if err := setValue(byPath[path].value, flagValues[path].value); err != nil {
+			loadErrs = append(loadErrs, fmt.Errorf("%v (flag): %w", path, err))
+		}
+	}
+
+	// 설정 파일이나 env 파일이 env 파일을 고른 환경과 다른 환경을 지정한 경우
+	if cfg.Environment != env {
+		loadErrs = append(loadErrs, fmt.Errorf("env: '%v' conflicts with '%v' that the env file was chosen for", cfg.Environment, env))
+	}
+	// local 환경은 따로 지정하지 않으면 로컬 kms 를 사용한다
+	if cfg.Environment == "local" && cfg.Kms.Endpoint == "" {
+		cfg.Kms.Endpoint = "http://localhost:8080"
+	}
+	if err := cfg.Validate(); err != nil {
+		loadErrs = append(loadErrs, err)
+	}
+	return cfg, *printFlag, errors.Join(loadErrs...)
+}
+
+// 정의되지 않은 항목이 있으면 에러를 리턴한다
+func (cfg *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %v: %w", path, err)
+	}
+	return nil
+}
+
+// env 파일을 고르기 위해 설정 파일의 env 만 읽는다. 나머지 항목은 loadFile 에서 검사한다
+func readFileEnv(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var file struct {
+		Environment string `yaml:"env"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return "", fmt.Errorf("config file %v: %w", path, err)
+	}
+	return file.Environment, nil
+}
+
+// secret 항목을 가린 설정을 yaml 로 출력한다
+func (cfg *Config) Print(w io.Writer) error {
+	masked := *cfg
+	for _, f := range fields(&masked) {
+		if !f.secret {
+			continue
+		}
+		switch f.value.Kind() {
+		case reflect.String:
+			if f.value.String() != "" {
+				f.value.SetString(redacted)
+			}
+		case reflect.Slice:
+			list := make([]string, f.value.Len())
+			for i := range list {
+				list[i] = redacted
+			}
+			f.value.Set(reflect.ValueOf(list))
+		}
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&masked); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// yaml 경로 순서대로 모든 설정 항목을 리턴
+func fields(cfg *Config) []field {
+	var list []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		for i := 0; i < v.NumField(); i++ {
+			structField := v.Type().Field(i)
+			name, _, _ := strings.Cut(structField.Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			path := prefix + name
+			if structField.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), path+".")
+				continue
+			}
+			list = append(list, field{
+				path:   path,
+				env:    structField.Tag.Get("env"),
+				secret: structField.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return list
+}
+
+// 환경변수와 flag 의 문자열 값을 항목 타입에 맞게 변환한다.
+// 목록은 콤마로 구분하고 (ex. "a,b"), map 은 "key=value" 를 콤마로 구분한다
+func setValue(v reflect.Value, s string) error {
+	if unmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		duration, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(duration))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(parsed)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(parsed))
+	case reflect.Uint64:
+		parsed, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(parsed)
+	case reflect.Slice:
+		v.Set(reflect.ValueOf(splitList(s)))
+	case reflect.Map:
+		entries := reflect.MakeMap(v.Type())
+		for _, entry := range splitList(s) {
+			key, value, ok := strings.Cut(entry, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid entry '%v' (expected key=value)", entry)
+			}
+			parsed := reflect.New(v.Type().Elem()).Elem()
+			if err := setValue(parsed, value); err != nil {
+				return fmt.Errorf("invalid entry '%v': %w", entry, err)
+			}
+			entries.SetMapIndex(reflect.ValueOf(key), parsed)
+		}
+		v.Set(entries)
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+// "-resBody|serializedTxn" 형식
+func (route *RedactRoute) UnmarshalText(text []byte) error {
+	*route = RedactRoute{}
+	for _, item := range strings.Split(string(text), "|") {
+		if item = strings.TrimSpace(item); item == "" || item == "-" {
+			return fmt.Errorf("invalid redact rule '%s'", text)
+		}
+		if dropped, ok := strings.CutPrefix(item, "-"); ok {
+			route.Drop = append(route.Drop, dropped)
+		} else {
+			route.Mask = append(route.Mask, item)
+		}
+	}
+	return nil
+}
+
+// "region:us-west-2|account:444455556666|assume_role_arn:arn:aws:iam::444455556666:role/wallet" 형식
+func (backend *KmsBackend) UnmarshalText(text []byte) error {
+	*backend = KmsBackend{}
+	for _, item := range strings.Split(string(text), "|") {
+		key, value, _ := strings.Cut(strings.TrimSpace(item), ":")
+		switch key {
+		case "region":
+			backend.Region = value
+		case "account":
+			backend.Account = value
+		case "assume_role_arn":
+			backend.AssumeRoleARN = value
+		case "endpoint":
+			backend.Endpoint = value
+		default:
+			return fmt.Errorf("invalid kms backend item '%v'", item)
+		}
+	}
+	return nil
+}
+
+// 콤마로 구분된 값 목록을 빈 항목을 제외하고 리턴
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// 모든 항목을 확인하고 잘못된 항목의 에러를 합쳐서 리턴한다
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, path string, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%v: %v", path, fmt.Sprintf(format, args...)))
+		}
+	}
+	oneOf := func(value string, path string, values ...string) {
+		check(slices.Contains(values, value), path, "must be one of %v (got '%v')", values, value)
+	}
+
+	oneOf(cfg.Environment, "env", "local", "dev", "stg", "prd")
+
+	server := cfg.Server
+	check(server.Port > 0 && server.Port <= 65535, "server.port", "must be between 1 and 65535 (got %v)", server.Port)
+	check(server.RequestTimeout >= 0, "server.request_timeout", "must not be negative")
+	for prefix, timeout := range server.RequestTimeouts {
+		check(strings.HasPrefix(prefix, "/"), "server.request_timeouts", "prefix '%v' must start with /", prefix)
+		check(timeout > 0, "server.request_timeouts", "timeout of '%v' must be positive", prefix)
+	}
+	check(server.ShutdownTimeout > 0, "server.shutdown_timeout", "must be positive")
+	check(server.RateLimit > 0, "server.rate_limit", "must be positive")
+	oneOf(server.ErrorCodes, "server.error_codes", "standard", "legacy")
+
+	kms := cfg.Kms
+	check(kms.Region != "", "kms.region", "required")
+	check((kms.AccessKey == "") == (kms.SecretKey == ""), "kms.access_key", "must be set together with kms.secret_key")
+	if kms.AssumeRoleARN != "" {
+		check(validRoleARN(kms.AssumeRoleARN), "kms.assume_role_arn", "invalid role arn '%v'", kms.AssumeRoleARN)
+		check(kms.SessionName != "", "kms.session_name", "required with kms.assume_role_arn")
+	} else {
+		check(kms.ExternalID == "", "kms.external_id", "requires kms.assume_role_arn")
+	}
+	check(kms.Account == "" || validAccount(kms.Account), "kms.account", "must be a 12 digit account id (got '%v')", kms.Account)
+	for name, backend := range kms.Backends {
+		path := "kms.backends." + name
+		check(name != DefaultBackend, path, "'%v' is reserved for the default backend", name)
+		check(backend.Region != "", path+".region", "required")
+		check(backend.Account == "" || validAccount(backend.Account), path+".account", "must be a 12 digit account id (got '%v')", backend.Account)
+		check(backend.AssumeRoleARN == "" || validRoleARN(backend.AssumeRoleARN), path+".assume_role_arn", "invalid role arn '%v'", backend.AssumeRoleARN)
+	}
+	for keyID, name := range kms.KeyBackends {
+		_, ok := kms.Backends[name]
+		check(ok || name == DefaultBackend, "kms.key_backends", "unknown backend '%v' for key '%v'", name, keyID)
+	}
+	for _, region := range kms.ReplicaRegions {
+		check(region != kms.Region, "kms.replica_regions", "'%v' is the region of the default backend", region)
+		check(slices.ContainsFunc(maps.Values(kms.Backends), func(backend KmsBackend) bool {
+			return backend.Region == region && (backend.Account == "" || kms.Account == "" || backend.Account == kms.Account)
+		}), "kms.replica_regions", "no kms backend in the same account for '%v'", region)
+	}
+	keyPolicy := kms.KeyPolicy
+	check(keyPolicy.ServiceRoleARN == "" || validRoleARN(keyPolicy.ServiceRoleARN), "kms.key_policy.service_role_arn", "invalid role arn '%v'", keyPolicy.ServiceRoleARN)
+	check(keyPolicy.AdminRoleARN == "" || validRoleARN(keyPolicy.AdminRoleARN), "kms.key_policy.admin_role_arn", "invalid role arn '%v'", keyPolicy.AdminRoleARN)
+	_, ok := keyPolicy.Templates[keyPolicy.Template]
+	check(keyPolicy.Template == "" || ok, "kms.key_policy.template", "unknown template '%v'", keyPolicy.Template)
+	for name := range keyPolicy.Templates {
+		// 계정은 시작할 때 aws 에서 확인할 수 있기 때문에 임의의 값으로 확인한다
+		_, err := keyPolicy.Render(name, "000000000000")
+		check(err == nil, "kms.key_policy.templates", "%v", err)
+	}
+	check(kms.Timeout > 0, "kms.timeout", "must be positive")
+	check(kms.MaxRetries >= 0, "kms.max_retries", "must not be negative")
+	check(kms.BreakerThreshold > 0, "kms.breaker_threshold", "must be positive")
+	check(kms.BreakerCooldown > 0, "kms.breaker_cooldown", "must be positive")
+	check(kms.PubKeyCache.Size > 0, "kms.pubkey_cache.size", "must be positive")
+	check(kms.PubKeyCache.TTL >= 0, "kms.pubkey_cache.ttl", "must not be negative")
+
+	chains := cfg.Chains
+	check(chains.ChainID > 0, "chains.chain_id", "required")
+	for _, token := range chains.SweepTokens {
+		check(common.IsHexAddress(token), "chains.sweep_tokens", "invalid address '%v'", token)
+	}
+
+	logging := cfg.Logging
+	check(logging.MaxValueLen >= 0, "logging.max_value_len", "must not be negative")
+	for prefix := range logging.RedactRoutes {
+		check(strings.HasPrefix(prefix, "/"), "logging.redact_routes", "prefix '%v' must start with /", prefix)
+	}
+
+	check(cfg.Health.Timeout > 0, "health.timeout", "must be positive")
+
+	jobs := cfg.Jobs
+	oneOf(jobs.Store, "jobs.store", "memory", "sqlite")
+	check(jobs.Store != "sqlite" || jobs.DBPath != "", "jobs.db_path", "required for sqlite job store")
+	check(jobs.Workers > 0, "jobs.workers", "must be positive")
+
+	oneOf(cfg.Telemetry.TracingExporter, "telemetry.tracing_exporter", "none", "stdout", "otlp")
+
+	return errors.Join(errs...)
+}
+
+func validRoleARN(arn string) bool {
+	return strings.HasPrefix(arn, "arn:") && strings.Contains(arn, ":role/")
+}
+
+func validAccount(account string) bool {
+	if len(account) != 12 {
+		return false
+	}
+	for _, c := range account {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
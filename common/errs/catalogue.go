@@ -0,0 +1,82 @@
+package errs
+
+import "errors"
+
+// 클라이언트에 노출되는 에러 종류.
+// Code 는 바뀌지 않는 문자열이므로 클라이언트는 메세지가 아닌 Code 로 분기한다
+type Kind struct {
+	Code      string // ex. KEY_NOT_FOUND
+	Status    int    // http status
+	Retryable bool   // 같은 요청을 다시 보내면 성공할 수 있는지
+}
+
+var (
+	KindBadRequest      = Kind{"BAD_REQUEST", 400, false}
+	KindRouteNotFound   = Kind{"ROUTE_NOT_FOUND", 404, false}
+	KindKeyNotFound     = Kind{"KEY_NOT_FOUND", 404, false}
+	KindKeyInvalid      = Kind{"KEY_INVALID_STATE", 409, false}
+	KindMarkerInvalid   = Kind{"MARKER_INVALID", 400, false}
+	KindTxnInvalid      = Kind{"TXN_INVALID", 422, false}
+	KindKeyFrozen       = Kind{"KEY_FROZEN", 423, false}
+	KindAddressMismatch = Kind{"ADDRESS_MISMATCH", 422, false}
+	KindRequestTimeout  = Kind{"REQUEST_TIMEOUT", 408, true}
+	KindJobNotFound     = Kind{"JOB_NOT_FOUND", 404, false}
+	KindRequestCanceled = Kind{"REQUEST_CANCELED", 499, false}
+
+	KindKeyDisabled          = Kind{"KEY_DISABLED", 409, false}
+	KindKeyUsageInvalid      = Kind{"KEY_USAGE_INVALID", 422, false}
+	KindOperationUnsupported = Kind{"OPERATION_UNSUPPORTED", 422, false}
+	KindKeyMaterialIncorrect = Kind{"KEY_MATERIAL_INCORRECT", 422, false}
+	KindImportTokenExpired   = Kind{"IMPORT_TOKEN_EXPIRED", 422, false}
+	KindImportTokenInvalid   = Kind{"IMPORT_TOKEN_INVALID", 422, false}
+	KindAlreadyExists        = Kind{"ALREADY_EXISTS", 409, false}
+	KindKmsLimitExceeded     = Kind{"KMS_LIMIT_EXCEEDED", 429, false} // 요청 한도가 아닌 키 개수 등의 할당량 초과
+
+	KindInternal  = Kind{"INTERNAL_ERROR", 500, false}
+	KindUnhandled = Kind{"UNHANDLED_ERROR", 500, false}
+
+	KindKmsError            = Kind{"KMS_ERROR", 502, true}
+	KindKmsUnavailable      = Kind{"KMS_UNAVAILABLE", 503, true}
+	KindKmsThrottled        = Kind{"KMS_THROTTLED", 429, true}
+	KindKmsAccessDenied     = Kind{"KMS_ACCESS_DENIED", 502, false} // 서버의 aws 자격증명 혹은 권한 문제
+	KindKeyUnavailable      = Kind{"KEY_UNAVAILABLE", 503, true}
+	KindKeyStoreUnavailable = Kind{"KEY_STORE_UNAVAILABLE", 503, false}
+)
+
+// 전체 에러 종류 (문서화, 테스트용)
+var Catalogue = []Kind{
+	KindBadRequest, KindRouteNotFound, KindKeyNotFound, KindKeyInvalid, KindMarkerInvalid, KindTxnInvalid,
+	KindKeyFrozen, KindAddressMismatch, KindRequestTimeout, KindJobNotFound, KindRequestCanceled,
+	KindKeyDisabled, KindKeyUsageInvalid, KindOperationUnsupported, KindKeyMaterialIncorrect,
+	KindImportTokenExpired, KindImportTokenInvalid, KindAlreadyExists, KindKmsLimitExceeded,
+	KindInternal, KindUnhandled, KindKmsError, KindKmsUnavailable, KindKmsThrottled,
+	KindKmsAccessDenied, KindKeyUnavailable, KindKeyStoreUnavailable,
+}
+
+// true 이면 이전처럼 Errs 의 숫자 코드(402, 600 ...)를 http status 로 응답한다 (ERROR_CODES=legacy)
+var LegacyStatus bool
+
+// 응답에 사용할 http status
+func (e *CusErr) Status() int {
+	if LegacyStatus || e.Kind.Status == 0 {
+		return e.Code
+	}
+	return e.Kind.Status
+}
+
+// 에러 종류와 코드는 유지한 채로 응답에 포함될 부가 정보를 덧붙인다
+func WithDetails(err error, details map[string]any) error {
+	var customErr *CusErr
+	if !errors.As(err, &customErr) {
+		return err
+	}
+	detailed := *customErr
+	detailed.Details = make(map[string]any, len(customErr.Details)+len(details))
+	for k, v := range customErr.Details {
+		detailed.Details[k] = v
+	}
+	for k, v := range details {
+		detailed.Details[k] = v
+	}
+	return &detailed
+}
@@ -1,49 +1,81 @@
 package errs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime"
-	"strings"
 
-	awsHttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
-	awsTypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
 )
 
 type CusErr struct {
-	Code  int
-	Type  string
-	Inner error
-	Trace string
-	Func  string
+	Code    int // 이전 숫자 코드 (Errs)
+	Type    string
+	Kind    Kind
+	Details map[string]any // 응답에 포함되는 부가 정보 (ex. keyID)
+	Inner   error
+	Trace   string
+	Func    string
 }
 
 func (e *CusErr) Error() string {
 	return e.Type
 }
 
+// errors.Is/As 로 원인이 된 aws 에러를 확인할 수 있도록 한다 (ex. IsThrottling)
+func (e *CusErr) Unwrap() error {
+	return e.Inner
+}
+
 type err struct {
 	Code int
 	Type string
 }
 
 var Errs = map[string]err{
-	"BadRequestErr":    {400, "bad request error"},
-	"KeyIdNotFoundErr": {402, "keyID not found"},
-	"InvalidKeyErr":    {403, "key is invalid"},
-	"InvalidMarkerErr": {404, "marker is invalid"},
-	"InvalidTxnErr":    {405, "serialized transaction is invalid"},
+	"BadRequestErr":           {400, "bad request error"},
+	"KeyIdNotFoundErr":        {402, "keyID not found"},
+	"InvalidKeyErr":           {403, "key is invalid"},
+	"InvalidMarkerErr":        {404, "marker is invalid"},
+	"InvalidTxnErr":           {405, "serialized transaction is invalid"},
+	"FrozenKeyErr":            {406, "key is frozen"},
+	"AddressMismatchErr":      {407, "address does not match expected address"},
+	"RequestTimeoutErr":       {408, "request deadline exceeded"},
+	"JobNotFoundErr":          {409, "job not found"},
+	"KeyDisabledErr":          {410, "key is disabled"},
+	"InvalidKeyUsageErr":      {411, "key usage or spec does not support the operation"},
+	"UnsupportedOperationErr": {412, "operation is not supported for the key"},
+	"IncorrectKeyMaterialErr": {413, "key material is incorrect"},
+	"ImportTokenExpiredErr":   {414, "import token has expired"},
+	"InvalidImportTokenErr":   {415, "import token is invalid"},
+	"AlreadyExistsErr":        {416, "resource already exists"},
+	"KmsLimitExceededErr":     {417, "aws_kms quota exceeded"},
+	"RequestCanceledErr":      {499, "request canceled"},
 
 	"InternalServerErr":  {500, "internal server error"},
 	"UnhandledServerErr": {501, "unhandled server error"},
 
-	"UnhandledAwsKmsErr": {600, "unhandled aws_kms error"},
+	"UnhandledAwsKmsErr":     {600, "unhandled aws_kms error"},
+	"KmsUnavailableErr":      {601, "aws_kms is unavailable"},
+	"KmsThrottlingErr":       {602, "aws_kms request is throttled"},
+	"KmsAccessDeniedErr":     {603, "aws_kms access denied"},
+	"KeyUnavailableErr":      {604, "key is unavailable"},
+	"KeyStoreUnavailableErr": {605, "custom key store is unavailable"},
 }
 
+var (
+	// kms 가 장애 상태라고 판단되어 호출하지 않고 바로 실패시킨 경우 (circuit breaker open)
+	ErrCircuitOpen = errors.New("kms circuit breaker is open")
+	// 요청의 deadline 이 아니라 kms 호출 한번의 제한시간을 넘긴 경우
+	ErrKmsTimeout = errors.New("kms call timed out")
+)
+
 func BadRequestErr(err error) error {
 	return &CusErr{
 		Code:  Errs["BadRequestErr"].Code,
 		Type:  Errs["BadRequestErr"].Type,
+		Kind:  KindBadRequest,
 		Inner: err,
 	}
 }
@@ -52,6 +84,7 @@ func KeyIdNotFoundErr(err error) error {
 	return &CusErr{
 		Code:  Errs["KeyIdNotFoundErr"].Code,
 		Type:  Errs["KeyIdNotFoundErr"].Type,
+		Kind:  KindKeyNotFound,
 		Inner: err,
 	}
 }
@@ -60,6 +93,7 @@ func InvalidKeyErr(err error) error {
 	return &CusErr{
 		Code:  Errs["InvalidKeyErr"].Code,
 		Type:  Errs["InvalidKeyErr"].Type,
+		Kind:  KindKeyInvalid,
 		Inner: err,
 	}
 }
@@ -68,6 +102,7 @@ func InvalidMarkerErr(err error) error {
 	return &CusErr{
 		Code:  Errs["InvalidMarkerErr"].Code,
 		Type:  Errs["InvalidMarkerErr"].Type,
+		Kind:  KindMarkerInvalid,
 		Inner: err,
 	}
 }
@@ -76,6 +111,52 @@ func InvalidTxnErr(err error) error {
 	return &CusErr{
 		Code:  Errs["InvalidTxnErr"].Code,
 		Type:  Errs["InvalidTxnErr"].Type,
+		Kind:  KindTxnInvalid,
+		Inner: err,
+	}
+}
+
+func FrozenKeyErr(err error) error {
+	return &CusErr{
+		Code:  Errs["FrozenKeyErr"].Code,
+		Type:  Errs["FrozenKeyErr"].Type,
+		Kind:  KindKeyFrozen,
+		Inner: err,
+	}
+}
+
+func AddressMismatchErr(err error) error {
+	return &CusErr{
+		Code:  Errs["AddressMismatchErr"].Code,
+		Type:  Errs["AddressMismatchErr"].Type,
+		Kind:  KindAddressMismatch,
+		Inner: err,
+	}
+}
+
+func RequestTimeoutErr(err error) error {
+	return &CusErr{
+		Code:  Errs["RequestTimeoutErr"].Code,
+		Type:  Errs["RequestTimeoutErr"].Type,
+		Kind:  KindRequestTimeout,
+		Inner: err,
+	}
+}
+
+func RequestCanceledErr(err error) error {
+	return &CusErr{
+		Code:  Errs["RequestCanceledErr"].Code,
+		Type:  Errs["RequestCanceledErr"].Type,
+		Kind:  KindRequestCanceled,
+		Inner: err,
+	}
+}
+
+func JobNotFoundErr(err error) error {
+	return &CusErr{
+		Code:  Errs["JobNotFoundErr"].Code,
+		Type:  Errs["JobNotFoundErr"].Type,
+		Kind:  KindJobNotFound,
 		Inner: err,
 	}
 }
@@ -88,6 +169,7 @@ func InternalServerErr(err error) error {
 	return &CusErr{
 		Code:  Errs["InternalServerErr"].Code,
 		Type:  Errs["InternalServerErr"].Type,
+		Kind:  KindInternal,
 		Inner: err,
 		Trace: trace,
 		Func:  funcs,
@@ -102,49 +184,163 @@ func UnhandledAwsKmsErr(err error) error {
 	return &CusErr{
 		Code:  Errs["UnhandledAwsKmsErr"].Code,
 		Type:  Errs["UnhandledAwsKmsErr"].Type,
+		Kind:  KindKmsError,
 		Inner: err,
 		Func:  funcs,
 		Trace: trace,
 	}
 }
 
+func KmsUnavailableErr(err error) error {
+	return &CusErr{
+		Code:  Errs["KmsUnavailableErr"].Code,
+		Type:  Errs["KmsUnavailableErr"].Type,
+		Kind:  KindKmsUnavailable,
+		Inner: err,
+	}
+}
+
+func KmsThrottlingErr(err error) error {
+	return &CusErr{
+		Code:  Errs["KmsThrottlingErr"].Code,
+		Type:  Errs["KmsThrottlingErr"].Type,
+		Kind:  KindKmsThrottled,
+		Inner: err,
+	}
+}
+
+func KeyDisabledErr(err error) error {
+	return &CusErr{
+		Code:  Errs["KeyDisabledErr"].Code,
+		Type:  Errs["KeyDisabledErr"].Type,
+		Kind:  KindKeyDisabled,
+		Inner: err,
+	}
+}
+
+func InvalidKeyUsageErr(err error) error {
+	return &CusErr{
+		Code:  Errs["InvalidKeyUsageErr"].Code,
+		Type:  Errs["InvalidKeyUsageErr"].Type,
+		Kind:  KindKeyUsageInvalid,
+		Inner: err,
+	}
+}
+
+func UnsupportedOperationErr(err error) error {
+	return &CusErr{
+		Code:  Errs["UnsupportedOperationErr"].Code,
+		Type:  Errs["UnsupportedOperationErr"].Type,
+		Kind:  KindOperationUnsupported,
+		Inner: err,
+	}
+}
+
+func IncorrectKeyMaterialErr(err error) error {
+	return &CusErr{
+		Code:  Errs["IncorrectKeyMaterialErr"].Code,
+		Type:  Errs["IncorrectKeyMaterialErr"].Type,
+		Kind:  KindKeyMaterialIncorrect,
+		Inner: err,
+	}
+}
+
+func ImportTokenExpiredErr(err error) error {
+	return &CusErr{
+		Code:  Errs["ImportTokenExpiredErr"].Code,
+		Type:  Errs["ImportTokenExpiredErr"].Type,
+		Kind:  KindImportTokenExpired,
+		Inner: err,
+	}
+}
+
+func InvalidImportTokenErr(err error) error {
+	return &CusErr{
+		Code:  Errs["InvalidImportTokenErr"].Code,
+		Type:  Errs["InvalidImportTokenErr"].Type,
+		Kind:  KindImportTokenInvalid,
+		Inner: err,
+	}
+}
+
+func AlreadyExistsErr(err error) error {
+	return &CusErr{
+		Code:  Errs["AlreadyExistsErr"].Code,
+		Type:  Errs["AlreadyExistsErr"].Type,
+		Kind:  KindAlreadyExists,
+		Inner: err,
+	}
+}
+
+func KmsLimitExceededErr(err error) error {
+	return &CusErr{
+		Code:  Errs["KmsLimitExceededErr"].Code,
+		Type:  Errs["KmsLimitExceededErr"].Type,
+		Kind:  KindKmsLimitExceeded,
+		Inner: err,
+	}
+}
+
+func KmsAccessDeniedErr(err error) error {
+	return &CusErr{
+		Code:  Errs["KmsAccessDeniedErr"].Code,
+		Type:  Errs["KmsAccessDeniedErr"].Type,
+		Kind:  KindKmsAccessDenied,
+		Inner: err,
+	}
+}
+
+func KeyUnavailableErr(err error) error {
+	return &CusErr{
+		Code:  Errs["KeyUnavailableErr"].Code,
+		Type:  Errs["KeyUnavailableErr"].Type,
+		Kind:  KindKeyUnavailable,
+		Inner: err,
+	}
+}
+
+func KeyStoreUnavailableErr(err error) error {
+	return &CusErr{
+		Code:  Errs["KeyStoreUnavailableErr"].Code,
+		Type:  Errs["KeyStoreUnavailableErr"].Type,
+		Kind:  KindKeyStoreUnavailable,
+		Inner: err,
+	}
+}
+
 func UnhandledServerErr(err error) error {
 	return &CusErr{
 		Code:  Errs["UnhandledServerErr"].Code,
 		Type:  Errs["UnhandledServerErr"].Type,
+		Kind:  KindUnhandled,
 		Inner: err,
 	}
 }
 
-func RouteAwsErr(err error) error {
-	var (
-		awsErrRes        *awsHttp.ResponseError
-		notFoundErr      *awsTypes.NotFoundException
-		invalidMarkerErr *awsTypes.InvalidMarkerException
-		invalidStateErr  *awsTypes.KMSInvalidStateException
-	)
-
-	if errors.As(err, &notFoundErr) { // 없거나 잘못된 kms-keyID
-		if splitedMsg := strings.Split(*notFoundErr.Message, "/"); len(splitedMsg) > 1 {
-			return KeyIdNotFoundErr(fmt.Errorf("keyId '%v not found", splitedMsg[1]))
-		} else {
-			return KeyIdNotFoundErr(fmt.Errorf(*notFoundErr.Message))
-		}
-
-	} else if errors.As(err, &invalidMarkerErr) { // 없거나 잘못된 marker
-		newMsg, _ := strings.CutSuffix(invalidMarkerErr.Error(), ": ")
-		return InvalidMarkerErr(fmt.Errorf(newMsg))
+// 에러 타입과 코드는 유지한 채로 내부 에러에 부가 정보를 덧붙인다
+func Annotate(err error, note string) error {
+	var customErr *CusErr
+	if errors.As(err, &customErr) {
+		annotated := *customErr
+		annotated.Inner = fmt.Errorf("%w (%s)", customErr.Inner, note)
+		return &annotated
+	}
+	return fmt.Errorf("%w (%s)", err, note)
+}
 
-	} else if errors.As(err, &invalidStateErr) { // 사용불가능한 상태의 kms-key
-		if splitedMsg := strings.Split(*invalidStateErr.Message, "/"); len(splitedMsg) > 1 {
-			return InvalidKeyErr(fmt.Errorf("keyId '%v", splitedMsg[1]))
-		} else {
-			return InvalidKeyErr(fmt.Errorf(*invalidStateErr.Message))
-		}
+// 요청 context 가 끝난 이유에 맞는 에러를 리턴 (취소 499, deadline 초과 408)
+func RouteCtxErr(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return RequestTimeoutErr(err)
+	}
+	return RequestCanceledErr(err)
+}
 
-	} else if errors.As(err, &awsErrRes) {
-		return UnhandledAwsKmsErr(err)
-	} else {
-		return UnhandledAwsKmsErr(err)
+// kms 요청 한도 초과로 인한 에러인지 확인 (재시도 대상)
+func IsThrottling(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode() == "ThrottlingException"
 	}
+	return false
 }
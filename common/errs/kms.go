@@ -0,0 +1,158 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/smithy-go"
+)
+
+// aws 에러 메세지에 포함된 arn 에서 keyID 만 꺼낸다 (arn:aws:kms:<region>:<account>:key/<keyID>)
+var arnKeyID = regexp.MustCompile(`key/([0-9A-Za-z-]+)`)
+
+// 응답에는 aws 메세지(arn, 계정 id 포함) 대신 keyID 만 노출한다
+func keyRef(apiErr smithy.APIError) string {
+	if match := arnKeyID.FindStringSubmatch(apiErr.ErrorMessage()); match != nil {
+		return fmt.Sprintf("keyId '%v'", match[1])
+	}
+	return "key"
+}
+
+// kms 예외 코드(smithy ErrorCode)별 에러. 4xx 는 사용자에게 보여줄 수 있는 고정 문구를 사용하고,
+// 5xx 는 로그에만 남기기 때문에 원래 에러를 그대로 감싼다
+var kmsExceptions = map[string]func(err error, apiErr smithy.APIError) error{
+	// 요청한 키
+	"NotFoundException": func(_ error, apiErr smithy.APIError) error {
+		return KeyIdNotFoundErr(fmt.Errorf("%v not found", keyRef(apiErr)))
+	},
+	"InvalidArnException": func(error, smithy.APIError) error {
+		return BadRequestErr(fmt.Errorf("keyId is not a valid key id or arn"))
+	},
+	"KMSInvalidStateException": func(_ error, apiErr smithy.APIError) error {
+		return InvalidKeyErr(fmt.Errorf("%v is not in a usable state", keyRef(apiErr)))
+	},
+	"DisabledException": func(_ error, apiErr smithy.APIError) error {
+		return KeyDisabledErr(fmt.Errorf("%v is disabled", keyRef(apiErr)))
+	},
+	"KeyUnavailableException": func(err error, _ smithy.APIError) error {
+		return KeyUnavailableErr(err)
+	},
+	"InvalidKeyUsageException": func(_ error, apiErr smithy.APIError) error {
+		return InvalidKeyUsageErr(fmt.Errorf("%v does not support the requested operation", keyRef(apiErr)))
+	},
+	"UnsupportedOperationException": func(error, smithy.APIError) error {
+		return UnsupportedOperationErr(fmt.Errorf("the requested operation is not supported for the key"))
+	},
+	"AlreadyExistsException": func(error, smithy.APIError) error {
+		return AlreadyExistsErr(fmt.Errorf("the resource already exists"))
+	},
+
+	// 외부키 주입
+	"IncorrectKeyMaterialException": func(error, smithy.APIError) error {
+		return IncorrectKeyMaterialErr(fmt.Errorf("key material does not match the key material previously imported into the key"))
+	},
+	"IncorrectKeyException": func(error, smithy.APIError) error {
+		return IncorrectKeyMaterialErr(fmt.Errorf("the key is not the one that was used for the operation"))
+	},
+	"InvalidCiphertextException": func(error, smithy.APIError) error {
+		return IncorrectKeyMaterialErr(fmt.Errorf("encrypted key material could not be decrypted with the import token"))
+	},
+	"ExpiredImportTokenException": func(error, smithy.APIError) error {
+		return ImportTokenExpiredErr(fmt.Errorf("import token has expired, request new import parameters"))
+	},
+	"InvalidImportTokenException": func(error, smithy.APIError) error {
+		return InvalidImportTokenErr(fmt.Errorf("import token was not issued for the key"))
+	},
+
+	// 잘못된 요청값
+	"InvalidMarkerException": func(error, smithy.APIError) error {
+		return InvalidMarkerErr(fmt.Errorf("marker is invalid or expired"))
+	},
+	"ValidationException": func(error, smithy.APIError) error {
+		return BadRequestErr(fmt.Errorf("request parameters were rejected by aws_kms"))
+	},
+	"TagException": func(error, smithy.APIError) error {
+		return BadRequestErr(fmt.Errorf("tags are invalid"))
+	},
+	"MalformedPolicyDocumentException": func(error, smithy.APIError) error {
+		return BadRequestErr(fmt.Errorf("key policy is malformed"))
+	},
+	"InvalidAliasNameException": func(error, smithy.APIError) error {
+		return BadRequestErr(fmt.Errorf("alias name is invalid"))
+	},
+	"InvalidGrantIdException": func(error, smithy.APIError) error {
+		return BadRequestErr(fmt.Errorf("grant id is invalid"))
+	},
+	"InvalidGrantTokenException": func(error, smithy.APIError) error {
+		return BadRequestErr(fmt.Errorf("grant token is invalid"))
+	},
+	"KMSInvalidSignatureException": func(error, smithy.APIError) error {
+		return BadRequestErr(fmt.Errorf("signature is invalid"))
+	},
+	"KMSInvalidMacException": func(error, smithy.APIError) error {
+		return BadRequestErr(fmt.Errorf("mac is invalid"))
+	},
+	"DryRunOperationException": func(error, smithy.APIError) error {
+		return BadRequestErr(fmt.Errorf("dry run request would have succeeded"))
+	},
+
+	// kms 쪽 상태
+	"ThrottlingException": func(err error, _ smithy.APIError) error {
+		return KmsThrottlingErr(err)
+	},
+	"LimitExceededException": func(error, smithy.APIError) error {
+		return KmsLimitExceededErr(fmt.Errorf("aws_kms resource quota exceeded"))
+	},
+	"KMSInternalException": func(err error, _ smithy.APIError) error {
+		return KmsUnavailableErr(err)
+	},
+	"DependencyTimeoutException": func(err error, _ smithy.APIError) error {
+		return KmsUnavailableErr(err)
+	},
+
+	// 서버의 aws 자격증명, 권한
+	"AccessDeniedException":               kmsAccessDenied,
+	"UnrecognizedClientException":         kmsAccessDenied,
+	"InvalidSignatureException":           kmsAccessDenied,
+	"IncompleteSignatureException":        kmsAccessDenied,
+	"MissingAuthenticationTokenException": kmsAccessDenied,
+	"ExpiredTokenException":               kmsAccessDenied,
+}
+
+func kmsAccessDenied(err error, _ smithy.APIError) error {
+	return KmsAccessDeniedErr(err)
+}
+
+// custom key store (CloudHSM, 외부 키 저장소) 관련 예외
+var keyStoreExceptionPrefixes = []string{"CloudHsm", "CustomKeyStore", "Xks", "IncorrectTrustAnchor"}
+
+func RouteAwsErr(err error) error {
+	var (
+		customErr *CusErr
+		apiErr    smithy.APIError
+	)
+
+	if errors.As(err, &customErr) { // 이미 분류된 에러 (ex. 재시도 도중 요청 취소)
+		return err
+
+	} else if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrKmsTimeout) { // 장애 혹은 타임아웃
+		return KmsUnavailableErr(err)
+
+	} else if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) { // 요청 취소 혹은 deadline 초과
+		return RouteCtxErr(err)
+
+	} else if errors.As(err, &apiErr) {
+		if route, ok := kmsExceptions[apiErr.ErrorCode()]; ok {
+			return route(err, apiErr)
+		}
+		for _, prefix := range keyStoreExceptionPrefixes {
+			if strings.HasPrefix(apiErr.ErrorCode(), prefix) {
+				return KeyStoreUnavailableErr(err)
+			}
+		}
+	}
+	return UnhandledAwsKmsErr(err)
+}
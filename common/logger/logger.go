@@ -3,6 +3,8 @@ package logger
 import (
 	// "errors"
 
+	"context"
+	"io"
 	"os"
 	"strings"
 
@@ -19,12 +21,29 @@ type LogItem struct {
 
 func Init(env string) {
 	// zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
-	_logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
+	SetOutput(os.Stdout)
 	if env != "local" {
 		zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	}
 }
 
+// 로그를 기록할 곳을 바꾼다 (기본값 stdout)
+func SetOutput(w io.Writer) {
+	_logger = zerolog.New(w).With().Timestamp().Logger()
+}
+
+type requestIDKey struct{}
+
+// 요청 context 에 request id 를 저장한다. Ctx 로 로그에 포함시킬 수 있다
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
 func newLogItem(event *zerolog.Event) *LogItem {
 	return &LogItem{
 		event: event,
@@ -47,8 +66,9 @@ func Error() *LogItem {
 	return newLogItem(_logger.Error())
 }
 
+// panic 레벨로 기록만 하고 panic 을 다시 발생시키지는 않는다 (recover 에서 사용)
 func Panic() *LogItem {
-	return newLogItem(_logger.Panic())
+	return newLogItem(_logger.WithLevel(zerolog.PanicLevel))
 }
 
 // data
@@ -57,6 +77,14 @@ func (l *LogItem) D(key string, data interface{}) *LogItem {
 	return l
 }
 
+// 요청 context 의 request id
+func (l *LogItem) Ctx(ctx context.Context) *LogItem {
+	if requestID := RequestID(ctx); requestID != "" {
+		l.event = l.event.Str("requestID", requestID)
+	}
+	return l
+}
+
 // error
 func (l *LogItem) E(err error) *LogItem {
 	l.event = l.event.Err(err)
@@ -0,0 +1,21 @@
+package ethutil
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	erc20BalanceOfSelector = common.FromHex("0x70a08231") // balanceOf(address)
+	erc20TransferSelector  = common.FromHex("0xa9059cbb") // transfer(address,uint256)
+)
+
+func ERC20BalanceOfCallData(owner common.Address) []byte {
+	return append(append([]byte{}, erc20BalanceOfSelector...), PadLeftTo32Bytes(owner.Bytes())...)
+}
+
+func ERC20TransferCallData(to common.Address, amount *big.Int) []byte {
+	calldata := append(append([]byte{}, erc20TransferSelector...), PadLeftTo32Bytes(to.Bytes())...)
+	return append(calldata, PadLeftTo32Bytes(amount.Bytes())...)
+}
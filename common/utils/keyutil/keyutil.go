@@ -0,0 +1,196 @@
+package keyutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+var (
+	oidEcPublicKey = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidSecp256k1   = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// SEC1 (RFC 5915) EC private key
+type asn1PKFormat struct {
+	Version       int
+	PrivateKey    []byte
+	NamedCurveOID asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey     asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+// PKCS#8 private key
+type pkcs8Asn1PKFormat struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// aws kms 에 주입 가능한 PKCS#8 DER 형식으로 private key 를 인코딩
+func MarshalPKCS8(ecdsaPK *ecdsa.PrivateKey) ([]byte, error) {
+	asn1EcPK, err := asn1.Marshal(asn1PKFormat{
+		Version:       1,
+		PrivateKey:    crypto.FromECDSA(ecdsaPK),
+		PublicKey:     asn1.BitString{Bytes: crypto.FromECDSAPub(&ecdsaPK.PublicKey)},
+		NamedCurveOID: oidEcPublicKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pkcs8Asn1PKFormat{
+		Version: 0,
+		Algo: pkix.AlgorithmIdentifier{
+			Algorithm:  oidEcPublicKey,
+			Parameters: asn1.RawValue{Class: 0, Tag: 6, IsCompound: false, Bytes: []uint8{0x2b, 0x81, 0x4, 0x0, 0xa}, FullBytes: []uint8{0x6, 0x5, 0x2b, 0x81, 0x4, 0x0, 0xa}},
+		},
+		PrivateKey: asn1EcPK,
+	})
+}
+
+// SEC1("EC PRIVATE KEY") 혹은 PKCS#8("PRIVATE KEY") PEM 으로부터 secp256k1 private key 를 파싱
+// crypto/x509 는 secp256k1 커브를 지원하지 않기 때문에 직접 ASN.1 디코딩한다
+func ParsePEM(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode pem block")
+	}
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return parseSEC1(block.Bytes)
+
+	case "PRIVATE KEY":
+		return ParsePKCS8(block.Bytes)
+
+	default:
+		return nil, fmt.Errorf("unsupported pem type '%v'", block.Type)
+	}
+}
+
+// PKCS#8 DER 로 인코딩된 secp256k1 private key 를 파싱
+func ParsePKCS8(der []byte) (*ecdsa.PrivateKey, error) {
+	var pkcs8 pkcs8Asn1PKFormat
+	if _, err := asn1.Unmarshal(der, &pkcs8); err != nil {
+		return nil, err
+	}
+	if !pkcs8.Algo.Algorithm.Equal(oidEcPublicKey) {
+		return nil, fmt.Errorf("unsupported pkcs8 algorithm %v", pkcs8.Algo.Algorithm)
+	}
+	var curve asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(pkcs8.Algo.Parameters.FullBytes, &curve); err != nil {
+		return nil, err
+	}
+	if !curve.Equal(oidSecp256k1) {
+		return nil, fmt.Errorf("unsupported curve %v", curve)
+	}
+	return parseSEC1(pkcs8.PrivateKey)
+}
+
+func parseSEC1(der []byte) (*ecdsa.PrivateKey, error) {
+	var sec1 asn1PKFormat
+	if _, err := asn1.Unmarshal(der, &sec1); err != nil {
+		return nil, err
+	}
+	if sec1.NamedCurveOID != nil && !sec1.NamedCurveOID.Equal(oidSecp256k1) && !sec1.NamedCurveOID.Equal(oidEcPublicKey) {
+		return nil, fmt.Errorf("unsupported curve %v", sec1.NamedCurveOID)
+	}
+	return crypto.ToECDSA(math.PaddedBigBytes(new(big.Int).SetBytes(sec1.PrivateKey), 32))
+}
+
+// Web3 keystore v3 json 을 복호화
+func DecryptKeystore(keystoreJSON []byte, passphrase string) (*ecdsa.PrivateKey, error) {
+	key, err := keystore.DecryptKey(keystoreJSON, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return key.PrivateKey, nil
+}
+
+// BIP-39 니모닉으로부터 BIP-32 경로의 private key 를 유도
+func DeriveFromMnemonic(mnemonic, passphrase string, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := new(big.Int).SetBytes(sum[:32]), sum[32:]
+
+	curveN := crypto.S256().Params().N
+	if key.Sign() == 0 || key.Cmp(curveN) >= 0 {
+		return nil, fmt.Errorf("invalid master key")
+	}
+
+	for _, index := range path {
+		var data []byte
+		if index >= 0x80000000 { // hardened
+			data = append([]byte{0}, math.PaddedBigBytes(key, 32)...)
+		} else {
+			ecdsaPK, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+			if err != nil {
+				return nil, err
+			}
+			data = crypto.CompressPubkey(&ecdsaPK.PublicKey)
+		}
+		data = binary.BigEndian.AppendUint32(data, index)
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+
+		tweak := new(big.Int).SetBytes(sum[:32])
+		if tweak.Cmp(curveN) >= 0 {
+			return nil, fmt.Errorf("invalid child key at index %v", index)
+		}
+		key = new(big.Int).Mod(new(big.Int).Add(tweak, key), curveN)
+		if key.Sign() == 0 {
+			return nil, fmt.Errorf("invalid child key at index %v", index)
+		}
+		chainCode = sum[32:]
+	}
+
+	return crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+}
+
+// aws kms 의 GetParametersForImport 로 받은 wrapping public key(DER)로 private key 를 RSA-OAEP(SHA-256) 암호화
+// 클라이언트가 평문 키를 서버로 보내지 않고 직접 주입 데이터를 만들 때 사용한다
+func WrapKeyMaterial(ecdsaPK *ecdsa.PrivateKey, wrappingPubKeyDER []byte) ([]byte, error) {
+	pkcs8Asn1EcPK, err := MarshalPKCS8(ecdsaPK)
+	if err != nil {
+		return nil, err
+	}
+	return EncryptKeyMaterial(pkcs8Asn1EcPK, wrappingPubKeyDER)
+}
+
+// PKCS#8 DER 로 인코딩된 key material 을 wrapping public key(DER)로 RSA-OAEP(SHA-256) 암호화
+func EncryptKeyMaterial(pkcs8Asn1EcPK []byte, wrappingPubKeyDER []byte) ([]byte, error) {
+	wrappingPubKey, err := x509.ParsePKIXPublicKey(wrappingPubKeyDER)
+	if err != nil {
+		return nil, err
+	}
+	rsaPubKey, ok := wrappingPubKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("wrapping key is not an rsa public key")
+	}
+
+	return rsa.EncryptOAEP(sha256.New(), rand.Reader, rsaPubKey, pkcs8Asn1EcPK, nil)
+}
@@ -2,79 +2,321 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	ctrl "kms/wallet/app/api/controller"
 	"kms/wallet/app/api/model/dto"
 	srv "kms/wallet/app/api/service"
+	"kms/wallet/app/cache"
+	"kms/wallet/app/kmsclient"
+	"kms/wallet/app/metrics"
 	"kms/wallet/app/server"
+	"kms/wallet/app/store"
+	"kms/wallet/app/tracing"
+	"kms/wallet/common/audit"
 	"kms/wallet/common/config"
 	"kms/wallet/common/logger"
 	"log"
 	"math/big"
 	"os"
+	"sort"
 
-	awscfg "github.com/aws/aws-sdk-go-v2/config"
-
-	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
-	"github.com/aws/aws-sdk-go/aws"
-	"golang.org/x/exp/slices"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 func init() {
-	var (
-		curEnv = flag.String("env", "local", "environment")
-	)
-	flag.Parse()
-	if envs := []string{"local", "dev", "stg", "prd"}; !slices.Contains(envs, *curEnv) {
-		log.Fatalf("env must be one of %v (env=%v)", envs, *curEnv)
+	cfg, printConfig, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if printConfig && cfg != nil {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
 	}
-
-	wd, err := os.Getwd()
 	if err != nil {
-		log.Fatal(err)
+		// 표준 log 는 의존성이 slog 로 넘겨놓아 출력되지 않을 수 있기 때문에 stderr 에 직접 쓴다
+		fmt.Fprintf(os.Stderr, "invalid config:\n%v\n", err)
+		os.Exit(1)
 	}
+	if printConfig {
+		os.Exit(0)
+	}
+	config.Env = cfg
 
-	logger.Init(*curEnv)
-	config.Init(wd + "/env/.env." + *curEnv)
+	logger.Init(cfg.Environment)
 	dto.Init()
+	if err := audit.Init(cfg.Policy.AuditLogPath); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func main() {
-	creds := credentials.NewStaticCredentialsProvider(config.Env.AWS_ACCESS_KEY, config.Env.AWS_SECRET_KEY, "")
-	awsCfg, err := awscfg.LoadDefaultConfig(
-		context.Background(),
-		awscfg.WithCredentialsProvider(creds),
-		awscfg.WithRegion(config.Env.AWS_REGION),
-	)
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Exporter: config.Env.Telemetry.TracingExporter,
+		Endpoint: config.Env.Telemetry.TracingEndpoint,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	var kmsClient *kms.Client
-	if config.Env.ENV == "local" {
-		kmsClient = kms.NewFromConfig(awsCfg, func(o *kms.Options) {
-			o.BaseEndpoint = aws.String("http://localhost:8080")
+	kmsConfig := config.Env.Kms
+	// 기본 backend 를 먼저 두고 나머지는 이름 순서로 둔다. 새 키는 기본 backend 에 만들어진다
+	backendConfigs := []kmsBackendConfig{{config.DefaultBackend, config.KmsBackend{
+		Region:        kmsConfig.Region,
+		Account:       kmsConfig.Account,
+		AssumeRoleARN: kmsConfig.AssumeRoleARN,
+		Endpoint:      kmsConfig.Endpoint,
+	}}}
+	for _, name := range sortedKeys(kmsConfig.Backends) {
+		backendConfigs = append(backendConfigs, kmsBackendConfig{name, kmsConfig.Backends[name]})
+	}
+	// /api/health 와 breaker 지표는 기본 backend 기준이다
+	var resilientKmsClient *kmsclient.ResilientClient
+	var backends []kmsclient.Backend
+	accountID := kmsConfig.Account
+	for i, backendConfig := range backendConfigs {
+		client, callerAccount, err := newKmsClient(backendConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if i == 0 {
+			resilientKmsClient = client
+			if accountID == "" {
+				accountID = callerAccount
+			}
+		}
+		backends = append(backends, kmsclient.Backend{
+			Name:    backendConfig.name,
+			Region:  backendConfig.Region,
+			Account: backendConfig.Account,
+			Client:  client,
 		})
-	} else {
-		kmsClient = kms.NewFromConfig(awsCfg)
+	}
+	kmsRouter, err := kmsclient.NewRouter(backends, kmsConfig.KeyBackends)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	server := server.New()
-	chainID, ok := new(big.Int).SetString(config.Env.CHAIN_ID, 10)
-	if !ok {
-		log.Fatal("Invalid CHAIN_ID")
-	}
+	chainID := new(big.Int).SetUint64(config.Env.Chains.ChainID)
 
-	kmsSrv := srv.NewKmsSrv(kmsClient)
+	pubKeyCache, err := newPubKeyCache()
+	if err != nil {
+		log.Fatal(err)
+	}
+	keyPolicies, err := newKeyPolicies(accountID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	freezeStore, err := store.NewFreezeStore(config.Env.Policy.FreezeStatePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	kmsSrv := srv.NewKmsSrv(kmsRouter,
+		srv.WithPubKeyCache(pubKeyCache),
+		srv.WithFreezeStore(freezeStore),
+		srv.WithReplicaRegions(replicaRegions(backends)...),
+		srv.WithKeyPolicies(keyPolicies),
+	)
+	if keyIDs := kmsConfig.PubKeyCache.WarmUp; len(keyIDs) > 0 {
+		loaded := kmsSrv.WarmUpPubKeys(context.Background(), keyIDs)
+		logger.Info().D("loaded", loaded).D("requested", len(keyIDs)).W("public key cache warmed up")
+	}
 	txnSrv := srv.NewTxnSrv(chainID, kmsSrv)
 
+	metrics.EnablePerKey(config.Env.Telemetry.MetricsPerKey)
+	metrics.SetPubKeyCache(kmsSrv.PubKeyCacheStats)
+	metrics.SetBreaker(func() string { return resilientKmsClient.Health().State })
+
+	healthSrv := newHealthSrv(kmsSrv, chainID)
+
 	apiRouter := server.App.Group("/api")
-	ctrl.NewAppCtrl().BootStrap(apiRouter)
+	appCtrl := ctrl.NewAppCtrl(resilientKmsClient)
+	appCtrl.BootStrap(apiRouter)
+	if config.Env.Environment == "local" {
+		appCtrl.BootStrapDebug(apiRouter)
+	}
+	ctrl.NewHealthCtrl(healthSrv).BootStrap(apiRouter)
 	ctrl.NewKmsCtrl(kmsSrv).BootStrap(apiRouter)
 	ctrl.NewTxnCtrl(txnSrv).BootStrap(apiRouter)
 
-	if err := server.App.Listen(":7777"); err != nil {
+	jobSrv, jobStore, err := newJobSrv(txnSrv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := jobSrv.Start(); err != nil {
+		log.Fatal(err)
+	}
+	ctrl.NewJobCtrl(jobSrv).BootStrap(apiRouter)
+
+	if chains := config.Env.Chains; chains.RPCURL != "" {
+		chainClient, err := ethclient.Dial(chains.RPCURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var tokens []common.Address
+		for _, token := range chains.SweepTokens {
+			tokens = append(tokens, common.HexToAddress(token))
+		}
+		rotationStore, err := store.NewRotationStore(config.Env.Policy.RotationStatePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rotationSrv := srv.NewRotationSrv(kmsSrv, txnSrv, chainClient, tokens, rotationStore)
+		if err := rotationSrv.ReapplyRetirements(context.Background()); err != nil {
+			log.Fatal(err)
+		}
+		ctrl.NewRotationCtrl(rotationSrv).BootStrap(apiRouter)
+	}
+
+	// http 요청이 모두 끝난 뒤 순서대로 실행된다
+	server.OnShutdown("jobs", jobSrv.Shutdown)
+	server.OnShutdown("job store", func(context.Context) error { return jobStore.Close() })
+	server.OnShutdown("audit", func(context.Context) error { return audit.Close() })
+	server.OnShutdown("metrics", metrics.Flush)
+	server.OnShutdown("tracing", shutdownTracing)
+
+	if err := server.Run(fmt.Sprintf(":%d", config.Env.Server.Port)); err != nil {
 		log.Fatal(err)
 	}
 }
+
+type kmsBackendConfig struct {
+	name string
+	config.KmsBackend
+}
+
+// backend 별로 자격증명, 제한시간, 재시도, circuit breaker 를 따로 둔다. 확인한 호출 주체의 계정을 같이 리턴한다
+func newKmsClient(backendConfig kmsBackendConfig) (*kmsclient.ResilientClient, string, error) {
+	kmsConfig := config.Env.Kms
+	awsConfig := kmsclient.AwsConfig{
+		Region:        backendConfig.Region,
+		AccessKey:     kmsConfig.AccessKey,
+		SecretKey:     kmsConfig.SecretKey,
+		AssumeRoleARN: backendConfig.AssumeRoleARN,
+		ExternalID:    kmsConfig.ExternalID,
+		SessionName:   kmsConfig.SessionName,
+		StsEndpoint:   kmsConfig.StsEndpoint,
+	}
+	if awsConfig.AssumeRoleARN == "" {
+		awsConfig.AssumeRoleARN = kmsConfig.AssumeRoleARN
+	}
+	awsCfg, err := kmsclient.LoadAwsConfig(context.Background(), awsConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	callerAccount := logCallerIdentity(backendConfig.name, awsCfg, awsConfig)
+
+	// 재시도는 kmsclient 에서 처리하기 때문에 sdk 의 재시도는 끈다
+	kmsClient := kms.NewFromConfig(awsCfg, func(o *kms.Options) {
+		if backendConfig.Endpoint != "" {
+			o.BaseEndpoint = aws.String(backendConfig.Endpoint)
+		}
+		o.RetryMaxAttempts = 1
+		o.APIOptions = append(o.APIOptions, kmsclient.WithRequestID, tracing.WithKMS)
+	})
+	kmsClientConfig := kmsclient.DefaultConfig()
+	kmsClientConfig.Timeout = kmsConfig.Timeout
+	kmsClientConfig.MaxRetries = kmsConfig.MaxRetries
+	kmsClientConfig.FailureThreshold = kmsConfig.BreakerThreshold
+	kmsClientConfig.OpenTimeout = kmsConfig.BreakerCooldown
+	return kmsclient.New(kmsClient, kmsClientConfig), callerAccount, nil
+}
+
+// 어떤 자격증명으로 kms 를 호출하는지 남긴다. 확인하지 못해도 시작은 계속하고 readiness 에서 kms 상태를 확인한다
+func logCallerIdentity(backend string, awsCfg aws.Config, awsConfig kmsclient.AwsConfig) (account string) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Env.Kms.Timeout)
+	defer cancel()
+	identity, err := kmsclient.GetCallerIdentity(ctx, awsCfg, awsConfig.StsEndpoint)
+	if err != nil {
+		logger.Warn().E(err).D("backend", backend).D("assumeRole", awsConfig.AssumeRoleARN).W("failed to resolve aws caller identity")
+		return ""
+	}
+	logger.Info().D("backend", backend).D("account", identity.Account).D("arn", identity.ARN).D("region", awsConfig.Region).W("aws caller identity")
+	return identity.Account
+}
+
+// 모든 key policy 템플릿의 placeholder 를 채운다. accountID 는 kms.account, 없으면 기본 backend 의 호출 주체 계정
+func newKeyPolicies(accountID string) (srv.KeyPolicies, error) {
+	keyPolicy := config.Env.Kms.KeyPolicy
+	keyPolicies := srv.KeyPolicies{Default: keyPolicy.Template, Documents: make(map[string]string)}
+	for name := range keyPolicy.Templates {
+		document, err := keyPolicy.Render(name, accountID)
+		if err != nil {
+			return srv.KeyPolicies{}, err
+		}
+		keyPolicies.Documents[name] = document
+	}
+	return keyPolicies, nil
+}
+
+// replica region 마다 같은 region 의 첫번째 backend 의 kms 로 서명한다 (설정 검증에서 backend 가 있는지 확인한다)
+func replicaRegions(backends []kmsclient.Backend) []srv.ReplicaRegion {
+	var replicas []srv.ReplicaRegion
+	for _, region := range config.Env.Kms.ReplicaRegions {
+		for _, backend := range backends[1:] {
+			if backend.Region == region {
+				replicas = append(replicas, srv.ReplicaRegion{Region: region, Client: backend.Client})
+				break
+			}
+		}
+	}
+	return replicas
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newPubKeyCache() (*cache.PubKeyCache, error) {
+	cacheConfig := config.Env.Kms.PubKeyCache
+	return cache.NewPubKeyCache(cache.PubKeyCacheConfig{
+		Size:         cacheConfig.Size,
+		TTL:          cacheConfig.TTL,
+		SnapshotPath: cacheConfig.Path,
+	})
+}
+
+func newJobSrv(txnSrv *srv.TxnSrv) (*srv.JobSrv, store.JobStore, error) {
+	jobsConfig := config.Env.Jobs
+	var jobStore store.JobStore
+	switch jobsConfig.Store {
+	case "memory":
+		jobStore = store.NewMemoryJobStore()
+	case "sqlite":
+		sqliteStore, err := store.NewSQLiteJobStore(jobsConfig.DBPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		jobStore = sqliteStore
+	default:
+		return nil, nil, fmt.Errorf("invalid jobs.store %v", jobsConfig.Store)
+	}
+
+	jobConfig := srv.JobSrvConfig{Workers: jobsConfig.Workers, WebhookSecret: jobsConfig.WebhookSecret}
+	return srv.NewJobSrv(txnSrv, jobStore, jobConfig), jobStore, nil
+}
+
+func newHealthSrv(kmsSrv *srv.KmsSrv, chainID *big.Int) *srv.HealthSrv {
+	healthConfig := config.Env.Health
+	rpcURLs := healthConfig.RPCURLs
+	if len(rpcURLs) == 0 && config.Env.Chains.RPCURL != "" {
+		rpcURLs = []string{config.Env.Chains.RPCURL}
+	}
+	return srv.NewHealthSrv(kmsSrv, srv.HealthSrvConfig{
+		CanaryKeyID: healthConfig.CanaryKeyID,
+		RPCURLs:     rpcURLs,
+		ChainID:     chainID,
+		Timeout:     healthConfig.Timeout,
+	})
+}